@@ -1,9 +1,11 @@
 package main
 
 import (
+	"devbox/pkg/progress"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -13,7 +15,9 @@ import (
 // It configures the logger to use ISO8601 time format and capitalizes the log levels.
 // The logger is set to production mode by default, but can be configured for debug mode if verbose is true.
 // If filename is not empty, the logger's output is redirected to the specified file.
-func SetupZapLogger(verbose bool, filename string) {
+// If display is not nil, the console logs are written above its progress rows, only the warnings and errors unless verbose is true.
+// It returns an error when the log file can not be opened.
+func SetupZapLogger(verbose bool, filename string, display *progress.Display) error {
 	// Set up the logger configuration
 	config := zap.NewDevelopmentConfig()
 
@@ -35,19 +39,30 @@ func SetupZapLogger(verbose bool, filename string) {
 	// If a filename is specified, update the output path.
 	if filename != "" {
 		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create log directory: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("failed to create log directory: %w", err)
 		}
 		config.OutputPaths = []string{filename, "stderr"}
 	}
 
+	var options []zap.Option
+	if display != nil {
+		consoleLevel := zapcore.WarnLevel
+		if verbose {
+			consoleLevel = zapcore.DebugLevel
+		}
+		config.OutputPaths = slices.DeleteFunc(config.OutputPaths, func(path string) bool { return path == "stderr" })
+		options = append(options, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, zapcore.NewCore(zapcore.NewConsoleEncoder(config.EncoderConfig), display, consoleLevel))
+		}))
+	}
+
 	// Create the logger
-	logger, err := config.Build()
+	logger, err := config.Build(options...)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create logger: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to create logger: %w", err)
 	}
 
 	// Set the global logger
 	zap.ReplaceGlobals(logger)
+	return nil
 }
@@ -1,20 +1,46 @@
 package main
 
 import (
+	"devbox/internal/commands"
+	"devbox/internal/commands/box"
+	"devbox/internal/commands/doctor"
+	"devbox/internal/commands/image"
 	"devbox/internal/commands/install"
+	"devbox/internal/commands/lock"
+	"devbox/internal/commands/project"
 	"devbox/internal/commands/setup"
+	"devbox/internal/commands/upgrade"
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/progress"
 	"devbox/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"os"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
+const (
+	// PROGRESS_ANNOTATION marks the commands displaying the progress of their tasks on a terminal
+	PROGRESS_ANNOTATION = "progress"
+
+	// OUTPUT_TEXT and OUTPUT_JSON are the output formats of the commands
+	OUTPUT_TEXT = "text"
+	OUTPUT_JSON = "json"
+)
+
 var (
 	version = "dev"
 
 	args ParserArgs
 
+	// started is the start time of the command, reported in its result
+	started = time.Now()
+
 	mainCmd = &cobra.Command{
 		Use:     "devbox {setup|install|share} [flags]",
 		Version: version,
@@ -22,9 +48,26 @@ var (
 		Long: `devbox is the package manager for the distrobox ecosystem.
 It helps you install packages on your distrobox and export them to your host system.`,
 		PersistentPreRun: func(cmd *cobra.Command, preRunArgs []string) {
-			// Setup the logger with the verbosity level and file output if specified
-			SetupZapLogger(args.Verbose, args.LogFilePath)
+			// Setup the logger with the verbosity level and file output if specified, above the progress rows of the installs
+			if args.Output != OUTPUT_TEXT && args.Output != OUTPUT_JSON {
+				fmt.Fprintf(os.Stderr, "Error: invalid output format %q, expected %s or %s\n", args.Output, OUTPUT_TEXT, OUTPUT_JSON)
+				os.Exit(commands.EXIT_USAGE)
+			}
+			var display *progress.Display
+			if cmd.Annotations[PROGRESS_ANNOTATION] != "" && !args.NoProgress && args.Output == OUTPUT_TEXT && utils.IsTerminal(os.Stdout) && utils.IsTerminal(os.Stderr) {
+				display = progress.New(os.Stderr)
+				progress.Enable(display)
+			}
+			if err := SetupZapLogger(args.Verbose, args.LogFilePath, display); err != nil {
+				// Without logger, the error is printed as the invalid flags errors
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				fail(cmd, "", &commands.UsageError{Err: err})
+			}
 			zap.L().Debug("Verbose mode enabled")
+			zap.L().Debug("Detected environment", zap.Stringer("environment", utils.CurrentEnvironment()))
+			if args.ExportPath = strings.TrimSpace(args.ExportPath); args.ExportPath != "" {
+				utils.DistroboxExportPath = args.ExportPath
+			}
 		},
 	}
 
@@ -34,19 +77,26 @@ It helps you install packages on your distrobox and export them to your host sys
 		Long: `Setup the devbox by installing the minimum required packages.
 This command will install the necessary packages to get started with devbox.
 It installs the minimal required packages to start developing with devbox.`,
+		Annotations: map[string]string{PROGRESS_ANNOTATION: "true"},
 		Run: func(cmd *cobra.Command, commandArgs []string) {
 			errs := setup.SetupDevbox(&args.SharedCmdArgs)
-			if errs != nil {
-				zap.L().Fatal("Failed to setup devbox", zap.Errors("errors", errs))
-			}
+			finish(commands.NewResult(commandName(cmd), started, errs, nil), "Failed to setup devbox")
 		},
 	}
 
 	installCmd = &cobra.Command{
-		Use:   "install [--skip-ide] [--no-export] [--file <PATH>] [toolchain...]",
+		Use:   "install [--skip-ide] [--no-export] [--file <PATH>] [--locked] [toolchain...]",
 		Short: "Install a language toolchain or a package",
 		Long: `Install a language toolchain or a package.
-Supports installing language toolchains for Bash, Go, Rust, Python, Node, Kubernetes, Container, Java, GitLab, GitHub, C, C++`,
+Supports installing language toolchains for Bash, Go, Rust, Python, Node, Kubernetes, Container, Java, GitLab, GitHub, C, C++
+The --profile option selects the components of the toolchains: minimal (core system packages only),
+default (core packages and default components) or full (every component).
+A toolchain can select its own profile or components (e.g. python:minimal or golang:core,lint).
+A version can be selected for the java, python, node and golang toolchains (e.g. java@17 or python@3.12),
+it is installed side by side with the other versions and its binaries are exported with the version as suffix.
+With --locked, the packages are installed at the versions of the lock file written by devbox lock,
+and the toolchains of the lock file are installed when none is given.`,
+		Annotations: map[string]string{PROGRESS_ANNOTATION: "true"},
 		Run: func(cmd *cobra.Command, commandArgs []string) {
 			// Aggregate arguments
 			var allArgs []string
@@ -54,25 +104,311 @@ Supports installing language toolchains for Bash, Go, Rust, Python, Node, Kubern
 
 			args.InstallCmdFilePath = strings.TrimSpace(args.InstallCmdFilePath)
 
-			// If --file flag is provided, read the file and append its content line by line
+			// If --file flag is provided, read the file entries, with their qualifiers, options and includes
 			if args.InstallCmdFilePath != "" {
 				zap.L().Debug("Reading install packages file", zap.String("file", args.InstallCmdFilePath))
-				fileArgs, err := utils.ReadFileLines(args.InstallCmdFilePath)
+				specs, err := install.ReadInstallFile(args.InstallCmdFilePath)
+				if err != nil {
+					fail(cmd, "Failed to read install packages file", &commands.UsageError{Err: err})
+				}
+				for _, spec := range specs {
+					allArgs = append(allArgs, spec.String())
+				}
+			}
+
+			if args.Locked {
+				lockFile, err := commands.ReadLockFile(args.LockFilePath)
 				if err != nil {
-					zap.L().Fatal("Failed to read install packages file", zap.String("file", args.InstallCmdFilePath), zap.Error(err))
+					fail(cmd, "Failed to read lock file", &commands.UsageError{Err: err})
 				}
-				allArgs = append(allArgs, fileArgs...)
+				errs := lock.InstallLocked(&args.SharedCmdArgs, lockFile, allArgs)
+				finish(commands.NewResult(commandName(cmd), started, errs, nil), "Failed to install locked toolchains")
+				return
 			}
 
 			if len(allArgs) == 0 {
-				zap.L().Fatal("No toolchains or packages specified for installation. Use --file to specify a file or provide arguments directly.")
+				fail(cmd, "No toolchains or packages specified for installation. Use --file to specify a file or provide arguments directly.", commands.ErrNoToolchain)
 			}
 
 			// Call the install function with all arguments
-			err := install.InstallToolchains(&args.SharedCmdArgs, allArgs...)
+			errs := install.InstallToolchains(&args.SharedCmdArgs, allArgs...)
+			finish(commands.NewResult(commandName(cmd), started, errs, nil), "Failed to install toolchains")
+		},
+	}
+
+	upgradeCmd = &cobra.Command{
+		Use:   "upgrade [toolchain...]",
+		Short: "Upgrade the packages installed by the toolchains",
+		Long: `Upgrade the packages installed by the toolchains to their latest version.
+Without toolchains, the installed packages of every toolchain are upgraded.
+The system packages, the language packages and the VS Code extensions are upgraded, and the versions changes are reported.`,
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			changes, errs := upgrade.UpgradeToolchains(&args.SharedCmdArgs, commandArgs)
+			if args.Output == OUTPUT_JSON {
+				finish(commands.NewResult(commandName(cmd), started, errs, changes), "Failed to upgrade toolchains")
+				return
+			}
+
+			upgraded := 0
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PACKAGE MANAGER\tPACKAGE\tBEFORE\tAFTER")
+			for _, change := range changes {
+				if !change.Changed() {
+					continue
+				}
+				upgraded++
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", change.PackageManager, change.Package, valueOrDash(change.Before), valueOrDash(change.After))
+			}
+			w.Flush()
+			fmt.Printf("%d packages upgraded, %d already up to date\n", upgraded, len(changes)-upgraded)
+			finish(commands.NewResult(commandName(cmd), started, errs, changes), "Failed to upgrade toolchains")
+		},
+	}
+
+	syncCmd = &cobra.Command{
+		Use:   "sync [--manifest <PATH>] [--dry-run]",
+		Short: "Install what the project devbox.yaml manifest requires",
+		Long: `Install what the project devbox.yaml manifest requires.
+The manifest is searched in the current directory and its parents, it declares the toolchains,
+the extra packages per package manager, the VS Code extensions and settings and the environment variables of the project.
+Only the toolchains and packages missing on the machine are installed.`,
+		Annotations: map[string]string{PROGRESS_ANNOTATION: "true"},
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			manifestFile := args.SyncManifestFile
+			if manifestFile == "" {
+				cwd, err := os.Getwd()
+				if err != nil {
+					fail(cmd, "Failed to get the current directory", err)
+				}
+				if manifestFile, err = project.FindManifest(cwd); err != nil {
+					fail(cmd, "Failed to find the project manifest", &commands.UsageError{Err: err})
+				}
+			}
+			manifest, err := project.ReadManifest(manifestFile)
+			if err != nil {
+				fail(cmd, "Failed to read the project manifest", &commands.UsageError{Err: err})
+			}
+
+			if args.SyncDryRun {
+				plan, errs := project.PlanSync(&args.SharedCmdArgs, manifest)
+				if args.Output == OUTPUT_TEXT && errs == nil {
+					if plan.IsEmpty() {
+						fmt.Println("Nothing to install")
+					}
+					for _, toolchain := range plan.Toolchains {
+						fmt.Printf("toolchain %s\n", toolchain)
+					}
+					for _, pm := range plan.PackageManagers() {
+						fmt.Printf("%s %s\n", pm.Name, strings.Join(plan.Packages[pm], " "))
+					}
+				}
+				finish(commands.NewResult(commandName(cmd), started, errs, plan), "Failed to compute the missing packages")
+				return
+			}
+
+			errs := project.Sync(&args.SharedCmdArgs, manifest)
+			finish(commands.NewResult(commandName(cmd), started, errs, nil), "Failed to sync the project manifest "+manifest.File)
+		},
+	}
+
+	lockCmd = &cobra.Command{
+		Use:   "lock [--lock-file <PATH>] [toolchain...]",
+		Short: "Write the installed versions of the toolchains packages to a lock file",
+		Long: `Write the installed versions of the toolchains packages to a lock file.
+The system packages, the language packages and the VS Code extensions versions are locked,
+so devbox install --locked installs the same versions on every machine.
+Without toolchains, the toolchains of the existing lock file are locked again.`,
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			if len(commandArgs) == 0 {
+				lockFile, err := commands.ReadLockFile(args.LockFilePath)
+				if err != nil {
+					fail(cmd, "No toolchains specified and no existing lock file", &commands.UsageError{Err: err})
+				}
+				commandArgs = lockFile.Toolchains
+			}
+
+			lockFile, errs := lock.LockToolchains(&args.SharedCmdArgs, commandArgs)
+			if errs != nil {
+				fail(cmd, "Failed to lock toolchains", errs...)
+			}
+			if err := lockFile.Write(args.LockFilePath); err != nil {
+				fail(cmd, "Failed to write lock file", err)
+			}
+			zap.L().Info("Lock file written", zap.String("file", args.LockFilePath), zap.Strings("toolchains", lockFile.Toolchains))
+			finish(commands.NewResult(commandName(cmd), started, nil, lockFile), "")
+		},
+	}
+
+	imageCmd = &cobra.Command{
+		Use:   "image",
+		Short: "Build container images with devbox toolchains baked in",
+		Long: `Build container images with devbox toolchains baked in.
+The generated images contain the devbox binary and the packages of the selected toolchains,
+so running devbox setup or install at first entry only exports the binaries and installs the IDE tools.`,
+	}
+
+	imageBuildFileCmd = &cobra.Command{
+		Use:   "build-file [--base-image <IMAGE>] [--binary <PATH>] [--file <PATH>] [--package-manager <NAME>] toolchain...",
+		Short: "Generate a Containerfile with the toolchains baked in",
+		Long: `Generate a Containerfile starting from the base image, copying the devbox binary and installing
+the system and language packages of the given toolchains in cache-friendly layers.
+Build it with: podman build -t <name> -f Containerfile .`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			var data any
+			err := image.WriteContainerfile(&args.ImageBuildFileOptions, commandArgs...)
+			if err == nil {
+				data = map[string]string{"file": args.ImageBuildFileOptions.OutputPath}
+			}
+			finish(commands.NewResult(commandName(cmd), started, []error{err}, data), "Failed to generate Containerfile")
+		},
+	}
+
+	boxCmd = &cobra.Command{
+		Use:   "box",
+		Short: "Manage devbox distroboxes from the host",
+		Long: `Manage devbox distroboxes from the host.
+Boxes are declared in a distrobox assemble manifest, their init hooks install the toolchains
+and their toolchains binaries are exported to the host.`,
+	}
+
+	boxCreateCmd = &cobra.Command{
+		Use:   "create --name <NAME> [--image <IMAGE>] [--toolchains <toolchain,...>] [--replace]",
+		Short: "Declare a box in the assemble manifest and create it",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			err := box.CreateBox(args.BoxManifestFile, &args.BoxCreateOptions)
+			finish(commands.NewResult(commandName(cmd), started, []error{err}, nil), "Failed to create box "+args.BoxCreateOptions.Name)
+		},
+	}
+
+	boxListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the boxes declared in the assemble manifest",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			boxes, err := box.ListBoxes(args.BoxManifestFile)
 			if err != nil {
-				zap.L().Fatal("Failed to install toolchains", zap.Errors("errors", err))
+				fail(cmd, "Failed to list boxes", err)
+			}
+			if args.Output == OUTPUT_TEXT {
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "NAME\tSTATUS\tIMAGE")
+				for _, b := range boxes {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", b.Name, b.Status, b.Image)
+				}
+				w.Flush()
+			}
+			finish(commands.NewResult(commandName(cmd), started, nil, boxes), "")
+		},
+	}
+
+	boxRemoveCmd = &cobra.Command{
+		Use:   "rm <NAME>",
+		Short: "Remove a box and delete it from the assemble manifest",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			err := box.RemoveBox(args.BoxManifestFile, commandArgs[0])
+			finish(commands.NewResult(commandName(cmd), started, []error{err}, nil), "Failed to remove box "+commandArgs[0])
+		},
+	}
+
+	boxEnterCmd = &cobra.Command{
+		Use:   "enter <NAME>",
+		Short: "Open a shell inside a box",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			err := box.EnterBox(commandArgs[0])
+			finish(commands.NewResult(commandName(cmd), started, []error{err}, nil), "Failed to enter box "+commandArgs[0])
+		},
+	}
+
+	unexportCmd = &cobra.Command{
+		Use:   "unexport <bin|app>...",
+		Short: "Remove exported binaries or applications from the host system",
+		Long: `Remove exported binaries or applications from the host system.
+Each argument is the name or the path of an exported binary, or the name of an exported application.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			requireDistrobox(cmd)
+			errs := utils.UnexportDistroboxExports(commandArgs)
+			finish(commands.NewResult(commandName(cmd), started, errs, nil), "Failed to unexport")
+		},
+	}
+
+	reexportCmd = &cobra.Command{
+		Use:   "reexport [--all]",
+		Short: "Repair the exported binaries and applications",
+		Long: `Repair the exported binaries and applications.
+By default, only the binaries whose path in the distrobox changed are exported again.
+With --all, every exported binary and application is deleted and exported again.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			requireDistrobox(cmd)
+			var errs []error
+			if args.ReexportAll {
+				errs = utils.ReexportDistroboxExports()
+			} else {
+				errs = utils.RepairDistroboxBinaries()
+			}
+			finish(commands.NewResult(commandName(cmd), started, errs, nil), "Failed to re-export")
+		},
+	}
+
+	listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the installable toolchains",
+		Long: `List the installable toolchains, their selectable versions and their components.
+The components installed by default are marked with a *.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			toolchains := install.ListToolchains()
+			if args.Output == OUTPUT_TEXT {
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "TOOLCHAIN\tVERSIONS\tCOMPONENTS\tDESCRIPTION")
+				for _, tc := range toolchains {
+					components := make([]string, len(tc.Components))
+					for i, component := range tc.Components {
+						components[i] = component.Name
+						if component.Default {
+							components[i] += "*"
+						}
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tc.Name, strings.Join(tc.Versions, " "), strings.Join(components, " "), tc.Description)
+				}
+				w.Flush()
 			}
+			finish(commands.NewResult(commandName(cmd), started, nil, toolchains), "")
+		},
+	}
+
+	doctorCmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the devbox environment health",
+		Long: `Check the devbox environment health.
+Checks the environment, the system package manager, distrobox-export, the env file and its shell integration,
+the VS Code settings, the toolchains package managers and the exported binaries and applications.
+Exits with the environment exit code (4) if at least one check fails.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, commandArgs []string) {
+			// --json is the deprecated alias of --output json
+			if args.DoctorJSON {
+				args.Output = OUTPUT_JSON
+			}
+			results := doctor.RunChecks()
+			if args.Output == OUTPUT_TEXT {
+				for _, result := range results {
+					fmt.Printf("[%s] %s: %s\n", strings.ToUpper(result.Status), result.Name, result.Message)
+					if result.Hint != "" {
+						fmt.Printf("       hint: %s\n", result.Hint)
+					}
+				}
+			}
+			result := commands.NewResult(commandName(cmd), started, nil, results)
+			if doctor.HasFailures(results) {
+				result.Status, result.ExitCode = commands.STATUS_FAILED, commands.EXIT_ENVIRONMENT
+			}
+			finish(result, "")
 		},
 	}
 
@@ -84,16 +420,134 @@ This command will install the package in the distrobox if it is not already inst
 	}
 )
 
+// valueOrDash returns the value, or a dash if it is empty
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// finish stops the progress display and reports the result of the command, then exits with its exit code when it failed.
+// With --output json, the result is printed as JSON. Otherwise the package manager commands which needed retries
+// are printed and the errors are logged with the message.
+func finish(result *commands.Result, message string) {
+	progress.Stop()
+	if args.Output == OUTPUT_JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			zap.L().Error("Failed to encode the result", zap.Error(err))
+			os.Exit(commands.EXIT_FAILURE)
+		}
+	} else {
+		reportRetries(result.Retries)
+		if len(result.Errors) > 0 {
+			errs := make([]string, len(result.Errors))
+			for i, err := range result.Errors {
+				errs[i] = err.Message
+			}
+			zap.L().Error(message, zap.Strings("errors", errs), zap.Int("exit_code", result.ExitCode))
+		}
+	}
+	if result.ExitCode != commands.EXIT_SUCCESS {
+		os.Exit(result.ExitCode)
+	}
+}
+
+// fail reports the errors which stopped the command and exits
+func fail(cmd *cobra.Command, message string, errs ...error) {
+	finish(commands.NewResult(commandName(cmd), started, errs, nil), message)
+}
+
+// requestedOutput returns the --output value of the command line arguments, which are not parsed after an invalid flag
+func requestedOutput(arguments []string) string {
+	for i, argument := range arguments {
+		if value, found := strings.CutPrefix(argument, "--output="); found {
+			return value
+		}
+		if argument == "--output" && i+1 < len(arguments) {
+			return arguments[i+1]
+		}
+	}
+	return ""
+}
+
+// commandName returns the name of the command reported in its result, without the devbox prefix (e.g. "box list")
+func commandName(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
+
+// reportRetries prints the package manager commands which needed retries, if any
+func reportRetries(retries []*packagemanager.Retry) {
+	if len(retries) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE MANAGER\tPACKAGES\tATTEMPTS\tRESULT")
+	for _, retry := range retries {
+		result := "failed"
+		if retry.Succeeded {
+			result = "succeeded"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", retry.PackageManager, strings.Join(retry.Packages, " "), retry.Attempts, result)
+	}
+	w.Flush()
+}
+
+// requireDistrobox fails the command if devbox is not running inside a distrobox, as exports are only possible from a distrobox
+func requireDistrobox(cmd *cobra.Command) {
+	if env := utils.CurrentEnvironment(); !env.Distrobox {
+		fail(cmd, "This command must be run inside a distrobox", fmt.Errorf("%w, detected environment: %s", utils.ErrDistroboxNotAvailable, env))
+	}
+}
+
 func main() {
-	installCmd.Flags().StringVar(&args.InstallCmdFilePath, "file", "", "Path to a file containing a list of languages toolchains to install, one per line")
+	installCmd.Flags().StringVar(&args.InstallCmdFilePath, "file", "", "Path to a file containing a list of languages toolchains to install, one per line, or - to read the standard input")
+	installCmd.Flags().BoolVar(&args.Locked, "locked", false, "Install the packages at the versions of the lock file")
+	installCmd.Flags().StringVar(&args.LockFilePath, "lock-file", commands.DEFAULT_LOCK_FILE, "Path to the lock file")
+
+	syncCmd.Flags().StringVar(&args.SyncManifestFile, "manifest", "", "Path to the project manifest (default devbox.yaml in the current directory or its parents)")
+	syncCmd.Flags().BoolVar(&args.SyncDryRun, "dry-run", false, "Print the missing toolchains and packages without installing them")
+
+	lockCmd.Flags().StringVar(&args.LockFilePath, "lock-file", commands.DEFAULT_LOCK_FILE, "Path of the written lock file")
+
+	imageBuildFileCmd.Flags().StringVar(&args.ImageBuildFileOptions.BaseImage, "base-image", image.DEFAULT_BASE_IMAGE, "Base image of the generated Containerfile")
+	imageBuildFileCmd.Flags().StringVar(&args.ImageBuildFileOptions.BinaryPath, "binary", image.DEFAULT_BINARY_PATH, "Path of the devbox binary in the build context")
+	imageBuildFileCmd.Flags().StringVarP(&args.ImageBuildFileOptions.OutputPath, "file", "f", image.DEFAULT_OUTPUT_FILE, "Path of the generated Containerfile")
+	imageBuildFileCmd.Flags().StringVar(&args.ImageBuildFileOptions.PackageManager, "package-manager", image.DEFAULT_SYSTEM_PACKAGE_MANAGER, "System package manager of the base image")
+	imageCmd.AddCommand(imageBuildFileCmd)
+
+	boxCmd.PersistentFlags().StringVar(&args.BoxManifestFile, "manifest", box.DEFAULT_MANIFEST_FILE, "Path to the distrobox assemble manifest")
+	boxCreateCmd.Flags().StringVar(&args.BoxCreateOptions.Name, "name", "", "Name of the box")
+	boxCreateCmd.Flags().StringVar(&args.BoxCreateOptions.Image, "image", box.DEFAULT_BOX_IMAGE, "Image of the box, it must ship the devbox binary")
+	boxCreateCmd.Flags().StringSliceVar(&args.BoxCreateOptions.Toolchains, "toolchains", nil, "Comma separated list of toolchains to install in the box")
+	boxCreateCmd.Flags().BoolVar(&args.BoxCreateOptions.Replace, "replace", false, "Replace the box if it already exists")
+	_ = boxCreateCmd.MarkFlagRequired("name")
+	boxCmd.AddCommand(boxCreateCmd, boxListCmd, boxRemoveCmd, boxEnterCmd)
+
+	doctorCmd.Flags().BoolVar(&args.DoctorJSON, "json", false, "Print the checks results as JSON, same as --output json")
+	_ = doctorCmd.Flags().MarkDeprecated("json", "use --output json instead")
+
+	reexportCmd.Flags().BoolVar(&args.ReexportAll, "all", false, "Delete and export again every exported binary and application")
 
 	mainCmd.PersistentFlags().BoolVarP(&args.SkipIde, "skip-ide", "n", false, "Skip IDE installation")
 	mainCmd.PersistentFlags().BoolVarP(&args.Verbose, "verbose", "v", false, "Enable verbose output")
+	mainCmd.PersistentFlags().StringVar(&args.Output, "output", OUTPUT_TEXT, "Output format of the results: text or json")
+	mainCmd.PersistentFlags().BoolVar(&args.NoProgress, "no-progress", false, "Print the logs instead of the live progress of the installs on a terminal")
 	mainCmd.PersistentFlags().StringVarP(&args.LogFilePath, "log-file", "l", "", "Path to the log file")
 	mainCmd.PersistentFlags().BoolVar(&args.NoExport, "no-export", false, "Do not export the package to the host system")
+	mainCmd.PersistentFlags().StringVar(&args.ExportPath, "export-path", "", "Host directory receiving the exported binaries (default ~/.local/bin)")
+	mainCmd.PersistentFlags().BoolVar(&args.UserScope, "user-scope", false, "Only install user-scoped packages, skipping the system packages requiring root privileges")
+	mainCmd.PersistentFlags().StringVar(&args.Profile, "profile", commands.PROFILE_DEFAULT, "Components of the toolchains to install: minimal, default or full")
 
-	mainCmd.AddCommand(setupCmd, installCmd, sharePackageCmd)
-	if err := mainCmd.Execute(); err != nil {
-		zap.L().Fatal("devbox runtime error", zap.Error(err))
+	mainCmd.AddCommand(setupCmd, installCmd, listCmd, syncCmd, upgradeCmd, lockCmd, unexportCmd, reexportCmd, imageCmd, boxCmd, doctorCmd, sharePackageCmd)
+	// The Run functions exit by themselves, the remaining errors are the invalid flags and arguments, already printed
+	if cmd, err := mainCmd.ExecuteC(); err != nil {
+		if args.Output == OUTPUT_JSON || requestedOutput(os.Args[1:]) == OUTPUT_JSON {
+			args.Output = OUTPUT_JSON
+			fail(cmd, "", &commands.UsageError{Err: err})
+		}
+		os.Exit(commands.EXIT_USAGE)
 	}
 }
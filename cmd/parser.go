@@ -1,10 +1,28 @@
 package main
 
-import "devbox/internal/commands"
+import (
+	"devbox/internal/commands"
+	"devbox/internal/commands/box"
+	"devbox/internal/commands/image"
+)
 
 type ParserArgs struct {
 	commands.SharedCmdArgs
 	Verbose            bool
+	NoProgress         bool
+	Output             string
 	InstallCmdFilePath string
 	LogFilePath        string
+	ExportPath         string
+	ReexportAll        bool
+	DoctorJSON         bool
+	Locked             bool
+	LockFilePath       string
+	SyncManifestFile   string
+	SyncDryRun         bool
+
+	ImageBuildFileOptions image.BuildFileOptions
+
+	BoxManifestFile  string
+	BoxCreateOptions box.CreateOptions
 }
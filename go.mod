@@ -5,6 +5,7 @@ go 1.24.6
 require (
 	github.com/spf13/cobra v1.9.1
 	go.uber.org/zap v1.27.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,223 @@
+package box
+
+import (
+	"devbox/internal/commands"
+	"devbox/internal/commands/install"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DISTROBOX_COMMAND is the command used to manage the distroboxes from the host
+	DISTROBOX_COMMAND = "distrobox"
+
+	// DISTROBOX_NOT_FOUND_ERROR is returned when the distrobox command is not available on the host
+	DISTROBOX_NOT_FOUND_ERROR = "distrobox command is not available, please install it on your host system"
+)
+
+var (
+	// ErrDistroboxNotFound is returned when the distrobox command is not available on the host
+	ErrDistroboxNotFound = &commands.EnvironmentError{Err: errors.New(DISTROBOX_NOT_FOUND_ERROR)}
+
+	// DEFAULT_BOX_IMAGE is the default image of the boxes created by devbox, it ships the devbox binary
+	DEFAULT_BOX_IMAGE = "ghcr.io/boxboxjason/devbox:latest"
+
+	// BOX_BINARIES_DIR is the directory of the toolchains exported binaries inside the box
+	BOX_BINARIES_DIR = "/usr/bin"
+)
+
+// CreateOptions contains the options used to create a box
+type CreateOptions struct {
+	Name       string
+	Image      string
+	Toolchains []string
+	Replace    bool
+}
+
+// Box is a distrobox known by devbox, either declared in the manifest or created
+type Box struct {
+	Name   string
+	Image  string
+	Status string
+}
+
+// CreateBox declares the box in the assemble manifest and creates it with distrobox assemble.
+// The box init hooks install the toolchains inside the box, and the toolchains binaries are exported to the host.
+func CreateBox(manifestFile string, opts *CreateOptions) error {
+	if strings.TrimSpace(opts.Name) == "" {
+		return &commands.UsageError{Err: errors.New("box name is required")}
+	}
+	specs, toolchains, err := install.ParseMergedToolchainSpecs(opts.Toolchains, "")
+	if err != nil {
+		return &commands.UsageError{Err: err}
+	}
+
+	manifest, err := ReadManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	if manifest.Section(opts.Name) != nil && !opts.Replace {
+		return fmt.Errorf("box %s already exists in manifest %s, use --replace to recreate it", opts.Name, manifestFile)
+	}
+
+	section := &ManifestSection{Name: opts.Name}
+	section.Add("image", opts.Image)
+	section.Add("pull", "true")
+	section.Add("replace", fmt.Sprintf("%t", opts.Replace))
+
+	toolchainNames := make([]string, len(toolchains))
+	toolchainSpecs := make([]string, len(specs))
+	var exportedBinaries []string
+	for i, tc := range toolchains {
+		toolchainNames[i] = tc.Name
+		// The specs with options are quoted to reach devbox install as a single argument
+		toolchainSpecs[i] = specs[i].String()
+		if strings.Contains(toolchainSpecs[i], " ") {
+			toolchainSpecs[i] = "'" + toolchainSpecs[i] + "'"
+		}
+		exportedBinaries = append(exportedBinaries, boxExportedBinaries(tc)...)
+	}
+	// Init hooks run as root during the box initialization: binaries are exported by distrobox assemble
+	// and IDE tools must be installed from a user session, with devbox install
+	section.Add("init_hooks", fmt.Sprintf("devbox install --no-export --skip-ide %s", strings.Join(toolchainSpecs, " ")))
+	if len(exportedBinaries) > 0 {
+		section.Add("exported_bins", strings.Join(exportedBinaries, " "))
+	}
+
+	manifest.SetSection(section)
+	zap.L().Info("Writing box to assemble manifest", zap.String("box", opts.Name), zap.String("file", manifestFile), zap.Strings("toolchains", toolchainNames))
+	if err := manifest.Write(); err != nil {
+		return err
+	}
+
+	zap.L().Info("Creating box", zap.String("box", opts.Name), zap.String("image", opts.Image))
+	return runDistrobox("assemble", "create", "--file", manifestFile, "--name", opts.Name)
+}
+
+// boxExportedBinaries returns the paths of the toolchain binaries exported by distrobox assemble: the binaries installed
+// by the package managers outside of the PATH (e.g. the downloads in DOWNLOAD_BIN_DIR) at the paths they are installed at,
+// and the other exported binaries in BOX_BINARIES_DIR. Nothing is exported for the toolchains installed with --no-export.
+func boxExportedBinaries(tc *commands.Toolchain) []string {
+	var installedBinaries []string
+	if tc.PackageManagers != nil && !tc.NoExport {
+		for pm, packages := range *tc.PackageManagers {
+			if pm.InstalledBinaries != nil {
+				installedBinaries = append(installedBinaries, pm.InstalledBinaries(pm, packages)...)
+			}
+		}
+	}
+	slices.Sort(installedBinaries)
+
+	exportedBinaries := make([]string, 0, len(tc.ExportedBinaries)+len(installedBinaries))
+	for _, binary := range tc.ExportedBinaries {
+		exportedBinaries = append(exportedBinaries, filepath.Join(BOX_BINARIES_DIR, binary))
+	}
+	for _, binary := range installedBinaries {
+		exportedBinaries = slices.DeleteFunc(exportedBinaries, func(exported string) bool {
+			return filepath.Base(exported) == filepath.Base(binary)
+		})
+	}
+	return append(exportedBinaries, installedBinaries...)
+}
+
+// ListBoxes returns the boxes declared in the manifest, with their status reported by distrobox.
+func ListBoxes(manifestFile string) ([]*Box, error) {
+	manifest, err := ReadManifest(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]string)
+	output, err := outputDistrobox("list", "--no-color")
+	if err != nil {
+		return nil, err
+	}
+	// distrobox list prints a table: ID | NAME | STATUS | IMAGE
+	for _, line := range strings.Split(output, "\n")[1:] {
+		columns := strings.Split(line, "|")
+		if len(columns) >= 3 {
+			statuses[strings.TrimSpace(columns[1])] = strings.TrimSpace(columns[2])
+		}
+	}
+
+	boxes := make([]*Box, len(manifest.Sections))
+	for i, section := range manifest.Sections {
+		status, exists := statuses[section.Name]
+		if !exists {
+			status = "Not created"
+		}
+		boxes[i] = &Box{Name: section.Name, Image: section.Get("image"), Status: status}
+	}
+	return boxes, nil
+}
+
+// RemoveBox removes the box with distrobox assemble and deletes it from the manifest.
+func RemoveBox(manifestFile string, name string) error {
+	manifest, err := ReadManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	if manifest.Section(name) == nil {
+		return fmt.Errorf("box %s is not declared in manifest %s", name, manifestFile)
+	}
+
+	zap.L().Info("Removing box", zap.String("box", name))
+	if err := runDistrobox("assemble", "rm", "--file", manifestFile, "--name", name); err != nil {
+		return err
+	}
+
+	manifest.RemoveSection(name)
+	return manifest.Write()
+}
+
+// EnterBox opens an interactive shell inside the box.
+func EnterBox(name string) error {
+	if _, err := exec.LookPath(DISTROBOX_COMMAND); err != nil {
+		return ErrDistroboxNotFound
+	}
+	cmd := exec.Command(DISTROBOX_COMMAND, "enter", name)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	zap.L().Debug("Running command", zap.String("command", cmd.String()))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to enter box %s: %w", name, err)
+	}
+	return nil
+}
+
+// runDistrobox runs the distrobox command, forwarding its output to the user
+func runDistrobox(args ...string) error {
+	if _, err := exec.LookPath(DISTROBOX_COMMAND); err != nil {
+		return ErrDistroboxNotFound
+	}
+	cmd := exec.Command(DISTROBOX_COMMAND, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	zap.L().Debug("Running command", zap.String("command", cmd.String()))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", cmd.String(), err)
+	}
+	return nil
+}
+
+// outputDistrobox runs the distrobox command and returns its output
+func outputDistrobox(args ...string) (string, error) {
+	if _, err := exec.LookPath(DISTROBOX_COMMAND); err != nil {
+		return "", ErrDistroboxNotFound
+	}
+	cmd := exec.Command(DISTROBOX_COMMAND, args...)
+	zap.L().Debug("Running command", zap.String("command", cmd.String()))
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", cmd.String(), err)
+	}
+	return string(output), nil
+}
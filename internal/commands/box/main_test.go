@@ -0,0 +1,245 @@
+package box
+
+import (
+	"devbox/internal/commands"
+	"devbox/pkg/packagemanager"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	// avoid zap global logger side effects
+	zap.ReplaceGlobals(zap.NewNop())
+	os.Exit(m.Run())
+}
+
+// stubDistrobox writes a distrobox script recording its arguments in the returned log file.
+// The script prints listOutput when called with "list".
+func stubDistrobox(t *testing.T, listOutput string, exitCode int) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping distrobox script tests on Windows")
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "calls.log")
+	body := `#!/bin/sh
+echo "$@" >> "` + logFile + `"
+if [ "$1" = "list" ]; then
+	cat <<'EOF'
+` + listOutput + `
+EOF
+fi
+exit ` + strconv.Itoa(exitCode) + `
+`
+	if err := os.WriteFile(filepath.Join(dir, "distrobox"), []byte(body), 0o700); err != nil {
+		t.Fatalf("failed to write distrobox script: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logFile
+}
+
+func readCalls(t *testing.T, logFile string) []string {
+	t.Helper()
+	data, err := os.ReadFile(logFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatalf("failed to read calls log: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func Test_CreateBox(t *testing.T) {
+	logFile := stubDistrobox(t, "", 0)
+	manifestFile := filepath.Join(t.TempDir(), "devbox", "devbox.ini")
+
+	err := CreateBox(manifestFile, &CreateOptions{Name: "go-dev", Image: "example.com/devbox:latest", Toolchains: []string{"golang", "github"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls := readCalls(t, logFile)
+	want := "assemble create --file " + manifestFile + " --name go-dev"
+	if len(calls) != 1 || calls[0] != want {
+		t.Fatalf("expected distrobox to be called with %q, got: %v", want, calls)
+	}
+
+	manifest, err := ReadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	section := manifest.Section("go-dev")
+	if section == nil {
+		t.Fatalf("expected go-dev section in manifest")
+	}
+	if got := section.Get("image"); got != "example.com/devbox:latest" {
+		t.Fatalf("unexpected image: %q", got)
+	}
+	if got := section.Get("init_hooks"); got != "devbox install --no-export --skip-ide golang github" {
+		t.Fatalf("unexpected init_hooks: %q", got)
+	}
+	if got := section.Get("exported_bins"); got != "/usr/bin/go /usr/bin/make /usr/bin/gofmt /usr/bin/gh /usr/bin/hub /usr/bin/git-lfs" {
+		t.Fatalf("unexpected exported_bins: %q", got)
+	}
+
+	// Creating the same box again requires --replace
+	err = CreateBox(manifestFile, &CreateOptions{Name: "go-dev", Image: "example.com/devbox:latest", Toolchains: []string{"golang"}})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected already exists error, got: %v", err)
+	}
+	err = CreateBox(manifestFile, &CreateOptions{Name: "go-dev", Image: "example.com/devbox:latest", Toolchains: []string{"golang"}, Replace: true})
+	if err != nil {
+		t.Fatalf("unexpected error on replace: %v", err)
+	}
+	manifest, _ = ReadManifest(manifestFile)
+	if len(manifest.Sections) != 1 || manifest.Section("go-dev").Get("replace") != "true" {
+		t.Fatalf("expected the section to be replaced, got: %+v", manifest.Sections)
+	}
+}
+
+func Test_CreateBox_Specs(t *testing.T) {
+	stubDistrobox(t, "", 0)
+	manifestFile := filepath.Join(t.TempDir(), "devbox.ini")
+
+	err := CreateBox(manifestFile, &CreateOptions{Name: "java-dev", Image: "example.com/devbox:latest", Toolchains: []string{"java --no-ide", "bash"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manifest, err := ReadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	section := manifest.Section("java-dev")
+	if got := section.Get("init_hooks"); got != "devbox install --no-export --skip-ide 'java --no-ide' bash" {
+		t.Fatalf("unexpected init_hooks: %q", got)
+	}
+	exportedBinaries := strings.Fields(section.Get("exported_bins"))
+	for _, want := range []string{"/usr/bin/javac", filepath.Join(packagemanager.DOWNLOAD_BIN_DIR, "checkstyle"), filepath.Join(packagemanager.DOWNLOAD_BIN_DIR, "spotbugs"), "/usr/bin/shellcheck"} {
+		if !slices.Contains(exportedBinaries, want) {
+			t.Fatalf("expected %s in exported_bins, got: %q", want, exportedBinaries)
+		}
+	}
+	if slices.Contains(exportedBinaries, "/usr/bin/checkstyle") {
+		t.Fatalf("expected the downloaded binaries to be exported from %s, got: %q", packagemanager.DOWNLOAD_BIN_DIR, exportedBinaries)
+	}
+
+	err = CreateBox(manifestFile, &CreateOptions{Name: "java-dev", Image: "example.com/devbox:latest", Toolchains: []string{"java --no-export"}, Replace: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manifest, _ = ReadManifest(manifestFile)
+	if got := manifest.Section("java-dev").Get("exported_bins"); got != "" {
+		t.Fatalf("expected no exported binaries with --no-export, got: %q", got)
+	}
+}
+
+func Test_CreateBox_Errors(t *testing.T) {
+	manifestFile := filepath.Join(t.TempDir(), "devbox.ini")
+	tests := []struct {
+		name            string
+		opts            *CreateOptions
+		wantErrContains string
+	}{
+		{"missing name", &CreateOptions{Image: "img", Toolchains: []string{"golang"}}, "box name is required"},
+		{"unknown toolchain", &CreateOptions{Name: "box", Image: "img", Toolchains: []string{"cobol"}}, "unknown toolchain: cobol"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CreateBox(manifestFile, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, err)
+			}
+		})
+	}
+	if _, err := os.Stat(manifestFile); !os.IsNotExist(err) {
+		t.Fatalf("expected manifest not to be written on error")
+	}
+}
+
+func Test_CreateBox_DistroboxFails(t *testing.T) {
+	stubDistrobox(t, "", 1)
+	manifestFile := filepath.Join(t.TempDir(), "devbox.ini")
+	err := CreateBox(manifestFile, &CreateOptions{Name: "box", Image: "img", Toolchains: []string{"bash"}})
+	if err == nil || !strings.Contains(err.Error(), "failed to run") {
+		t.Fatalf("expected distrobox failure, got: %v", err)
+	}
+}
+
+func Test_ListBoxes(t *testing.T) {
+	stubDistrobox(t, `ID           | NAME                 | STATUS             | IMAGE
+4f2a1b3c5d6e | go-dev               | Up 2 hours         | example.com/devbox:latest
+9a8b7c6d5e4f | other                | Exited (0) 1 day   | fedora:latest`, 0)
+	manifestFile := filepath.Join(t.TempDir(), "devbox.ini")
+	manifest := &Manifest{File: manifestFile}
+	manifest.SetSection(&ManifestSection{Name: "go-dev", Entries: []ManifestEntry{{"image", "example.com/devbox:latest"}}})
+	manifest.SetSection(&ManifestSection{Name: "rust-dev", Entries: []ManifestEntry{{"image", "example.com/devbox:rust"}}})
+	if err := manifest.Write(); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	boxes, err := ListBoxes(manifestFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(boxes) != 2 {
+		t.Fatalf("expected 2 boxes, got: %d", len(boxes))
+	}
+	if boxes[0].Name != "go-dev" || boxes[0].Status != "Up 2 hours" || boxes[0].Image != "example.com/devbox:latest" {
+		t.Fatalf("unexpected first box: %+v", boxes[0])
+	}
+	if boxes[1].Name != "rust-dev" || boxes[1].Status != "Not created" {
+		t.Fatalf("unexpected second box: %+v", boxes[1])
+	}
+}
+
+func Test_RemoveBox(t *testing.T) {
+	logFile := stubDistrobox(t, "", 0)
+	manifestFile := filepath.Join(t.TempDir(), "devbox.ini")
+	manifest := &Manifest{File: manifestFile}
+	manifest.SetSection(&ManifestSection{Name: "go-dev", Entries: []ManifestEntry{{"image", "img"}}})
+	manifest.SetSection(&ManifestSection{Name: "rust-dev", Entries: []ManifestEntry{{"image", "img"}}})
+	if err := manifest.Write(); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	if err := RemoveBox(manifestFile, "go-dev"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	calls := readCalls(t, logFile)
+	want := "assemble rm --file " + manifestFile + " --name go-dev"
+	if len(calls) != 1 || calls[0] != want {
+		t.Fatalf("expected distrobox to be called with %q, got: %v", want, calls)
+	}
+	manifest, _ = ReadManifest(manifestFile)
+	if manifest.Section("go-dev") != nil || manifest.Section("rust-dev") == nil {
+		t.Fatalf("expected only go-dev to be removed from manifest, got: %+v", manifest.Sections)
+	}
+
+	if err := RemoveBox(manifestFile, "unknown"); err == nil || !strings.Contains(err.Error(), "is not declared") {
+		t.Fatalf("expected not declared error, got: %v", err)
+	}
+}
+
+func Test_EnterBox(t *testing.T) {
+	logFile := stubDistrobox(t, "", 0)
+	if err := EnterBox("go-dev"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := readCalls(t, logFile); len(calls) != 1 || calls[0] != "enter go-dev" {
+		t.Fatalf("unexpected distrobox calls: %v", calls)
+	}
+}
+
+func Test_DistroboxNotAvailable(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if err := EnterBox("go-dev"); err == nil || err.Error() != DISTROBOX_NOT_FOUND_ERROR || !commands.IsEnvironmentError(err) {
+		t.Fatalf("expected distrobox not found error, got: %v", err)
+	}
+}
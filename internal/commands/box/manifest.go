@@ -0,0 +1,150 @@
+package box
+
+import (
+	"bufio"
+	"devbox/pkg/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// DEFAULT_MANIFEST_FILE is the distrobox assemble manifest holding the boxes created by devbox
+	DEFAULT_MANIFEST_FILE = utils.Getenv("DEVBOX_ASSEMBLE_FILE", filepath.Join(utils.Getenv("XDG_CONFIG_HOME", filepath.Join(os.Getenv("HOME"), ".config")), "devbox", "devbox.ini"))
+)
+
+// ManifestEntry is a key=value line of a manifest section, keys can be repeated
+type ManifestEntry struct {
+	Key   string
+	Value string
+}
+
+// ManifestSection is a box definition of the distrobox assemble manifest
+type ManifestSection struct {
+	Name    string
+	Entries []ManifestEntry
+}
+
+// Manifest is a distrobox assemble manifest (ini format)
+type Manifest struct {
+	File     string
+	Sections []*ManifestSection
+}
+
+// ReadManifest parses the distrobox assemble manifest file.
+// A missing file returns an empty manifest.
+func ReadManifest(file string) (*Manifest, error) {
+	manifest := &Manifest{File: file}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer f.Close()
+
+	var section *ManifestSection
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = &ManifestSection{Name: strings.TrimSpace(line[1 : len(line)-1])}
+			manifest.Sections = append(manifest.Sections, section)
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found || section == nil {
+			return nil, fmt.Errorf("%s:%d: invalid manifest line: %s", file, lineNumber, line)
+		}
+		section.Entries = append(section.Entries, ManifestEntry{
+			Key:   strings.TrimSpace(key),
+			Value: utils.TrimSpacesAndQuotes(value),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// Write writes the manifest to its file, creating the parent directories if needed.
+func (m *Manifest) Write() error {
+	if err := os.MkdirAll(filepath.Dir(m.File), 0700); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# distrobox assemble manifest generated by devbox, see https://distrobox.it/usage/distrobox-assemble/\n")
+	for _, section := range m.Sections {
+		fmt.Fprintf(&sb, "\n[%s]\n", section.Name)
+		for _, entry := range section.Entries {
+			fmt.Fprintf(&sb, "%s=%s\n", entry.Key, formatManifestValue(entry.Value))
+		}
+	}
+	if err := os.WriteFile(m.File, []byte(sb.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// Section returns the section with the given name, or nil if it does not exist.
+func (m *Manifest) Section(name string) *ManifestSection {
+	for _, section := range m.Sections {
+		if section.Name == name {
+			return section
+		}
+	}
+	return nil
+}
+
+// SetSection adds the section to the manifest, replacing any existing section with the same name.
+func (m *Manifest) SetSection(section *ManifestSection) {
+	for i, existing := range m.Sections {
+		if existing.Name == section.Name {
+			m.Sections[i] = section
+			return
+		}
+	}
+	m.Sections = append(m.Sections, section)
+}
+
+// RemoveSection removes the section with the given name and reports whether it existed.
+func (m *Manifest) RemoveSection(name string) bool {
+	for i, section := range m.Sections {
+		if section.Name == name {
+			m.Sections = append(m.Sections[:i], m.Sections[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the first value of the key in the section.
+func (s *ManifestSection) Get(key string) string {
+	for _, entry := range s.Entries {
+		if entry.Key == key {
+			return entry.Value
+		}
+	}
+	return ""
+}
+
+// Add appends a key=value entry to the section.
+func (s *ManifestSection) Add(key string, value string) {
+	s.Entries = append(s.Entries, ManifestEntry{Key: key, Value: value})
+}
+
+// formatManifestValue quotes the values containing spaces, as expected by distrobox assemble
+func formatManifestValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return strconv.Quote(value)
+	}
+	return value
+}
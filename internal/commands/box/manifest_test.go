@@ -0,0 +1,105 @@
+package box
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ReadManifest(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		want            []*ManifestSection
+		wantErrContains string
+	}{
+		{
+			name: "sections with comments, quotes and repeated keys",
+			content: `# comment
+; other comment
+[go-dev]
+image=example.com/devbox:latest
+init_hooks="devbox install golang"
+init_hooks = 'echo done'
+
+[rust-dev]
+image=example.com/devbox:rust
+`,
+			want: []*ManifestSection{
+				{Name: "go-dev", Entries: []ManifestEntry{{"image", "example.com/devbox:latest"}, {"init_hooks", "devbox install golang"}, {"init_hooks", "echo done"}}},
+				{Name: "rust-dev", Entries: []ManifestEntry{{"image", "example.com/devbox:rust"}}},
+			},
+		},
+		{
+			name:            "entry outside of a section",
+			content:         "image=example.com/devbox:latest\n",
+			wantErrContains: "devbox.ini:1: invalid manifest line",
+		},
+		{
+			name:            "line without value",
+			content:         "[go-dev]\nimage\n",
+			wantErrContains: "devbox.ini:2: invalid manifest line: image",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			file := filepath.Join(t.TempDir(), "devbox.ini")
+			if err := os.WriteFile(file, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("failed to write manifest: %v", err)
+			}
+			manifest, err := ReadManifest(file)
+			if tt.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(manifest.Sections, tt.want) {
+				t.Fatalf("ReadManifest() = %+v, want %+v", manifest.Sections, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing file returns an empty manifest", func(t *testing.T) {
+		t.Parallel()
+		manifest, err := ReadManifest(filepath.Join(t.TempDir(), "missing.ini"))
+		if err != nil || len(manifest.Sections) != 0 {
+			t.Fatalf("expected empty manifest, got: %+v, %v", manifest, err)
+		}
+	})
+}
+
+func Test_Manifest_WriteRoundTrip(t *testing.T) {
+	t.Parallel()
+	file := filepath.Join(t.TempDir(), "nested", "devbox.ini")
+	manifest := &Manifest{File: file}
+	section := &ManifestSection{Name: "go-dev"}
+	section.Add("image", "example.com/devbox:latest")
+	section.Add("init_hooks", "devbox install golang")
+	manifest.SetSection(section)
+	if err := manifest.Write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	if !strings.Contains(string(data), "[go-dev]\nimage=example.com/devbox:latest\ninit_hooks=\"devbox install golang\"\n") {
+		t.Fatalf("unexpected manifest content:\n%s", data)
+	}
+
+	read, err := ReadManifest(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(read.Sections, manifest.Sections) {
+		t.Fatalf("round trip mismatch: %+v != %+v", read.Sections, manifest.Sections)
+	}
+}
@@ -0,0 +1,314 @@
+package doctor
+
+import (
+	"devbox/internal/commands"
+	"devbox/internal/commands/install"
+	"devbox/internal/envmanager"
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/utils"
+	"devbox/pkg/vscode"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	STATUS_PASS = "pass"
+	STATUS_WARN = "warn"
+	STATUS_FAIL = "fail"
+)
+
+var (
+	// SHELL_RC_FILES are the shell startup files, relative to the home directory, expected to source the devbox env file
+	SHELL_RC_FILES = []string{".zshrc", ".zprofile", ".bashrc", ".bash_profile", ".profile"}
+)
+
+// CheckResult is the result of a devbox health check
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// RunChecks runs the devbox health checks and returns their results.
+func RunChecks() []*CheckResult {
+	settingsFiles, _ := vscode.SettingsFileCandidates()
+	results := []*CheckResult{
+		CheckEnvironment(),
+		CheckSystemPackageManager(packagemanager.SystemPackageManager),
+		CheckDistroboxExport(),
+		CheckEnvFile(envmanager.DEFAULT_SYS_ENV_FILE),
+		CheckEnvFileSourced(envmanager.DEFAULT_SYS_ENV_FILE, os.Getenv("HOME"), utils.Getenv("ZSH_CUSTOM", filepath.Join(os.Getenv("HOME"), ".oh-my-zsh", "custom"))),
+		CheckVSCodeSettings(settingsFiles),
+	}
+	results = append(results, CheckToolchainsPackageManagers(install.EXISTING_TOOLCHAINS)...)
+	return append(results, CheckExports()...)
+}
+
+// HasFailures reports whether at least one of the checks failed.
+func HasFailures(results []*CheckResult) bool {
+	return slices.ContainsFunc(results, func(result *CheckResult) bool {
+		return result.Status == STATUS_FAIL
+	})
+}
+
+// CheckEnvironment reports where devbox is running, exports to the host are only possible from inside a distrobox.
+func CheckEnvironment() *CheckResult {
+	env := utils.CurrentEnvironment()
+	result := &CheckResult{Name: "environment", Message: fmt.Sprintf("running in %s", env)}
+	switch {
+	case env.Distrobox:
+		result.Status = STATUS_PASS
+	case env.IsHost():
+		result.Status = STATUS_WARN
+		result.Hint = "exports are skipped on the host, run devbox inside a distrobox (see devbox box create) or use --user-scope to only install user-scoped packages"
+	default:
+		result.Status = STATUS_WARN
+		result.Hint = "exports are skipped outside of a distrobox, create the container with distrobox to export binaries to the host"
+	}
+	return result
+}
+
+// CheckSystemPackageManager reports the detected system package manager, and whether it can be run with the required privileges.
+func CheckSystemPackageManager(pm *packagemanager.PackageManager) *CheckResult {
+	result := &CheckResult{Name: "system-package-manager"}
+	if pm == nil {
+		result.Status = STATUS_FAIL
+		result.Message = "no supported system package manager found"
+		result.Hint = "devbox requires one of apt, dnf, microdnf, yum, apk, brew, pacman, zypper, port, nix-env, flatpak or snap"
+		return result
+	}
+	result.Message = fmt.Sprintf("using %s", pm.Name)
+	if pm.SudoRequired && os.Geteuid() != 0 {
+		if _, err := exec.LookPath("sudo"); err != nil {
+			result.Status = STATUS_FAIL
+			result.Message = fmt.Sprintf("%s requires root privileges but sudo is not available", pm.Name)
+			result.Hint = "install sudo in the container or use --user-scope to skip the system packages"
+			return result
+		}
+	}
+	result.Status = STATUS_PASS
+	return result
+}
+
+// CheckDistroboxExport reports whether distrobox-export is available to export binaries and applications to the host.
+func CheckDistroboxExport() *CheckResult {
+	result := &CheckResult{Name: "distrobox-export"}
+	if path, err := exec.LookPath(utils.DISTROBOX_EXPORT_COMMAND); err == nil {
+		result.Status = STATUS_PASS
+		result.Message = fmt.Sprintf("found at %s", path)
+		return result
+	}
+	result.Message = fmt.Sprintf("%s not found on PATH", utils.DISTROBOX_EXPORT_COMMAND)
+	if utils.CurrentEnvironment().Distrobox {
+		result.Status = STATUS_FAIL
+		result.Hint = "the distrobox is missing its host integration, recreate it with distrobox create"
+	} else {
+		result.Status = STATUS_WARN
+		result.Hint = "distrobox-export is only available inside a distrobox, exports are skipped"
+	}
+	return result
+}
+
+// CheckEnvFile reports whether the devbox env file is a regular file with 0600 permissions.
+// A missing file is only a warning, it is created by devbox setup.
+func CheckEnvFile(file string) *CheckResult {
+	result := &CheckResult{Name: "env-file"}
+	if file == "" {
+		result.Status = STATUS_FAIL
+		result.Message = "DEVBOX_ENV_FILE is set to an empty string"
+		result.Hint = "unset DEVBOX_ENV_FILE or set it to a valid file path"
+		return result
+	}
+	info, err := os.Stat(file)
+	switch {
+	case os.IsNotExist(err):
+		result.Status = STATUS_WARN
+		result.Message = fmt.Sprintf("%s does not exist", file)
+		result.Hint = "run devbox setup to create it"
+	case err != nil:
+		result.Status = STATUS_FAIL
+		result.Message = fmt.Sprintf("failed to stat %s: %v", file, err)
+	case !info.Mode().IsRegular():
+		result.Status = STATUS_FAIL
+		result.Message = fmt.Sprintf("%s is not a regular file", file)
+		result.Hint = "set DEVBOX_ENV_FILE to a regular file path"
+	case info.Mode().Perm() != 0600:
+		result.Status = STATUS_FAIL
+		result.Message = fmt.Sprintf("%s has permissions %04o, expected 0600", file, info.Mode().Perm())
+		result.Hint = fmt.Sprintf("run chmod 600 %s", file)
+	default:
+		result.Status = STATUS_PASS
+		result.Message = file
+	}
+	return result
+}
+
+// CheckEnvFileSourced reports whether the devbox env file is loaded by the user shell:
+// either it lives in the oh-my-zsh custom directory, or one of the shell rc files references it.
+func CheckEnvFileSourced(file string, home string, zshCustom string) *CheckResult {
+	result := &CheckResult{Name: "env-file-sourced"}
+	if file == "" {
+		result.Status = STATUS_FAIL
+		result.Message = "DEVBOX_ENV_FILE is set to an empty string"
+		return result
+	}
+	if filepath.Dir(filepath.Clean(file)) == filepath.Clean(zshCustom) && strings.HasSuffix(file, ".zsh") {
+		if _, err := os.Stat(filepath.Join(home, ".zshrc")); err == nil {
+			result.Status = STATUS_PASS
+			result.Message = "loaded from the oh-my-zsh custom directory"
+			return result
+		}
+	}
+	for _, rcFile := range SHELL_RC_FILES {
+		data, err := os.ReadFile(filepath.Join(home, rcFile))
+		if err != nil {
+			continue
+		}
+		content := string(data)
+		if strings.Contains(content, file) || strings.Contains(content, strings.Replace(file, home, "$HOME", 1)) || strings.Contains(content, strings.Replace(file, home, "~", 1)) {
+			result.Status = STATUS_PASS
+			result.Message = fmt.Sprintf("sourced by ~/%s", rcFile)
+			return result
+		}
+	}
+	result.Status = STATUS_WARN
+	result.Message = fmt.Sprintf("%s is not sourced by any shell rc file", file)
+	result.Hint = fmt.Sprintf("add 'source %s' to your shell rc file", file)
+	return result
+}
+
+// CheckVSCodeSettings reports whether the first existing VS Code settings.json file is valid JSON.
+func CheckVSCodeSettings(settingsFiles []string) *CheckResult {
+	result := &CheckResult{Name: "vscode-settings"}
+	for _, file := range settingsFiles {
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			result.Status = STATUS_FAIL
+			result.Message = fmt.Sprintf("failed to read %s: %v", file, err)
+			return result
+		}
+		settings := make(map[string]any)
+		if err := json.Unmarshal(data, &settings); err != nil {
+			result.Status = STATUS_FAIL
+			result.Message = fmt.Sprintf("%s is not valid JSON: %v", file, err)
+			result.Hint = "remove the comments and trailing commas from settings.json, devbox can not update it otherwise"
+			return result
+		}
+		result.Status = STATUS_PASS
+		result.Message = file
+		return result
+	}
+	result.Status = STATUS_WARN
+	result.Message = "settings.json not found"
+	result.Hint = "it is created on the next toolchain installation, unless --skip-ide is used"
+	return result
+}
+
+// CheckToolchainsPackageManagers reports, for each language package manager used by the toolchains or their components, whether its binary is on PATH.
+func CheckToolchainsPackageManagers(toolchains map[string]*commands.Toolchain) []*CheckResult {
+	usedBy := make(map[*packagemanager.PackageManager][]string)
+	for _, tc := range toolchains {
+		packageManagers := make(map[*packagemanager.PackageManager]struct{})
+		if tc.PackageManagers != nil {
+			for pm := range *tc.PackageManagers {
+				packageManagers[pm] = struct{}{}
+			}
+		}
+		for _, component := range tc.Components {
+			for pm := range component.PackageManagers {
+				packageManagers[pm] = struct{}{}
+			}
+		}
+		for pm := range packageManagers {
+			if tc.Versions != nil {
+				if _, versioned := tc.Versions.PackageManagers[pm]; versioned {
+					// Only used with a selected version (e.g. rustup)
+					continue
+				}
+			}
+			// The package managers with candidates are checked with the candidate they currently select
+			if resolved := pm.Resolve(); !slices.Contains(usedBy[resolved], tc.Name) {
+				usedBy[resolved] = append(usedBy[resolved], tc.Name)
+			}
+		}
+	}
+
+	results := make([]*CheckResult, 0, len(usedBy)+1)
+	for pm, toolchainNames := range usedBy {
+		slices.Sort(toolchainNames)
+		requiredBy := fmt.Sprintf("the %s toolchain", toolchainNames[0])
+		if len(toolchainNames) > 1 {
+			requiredBy = fmt.Sprintf("the %s toolchains", strings.Join(toolchainNames, ", "))
+		}
+		results = append(results, checkPackageManagerBinary(pm, requiredBy,
+			fmt.Sprintf("run devbox install %s, or add the %s binary directory to PATH", strings.Join(toolchainNames, " "), pm.Name),
+		))
+	}
+	results = append(results, checkPackageManagerBinary(vscode.VSCODE_PACKAGE_MANAGER,
+		"the IDE tools",
+		"run devbox setup, or use --skip-ide to skip the IDE tools",
+	))
+	slices.SortFunc(results, func(a, b *CheckResult) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return results
+}
+
+// checkPackageManagerBinary reports whether the package manager binary is on PATH
+func checkPackageManagerBinary(pm *packagemanager.PackageManager, requiredBy string, hint string) *CheckResult {
+	result := &CheckResult{Name: fmt.Sprintf("package-manager-%s", pm.Name)}
+	if path, err := exec.LookPath(pm.Name); err == nil {
+		result.Status = STATUS_PASS
+		result.Message = fmt.Sprintf("found at %s", path)
+	} else {
+		result.Status = STATUS_WARN
+		result.Message = fmt.Sprintf("%s not found on PATH, required by %s", pm.Name, requiredBy)
+		result.Hint = hint
+	}
+	return result
+}
+
+// CheckExports reports the exported binaries and applications whose host-side files are stale or broken.
+// The check is skipped outside of a distrobox.
+func CheckExports() []*CheckResult {
+	if !utils.CurrentEnvironment().Distrobox || !utils.IsDistroboxExportAvailable() {
+		return nil
+	}
+	results := make([]*CheckResult, 0, 2)
+	for _, kind := range []struct {
+		name   string
+		broken func() ([]*utils.BrokenDistroboxExport, error)
+	}{
+		{"exported-binaries", utils.BrokenDistroboxBinaries},
+		{"exported-applications", utils.BrokenDistroboxApplications},
+	} {
+		result := &CheckResult{Name: kind.name}
+		broken, err := kind.broken()
+		switch {
+		case err != nil:
+			result.Status = STATUS_FAIL
+			result.Message = err.Error()
+		case len(broken) == 0:
+			result.Status = STATUS_PASS
+			result.Message = "no broken export"
+		default:
+			details := make([]string, len(broken))
+			for i, export := range broken {
+				details[i] = fmt.Sprintf("%s (%s)", export.Name(), export.Reason)
+			}
+			result.Status = STATUS_WARN
+			result.Message = fmt.Sprintf("%d broken: %s", len(broken), strings.Join(details, ", "))
+			result.Hint = "run devbox reexport --all, or devbox unexport to remove the exports that are no longer needed"
+		}
+		results = append(results, result)
+	}
+	return results
+}
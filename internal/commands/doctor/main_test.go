@@ -0,0 +1,156 @@
+package doctor
+
+import (
+	"devbox/internal/commands"
+	"devbox/pkg/packagemanager"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	// avoid zap global logger side effects
+	zap.ReplaceGlobals(zap.NewNop())
+	os.Exit(m.Run())
+}
+
+func Test_CheckEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	validFile := filepath.Join(dir, "valid.zsh")
+	if err := os.WriteFile(validFile, nil, 0600); err != nil {
+		t.Fatalf("failed to write env file: %v", err)
+	}
+	openFile := filepath.Join(dir, "open.zsh")
+	if err := os.WriteFile(openFile, nil, 0644); err != nil {
+		t.Fatalf("failed to write env file: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		file            string
+		wantStatus      string
+		wantMsgContains string
+	}{
+		{"valid", validFile, STATUS_PASS, validFile},
+		{"empty path", "", STATUS_FAIL, "empty string"},
+		{"missing", filepath.Join(dir, "missing.zsh"), STATUS_WARN, "does not exist"},
+		{"directory", dir, STATUS_FAIL, "not a regular file"},
+		{"wrong permissions", openFile, STATUS_FAIL, "permissions 0644, expected 0600"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CheckEnvFile(tt.file)
+			if result.Status != tt.wantStatus || !strings.Contains(result.Message, tt.wantMsgContains) {
+				t.Fatalf("expected %s containing %q, got: %+v", tt.wantStatus, tt.wantMsgContains, result)
+			}
+		})
+	}
+}
+
+func Test_CheckEnvFileSourced(t *testing.T) {
+	tests := []struct {
+		name       string
+		rcFiles    map[string]string
+		file       string
+		wantStatus string
+	}{
+		{"oh-my-zsh custom directory", map[string]string{".zshrc": "plugins=(git)\n"}, "{zsh}/00-env-devbox.zsh", STATUS_PASS},
+		{"oh-my-zsh custom directory without zshrc", nil, "{zsh}/00-env-devbox.zsh", STATUS_WARN},
+		{"sourced with absolute path", map[string]string{".bashrc": "source {home}/devbox.sh\n"}, "{home}/devbox.sh", STATUS_PASS},
+		{"sourced with $HOME", map[string]string{".profile": ". $HOME/devbox.sh\n"}, "{home}/devbox.sh", STATUS_PASS},
+		{"not sourced", map[string]string{".bashrc": "alias ll='ls -l'\n"}, "{home}/devbox.sh", STATUS_WARN},
+		{"empty path", nil, "", STATUS_FAIL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			zshCustom := filepath.Join(home, ".oh-my-zsh", "custom")
+			replacer := strings.NewReplacer("{home}", home, "{zsh}", zshCustom)
+			for rcFile, content := range tt.rcFiles {
+				if err := os.WriteFile(filepath.Join(home, rcFile), []byte(replacer.Replace(content)), 0600); err != nil {
+					t.Fatalf("failed to write rc file: %v", err)
+				}
+			}
+			if result := CheckEnvFileSourced(replacer.Replace(tt.file), home, zshCustom); result.Status != tt.wantStatus {
+				t.Fatalf("expected %s, got: %+v", tt.wantStatus, result)
+			}
+		})
+	}
+}
+
+func Test_CheckVSCodeSettings(t *testing.T) {
+	dir := t.TempDir()
+	validFile := filepath.Join(dir, "valid.json")
+	os.WriteFile(validFile, []byte(`{"editor.formatOnSave": true}`), 0600)
+	invalidFile := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalidFile, []byte("{\n  // comment\n}"), 0600)
+	missingFile := filepath.Join(dir, "missing.json")
+
+	tests := []struct {
+		name       string
+		files      []string
+		wantStatus string
+	}{
+		{"first existing file is valid", []string{missingFile, validFile, invalidFile}, STATUS_PASS},
+		{"first existing file is invalid", []string{invalidFile, validFile}, STATUS_FAIL},
+		{"no settings file", []string{missingFile}, STATUS_WARN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := CheckVSCodeSettings(tt.files); result.Status != tt.wantStatus {
+				t.Fatalf("expected %s, got: %+v", tt.wantStatus, result)
+			}
+		})
+	}
+}
+
+func Test_CheckToolchainsPackageManagers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping PATH script tests on Windows")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pip"), []byte("#!/bin/sh\nexit 0\n"), 0700); err != nil {
+		t.Fatalf("failed to write pip script: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	pip := &packagemanager.PackageManager{Name: "pip"}
+	cargo := &packagemanager.PackageManager{Name: "cargo"}
+	toolchains := map[string]*commands.Toolchain{
+		"python": {Name: "python", PackageManagers: &map[*packagemanager.PackageManager][]string{pip: {"black"}}},
+		"rust":   {Name: "rust", PackageManagers: &map[*packagemanager.PackageManager][]string{cargo: {"cargo-audit"}}},
+		"c":      {Name: "c", PackageManagers: &map[*packagemanager.PackageManager][]string{cargo: {"cargo-c"}}},
+		"bash":   {Name: "bash"},
+	}
+
+	results := CheckToolchainsPackageManagers(toolchains)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got: %d", len(results))
+	}
+	byName := make(map[string]*CheckResult)
+	for _, result := range results {
+		byName[result.Name] = result
+	}
+	if result := byName["package-manager-pip"]; result == nil || result.Status != STATUS_PASS {
+		t.Fatalf("expected pip to pass, got: %+v", result)
+	}
+	if result := byName["package-manager-cargo"]; result == nil || result.Status != STATUS_WARN || !strings.Contains(result.Message, "the c, rust toolchains") || !strings.Contains(result.Hint, "devbox install c rust") {
+		t.Fatalf("expected cargo to warn, got: %+v", result)
+	}
+	if result := byName["package-manager-code"]; result == nil || result.Status != STATUS_WARN {
+		t.Fatalf("expected code to warn, got: %+v", result)
+	}
+}
+
+func Test_HasFailures(t *testing.T) {
+	if HasFailures([]*CheckResult{{Status: STATUS_PASS}, {Status: STATUS_WARN}}) {
+		t.Fatalf("expected no failure")
+	}
+	if !HasFailures([]*CheckResult{{Status: STATUS_PASS}, {Status: STATUS_FAIL}}) {
+		t.Fatalf("expected a failure")
+	}
+}
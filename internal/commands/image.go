@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/utils"
+	"os"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// IMAGE_SETUP_ENTRY is the entry written to the image toolchains file when the setup packages are baked in the image
+	IMAGE_SETUP_ENTRY = "setup"
+)
+
+var (
+	// IMAGE_TOOLCHAINS_FILE lists the toolchains whose packages were installed when building the container image
+	IMAGE_TOOLCHAINS_FILE = "/etc/devbox/toolchains"
+)
+
+// ImageToolchainEntry returns the entry of the toolchain in the image toolchains file: its name, followed by its selected components
+// after a colon (e.g. "golang:debug,lint").
+func ImageToolchainEntry(tc *Toolchain) string {
+	if len(tc.SelectedComponents) == 0 {
+		return tc.Name
+	}
+	return tc.Name + ":" + strings.Join(tc.SelectedComponents, ",")
+}
+
+// ImageToolchains returns the toolchains baked in the container image, with the components baked along their core packages.
+// It returns an empty map if devbox is not running inside a devbox generated image.
+func ImageToolchains() map[string][]string {
+	baked := make(map[string][]string)
+	data, err := os.ReadFile(IMAGE_TOOLCHAINS_FILE)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			zap.L().Warn("Failed to read image toolchains file", zap.String("file", IMAGE_TOOLCHAINS_FILE), zap.Error(err))
+		}
+		return baked
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, components, _ := strings.Cut(strings.TrimSpace(line), ":")
+		if name == "" {
+			continue
+		}
+		baked[name] = nil
+		if components != "" {
+			baked[name] = strings.Split(components, ",")
+		}
+	}
+	return baked
+}
+
+// IsBakedInImage checks if the packages of the given toolchain were installed when building the container image.
+func IsBakedInImage(name string) bool {
+	_, baked := ImageToolchains()[name]
+	return baked
+}
+
+// filterBakedToolchains returns the toolchains whose packages were not installed when building the container image.
+// The baked toolchains are kept with the packages of the selected components not baked in the image, and the packages
+// of the package managers not backed by a command (e.g. the downloads) only, as they are not installed in the image.
+func filterBakedToolchains(toolchains []*Toolchain) []*Toolchain {
+	baked := ImageToolchains()
+	if len(baked) == 0 {
+		return toolchains
+	}
+	remaining := make([]*Toolchain, 0, len(toolchains))
+	for _, tc := range toolchains {
+		if components, exists := baked[tc.Name]; exists {
+			zap.L().Info("Toolchain packages are already installed in the image, skipping package installation",
+				zap.String("toolchain", tc.Name), zap.Strings("components", components))
+			if notBaked := tc.notBakedPackages(components); notBaked != nil {
+				remaining = append(remaining, notBaked)
+			}
+			continue
+		}
+		remaining = append(remaining, tc)
+	}
+	return remaining
+}
+
+// notBakedPackages returns a toolchain with the packages of the selected components missing from the baked ones
+// and the packages of the package managers not backed by a command, or nil if the toolchain has none
+func (it *Toolchain) notBakedPackages(bakedComponents []string) *Toolchain {
+	notBaked := &Toolchain{Name: it.Name}
+	packageManagers := make(map[*packagemanager.PackageManager][]string)
+	if it.PackageManagers != nil {
+		for pm, packages := range *it.PackageManagers {
+			if pm.InstallPackages != nil {
+				packageManagers[pm] = packages
+			}
+		}
+	}
+	for _, name := range it.SelectedComponents {
+		component, exists := it.Components[name]
+		if !exists || slices.Contains(bakedComponents, name) {
+			continue
+		}
+		notBaked.InstalledPackages = utils.MergeStringSlices(notBaked.InstalledPackages, component.InstalledPackages)
+		for pm, packages := range component.PackageManagers {
+			packageManagers[pm] = utils.MergeStringSlices(packageManagers[pm], packages)
+		}
+	}
+	if len(notBaked.InstalledPackages) == 0 && len(packageManagers) == 0 {
+		return nil
+	}
+	if len(packageManagers) > 0 {
+		notBaked.PackageManagers = &packageManagers
+	}
+	return notBaked
+}
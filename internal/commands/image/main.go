@@ -0,0 +1,301 @@
+package image
+
+import (
+	"devbox/internal/commands"
+	"devbox/internal/commands/install"
+	"devbox/internal/commands/setup"
+	"devbox/pkg/packagemanager"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// IMAGE_TOOLCHAINS_LABEL is the image label listing the toolchains baked in the image
+	IMAGE_TOOLCHAINS_LABEL = "io.github.boxboxjason.devbox.toolchains"
+
+	// IMAGE_DEVBOX_BINARY is the path of the devbox binary inside the image
+	IMAGE_DEVBOX_BINARY = "/usr/local/bin/devbox"
+
+	// IMAGE_PROFILE_FILE is the shell profile holding the toolchains environment variables inside the image
+	IMAGE_PROFILE_FILE = "/etc/profile.d/devbox.sh"
+)
+
+var (
+	// DEFAULT_BASE_IMAGE is the default base image of the generated Containerfile
+	DEFAULT_BASE_IMAGE = "registry.fedoraproject.org/fedora-toolbox:latest"
+
+	// DEFAULT_BINARY_PATH is the default path of the devbox binary in the build context
+	DEFAULT_BINARY_PATH = "bin/devbox"
+
+	// DEFAULT_OUTPUT_FILE is the default path of the generated Containerfile
+	DEFAULT_OUTPUT_FILE = "Containerfile"
+
+	// DEFAULT_SYSTEM_PACKAGE_MANAGER is the default package manager of the base image
+	DEFAULT_SYSTEM_PACKAGE_MANAGER = "dnf"
+
+	// BUILD_ARGS are the build-time only variables used to install the language packages system-wide.
+	// They are declared as ARG so they are not persisted in the image and do not shadow the user environment.
+	BUILD_ARGS = [][2]string{
+		{"GOPATH", "/tmp/go"},
+		{"GOCACHE", "/tmp/go-cache"},
+		{"GOBIN", "/usr/local/bin"},
+		{"GOFLAGS", "-trimpath -modcacherw"},
+		{"CARGO_HOME", "/tmp/cargo"},
+		{"CARGO_INSTALL_ROOT", "/usr/local"},
+		{"PIP_BREAK_SYSTEM_PACKAGES", "1"},
+		{"PIP_NO_CACHE_DIR", "1"},
+		{"PIPX_HOME", "/opt/pipx"},
+		{"PIPX_BIN_DIR", "/usr/local/bin"},
+		{"UV_TOOL_DIR", "/opt/uv/tools"},
+		{"UV_TOOL_BIN_DIR", "/usr/local/bin"},
+		{"npm_config_prefix", "/usr/local"},
+		{"KREW_ROOT", "/usr/local/krew"},
+		{"PATH", "/usr/local/krew/bin:/usr/local/bin:${PATH}"},
+	}
+
+	// LANGUAGE_PACKAGE_MANAGERS_ORDER is the installation order of the language package managers,
+	// package managers depending on binaries installed by others must come last (krew is installed by go).
+	LANGUAGE_PACKAGE_MANAGERS_ORDER = []*packagemanager.PackageManager{
+		packagemanager.GOLANG_PACKAGE_MANAGER,
+		packagemanager.CARGO_PACKAGE_MANAGER,
+		packagemanager.PYTHON_PACKAGE_MANAGER,
+		packagemanager.PIPX_PACKAGE_MANAGER,
+		packagemanager.UV_PACKAGE_MANAGER,
+		packagemanager.PYTHON_TOOLS_PACKAGE_MANAGER,
+		packagemanager.NODE_PACKAGE_MANAGER,
+		packagemanager.NODE_TOOLS_PACKAGE_MANAGER,
+		packagemanager.KREW_PACKAGE_MANAGER,
+	}
+
+	// IMAGE_PACKAGE_MANAGERS replace the package managers selected from the available binaries (e.g. uv or pnpm)
+	// with the ones installed in the image by the toolchains system packages
+	IMAGE_PACKAGE_MANAGERS = map[*packagemanager.PackageManager]*packagemanager.PackageManager{
+		packagemanager.PYTHON_TOOLS_PACKAGE_MANAGER: packagemanager.PIPX_PACKAGE_MANAGER,
+		packagemanager.NODE_TOOLS_PACKAGE_MANAGER:   packagemanager.NODE_PACKAGE_MANAGER,
+	}
+
+	// PACKAGE_MANAGERS_PREPARE_COMMANDS are run before installing packages with a system package manager
+	PACKAGE_MANAGERS_PREPARE_COMMANDS = map[string]string{
+		"apt":    "apt-get update",
+		"apk":    "apk update",
+		"pacman": "pacman -Sy",
+		"zypper": "zypper refresh",
+	}
+
+	// PACKAGE_MANAGERS_POST_INSTALL_COMMANDS are run after installing packages to keep the image layers small and expose the installed binaries
+	PACKAGE_MANAGERS_POST_INSTALL_COMMANDS = map[string]string{
+		"apt":      "rm -rf /var/lib/apt/lists/*",
+		"apk":      "rm -rf /var/cache/apk/*",
+		"dnf":      "dnf clean all",
+		"microdnf": "microdnf clean all",
+		"yum":      "yum clean all",
+		"pacman":   "pacman -Scc --noconfirm",
+		"zypper":   "zypper clean --all",
+		"go":       "rm -rf /tmp/go /tmp/go-cache",
+		"cargo":    "rm -rf /tmp/cargo",
+		"npm":      "npm cache clean --force",
+		"krew":     "ln -sf /usr/local/krew/bin/kubectl-* /usr/local/bin/",
+	}
+)
+
+// BuildFileOptions contains the options used to generate the Containerfile
+type BuildFileOptions struct {
+	BaseImage      string
+	BinaryPath     string
+	OutputPath     string
+	PackageManager string
+}
+
+// WriteContainerfile generates the Containerfile for the given toolchains and writes it to the output path.
+func WriteContainerfile(opts *BuildFileOptions, toolchainNames ...string) error {
+	containerfile, err := GenerateContainerfile(opts, toolchainNames...)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.OutputPath), 0700); err != nil {
+		return fmt.Errorf("failed to create Containerfile directory: %w", err)
+	}
+	zap.L().Info("Writing Containerfile", zap.String("file", opts.OutputPath), zap.Strings("toolchains", toolchainNames))
+	if err := os.WriteFile(opts.OutputPath, []byte(containerfile), 0600); err != nil {
+		return fmt.Errorf("failed to write Containerfile: %w", err)
+	}
+	return nil
+}
+
+// GenerateContainerfile generates a Containerfile starting from the base image, with the packages of the given toolchains installed.
+// Layers are ordered from the least to the most frequently changing to make the best use of the build cache:
+// setup packages, system packages of each toolchain, language packages of each toolchain, environment variables and finally the devbox binary.
+func GenerateContainerfile(opts *BuildFileOptions, toolchainNames ...string) (string, error) {
+	toolchains, err := install.ParseToolchains(toolchainNames)
+	if err != nil {
+		return "", &commands.UsageError{Err: err}
+	}
+	systemPackageManager, err := packagemanager.GetSystemPackageManager(opts.PackageManager)
+	if err != nil {
+		return "", &commands.UsageError{Err: err}
+	}
+
+	names := make([]string, len(toolchains))
+	for i, tc := range toolchains {
+		names[i] = tc.Name
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Containerfile generated by devbox image build-file, toolchains: %s\n", strings.Join(names, ", "))
+	fmt.Fprintf(&sb, "FROM %s\n\n", opts.BaseImage)
+	fmt.Fprintf(&sb, "LABEL %s=%q\n\n", IMAGE_TOOLCHAINS_LABEL, strings.Join(names, ","))
+
+	sb.WriteString("# Setup packages\n")
+	sb.WriteString(runInstall(systemPackageManager, setup.DEFAULT_DEV_BINARIES))
+
+	for _, tc := range toolchains {
+		if len(tc.InstalledPackages) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "# %s system packages\n", tc.Name)
+		sb.WriteString(runInstall(systemPackageManager, tc.InstalledPackages))
+	}
+
+	sb.WriteString("# Build-time locations of the language packages\n")
+	for _, arg := range BUILD_ARGS {
+		fmt.Fprintf(&sb, "ARG %s=%q\n", arg[0], arg[1])
+	}
+	sb.WriteString("\n")
+
+	for _, tc := range toolchains {
+		for _, pm := range sortLanguagePackageManagers(tc.PackageManagers) {
+			if pm.InstallPackages != nil {
+				// The package managers not backed by a command are run by devbox install, once the image is running
+				continue
+			}
+			packages := (*tc.PackageManagers)[pm]
+			if imagePM, exists := IMAGE_PACKAGE_MANAGERS[pm]; exists {
+				pm = imagePM
+			}
+			fmt.Fprintf(&sb, "# %s %s packages\n", tc.Name, pm.Name)
+			sb.WriteString(runInstall(pm, packages))
+		}
+	}
+
+	sb.WriteString("# Toolchains environment variables\n")
+	sb.WriteString(runWriteLines(IMAGE_PROFILE_FILE, environmentLines(toolchains)))
+
+	sb.WriteString("# Toolchains baked in the image, their packages are not installed again by devbox\n")
+	entries := make([]string, len(toolchains))
+	for i, tc := range toolchains {
+		entries[i] = commands.ImageToolchainEntry(tc)
+	}
+	sb.WriteString(runWriteLines(commands.IMAGE_TOOLCHAINS_FILE, append(entries, commands.IMAGE_SETUP_ENTRY)))
+
+	fmt.Fprintf(&sb, "COPY %s %s\n", opts.BinaryPath, IMAGE_DEVBOX_BINARY)
+	fmt.Fprintf(&sb, "RUN chmod 0755 %s\n", IMAGE_DEVBOX_BINARY)
+
+	return sb.String(), nil
+}
+
+// runInstall returns the RUN instruction installing the packages with the package manager
+func runInstall(pm *packagemanager.PackageManager, packages []string) string {
+	var steps []string
+	if prepare, exists := PACKAGE_MANAGERS_PREPARE_COMMANDS[pm.Name]; exists {
+		steps = append(steps, prepare)
+	}
+	if pm.MultiInstall {
+		steps = append(steps, shellJoin(pm.InstallArgs(packages)))
+	} else {
+		for _, pkg := range packages {
+			steps = append(steps, shellJoin(pm.InstallArgs([]string{pkg})))
+		}
+	}
+	if postInstall, exists := PACKAGE_MANAGERS_POST_INSTALL_COMMANDS[pm.Name]; exists {
+		steps = append(steps, postInstall)
+	}
+	return "RUN " + strings.Join(steps, " && \\\n    ") + "\n\n"
+}
+
+// runWriteLines returns the RUN instruction writing the lines to the file
+func runWriteLines(file string, lines []string) string {
+	quotedLines := make([]string, len(lines))
+	for i, line := range lines {
+		quotedLines[i] = shellQuote(line)
+	}
+	return fmt.Sprintf("RUN mkdir -p %s && \\\n    printf '%%s\\n' \\\n    %s \\\n    > %s\n\n",
+		filepath.Dir(file), strings.Join(quotedLines, " \\\n    "), file)
+}
+
+// environmentLines returns the export lines of the setup and toolchains environment variables.
+// PATH variables are exported last in each group as they may reference the other variables.
+func environmentLines(toolchains []*commands.Toolchain) []string {
+	environments := []map[string]string{setup.DEFAULT_ENVIRONMENT}
+	for _, tc := range toolchains {
+		environments = append(environments, tc.EnvironmentVariables)
+	}
+
+	var lines []string
+	for _, environment := range environments {
+		keys := make([]string, 0, len(environment))
+		for key := range environment {
+			if key != "PATH" {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			lines = append(lines, fmt.Sprintf("export %s=\"%s\"", key, environment[key]))
+		}
+		if path, exists := environment["PATH"]; exists {
+			lines = append(lines, fmt.Sprintf("export PATH=\"%s\"", path))
+		}
+	}
+	return lines
+}
+
+// sortLanguagePackageManagers returns the package managers in installation order
+func sortLanguagePackageManagers(packageManagers *map[*packagemanager.PackageManager][]string) []*packagemanager.PackageManager {
+	if packageManagers == nil {
+		return nil
+	}
+	var sorted []*packagemanager.PackageManager
+	for _, pm := range LANGUAGE_PACKAGE_MANAGERS_ORDER {
+		if _, exists := (*packageManagers)[pm]; exists {
+			sorted = append(sorted, pm)
+		}
+	}
+	var others []*packagemanager.PackageManager
+	for pm := range *packageManagers {
+		if !slices.Contains(sorted, pm) {
+			others = append(others, pm)
+		}
+	}
+	slices.SortFunc(others, func(a, b *packagemanager.PackageManager) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return append(sorted, others...)
+}
+
+// shellJoin joins the arguments into a shell command line, quoting them when needed
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.IndexFunc(arg, isShellSpecial) == -1 {
+			quoted[i] = arg
+		} else {
+			quoted[i] = shellQuote(arg)
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes the string between single quotes so the shell does not interpret it
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func isShellSpecial(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./_-", r))
+}
@@ -0,0 +1,154 @@
+package image
+
+import (
+	"devbox/internal/commands"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	// avoid zap global logger side effects
+	zap.ReplaceGlobals(zap.NewNop())
+	os.Exit(m.Run())
+}
+
+func defaultOptions(t *testing.T) *BuildFileOptions {
+	t.Helper()
+	return &BuildFileOptions{
+		BaseImage:      "example.com/base:latest",
+		BinaryPath:     "bin/devbox",
+		OutputPath:     filepath.Join(t.TempDir(), "Containerfile"),
+		PackageManager: "dnf",
+	}
+}
+
+func Test_GenerateContainerfile_LayersOrder(t *testing.T) {
+	containerfile, err := GenerateContainerfile(defaultOptions(t), "kubernetes:full", "golang", "kubernetes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Each fragment must appear after the previous one
+	ordered := []string{
+		"FROM example.com/base:latest",
+		`LABEL io.github.boxboxjason.devbox.toolchains="kubernetes,golang"`,
+		"RUN dnf install git tree",
+		"RUN dnf install kubectl kustomize helm yamllint dot -y",
+		"RUN dnf install go make gofmt -y",
+		`ARG GOBIN="/usr/local/bin"`,
+		"go install sigs.k8s.io/krew/cmd/krew@latest",
+		"krew install ai blame",
+		"go install golang.org/x/tools/gopls@latest",
+		"> /etc/profile.d/devbox.sh",
+		"'kubernetes:cluster,docs,gui,plugins' \\\n    'golang:debug,lint' \\\n    'setup' \\\n    > /etc/devbox/toolchains",
+		"COPY bin/devbox /usr/local/bin/devbox",
+	}
+	position := 0
+	for _, fragment := range ordered {
+		index := strings.Index(containerfile[position:], fragment)
+		if index == -1 {
+			t.Fatalf("expected %q after position %d in Containerfile:\n%s", fragment, position, containerfile)
+		}
+		position += index + len(fragment)
+	}
+
+	if strings.Contains(containerfile, "kind k9s") {
+		t.Fatalf("expected the GitHub releases not to be installed in the image")
+	}
+	if strings.Count(containerfile, "dnf install kubectl") != 1 {
+		t.Fatalf("expected duplicated toolchains to be installed once")
+	}
+	if !strings.Contains(containerfile, `'export GOPATH="${GOPATH:-${XDG_DATA_HOME}/go}"'`) {
+		t.Fatalf("expected the toolchains environment variables in the profile file")
+	}
+}
+
+func Test_GenerateContainerfile_ImagePackageManagers(t *testing.T) {
+	containerfile, err := GenerateContainerfile(defaultOptions(t), "python", "node")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, fragment := range []string{"RUN pipx install pytest pylint", "RUN npm install -g typescript jest ts-node esbuild eslint prettier && \\\n    npm cache clean --force"} {
+		if !strings.Contains(containerfile, fragment) {
+			t.Fatalf("expected %q in Containerfile:\n%s", fragment, containerfile)
+		}
+	}
+}
+
+func Test_GenerateContainerfile_Errors(t *testing.T) {
+	tests := []struct {
+		name            string
+		packageManager  string
+		toolchains      []string
+		wantErrContains string
+	}{
+		{"unknown toolchain", "dnf", []string{"golang", "cobol"}, "unknown toolchain: cobol"},
+		{"unsupported package manager", "emerge", []string{"golang"}, "unsupported system package manager: emerge"},
+		{"no toolchain", "dnf", nil, "no toolchains specified"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := defaultOptions(t)
+			opts.PackageManager = tt.packageManager
+			_, err := GenerateContainerfile(opts, tt.toolchains...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, err)
+			}
+			if !commands.IsUsageError(err) {
+				t.Fatalf("expected a usage error, got: %v", err)
+			}
+		})
+	}
+}
+
+func Test_GenerateContainerfile_PreparesAptIndex(t *testing.T) {
+	opts := defaultOptions(t)
+	opts.PackageManager = "apt"
+	containerfile, err := GenerateContainerfile(opts, "bash")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(containerfile, "RUN apt-get update && \\\n    apt install bash shfmt shellcheck zsh fish -y") {
+		t.Fatalf("expected apt index to be updated before installing packages, got:\n%s", containerfile)
+	}
+}
+
+func Test_WriteContainerfile(t *testing.T) {
+	opts := defaultOptions(t)
+	opts.OutputPath = filepath.Join(t.TempDir(), "nested", "Containerfile")
+	if err := WriteContainerfile(opts, "bash"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(opts.OutputPath)
+	if err != nil {
+		t.Fatalf("failed to read Containerfile: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "# Containerfile generated by devbox image build-file, toolchains: bash\n") {
+		t.Fatalf("unexpected Containerfile content:\n%s", data)
+	}
+}
+
+func Test_shellJoin(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"plain arguments", []string{"go", "install", "golang.org/x/tools/gopls@latest"}, "go install golang.org/x/tools/gopls@latest"},
+		{"argument with spaces", []string{"echo", "hello world"}, "echo 'hello world'"},
+		{"argument with single quote", []string{"echo", "it's"}, `echo 'it'\''s'`},
+		{"empty argument", []string{"echo", ""}, "echo ''"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := shellJoin(tt.args); got != tt.want {
+				t.Errorf("shellJoin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package install
 
-import "devbox/internal/commands"
+import (
+	"devbox/internal/commands"
+	"devbox/pkg/utils"
+)
 
 var (
 	// C_INSTALLABLE_TOOLCHAIN is the installable toolchain for C
@@ -12,30 +15,44 @@ var (
 			"clang",
 			"make",
 			"cmake",
-			"clang-tidy",
-			"cppcheck",
-			"clang-format",
-			"valgrind",
-			"lcov",
-			"gcovr",
 			"gdb",
-			"cmake-gui",
 		},
 		ExportedBinaries: []string{
 			"gcc",
 			"clang",
 			"make",
 			"cmake",
-			"clang-tidy",
-			"cppcheck",
-			"clang-format",
-			"valgrind",
-			"lcov",
-			"gcovr",
 			"gdb",
 			"ctest",
 			"cpack",
-			"cmake-gui",
+		},
+		Components: map[string]*commands.Component{
+			"lint": {
+				Description:       "Linters and formatters",
+				Default:           true,
+				InstalledPackages: []string{"clang-tidy", "cppcheck", "clang-format"},
+				ExportedBinaries:  []string{"clang-tidy", "cppcheck", "clang-format"},
+			},
+			"coverage": {
+				Description:       "Code coverage reports",
+				Default:           true,
+				InstalledPackages: []string{"lcov", "gcovr"},
+				ExportedBinaries:  []string{"lcov", "gcovr"},
+			},
+			"debug": {
+				Description:       "Memory debugger",
+				InstalledPackages: []string{"valgrind"},
+				ExportedBinaries:  []string{"valgrind"},
+				// valgrind runs with sudo on the host, to debug the programs needing root privileges
+				ExportOptions: map[string]*utils.DistroboxExportOptions{
+					"valgrind": {Sudo: true},
+				},
+			},
+			"gui": {
+				Description:       "CMake graphical interface",
+				InstalledPackages: []string{"cmake-gui"},
+				ExportedBinaries:  []string{"cmake-gui"},
+			},
 		},
 		VSCodeExtensions: []string{
 			"ms-vscode.makefile-tools",
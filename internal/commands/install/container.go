@@ -1,20 +1,21 @@
 package install
 
-import "devbox/internal/commands"
+import (
+	"devbox/internal/commands"
+	"devbox/pkg/packagemanager"
+)
 
 var (
 	CONTAINER_INSTALLABLE_TOOLCHAIN = &commands.Toolchain{
-		Name:        "container",
-		Description: "Container development environment",
-		InstalledPackages: []string{
-			"hadolint",
-			"trivy",
-		},
-		ExportedBinaries: []string{
-			"hadolint",
-			"trivy",
-		},
+		Name:                 "container",
+		Description:          "Container development environment",
 		EnvironmentVariables: map[string]string{},
+		PackageManagers: &map[*packagemanager.PackageManager][]string{
+			CONTAINER_GITHUB_PACKAGE_MANAGER: {
+				"hadolint",
+				"trivy",
+			},
+		},
 		VSCodeExtensions: []string{
 			"ms-azuretools.vscode-docker",
 			"docker.docker",
@@ -31,4 +32,25 @@ var (
 			"hadolint.outputLevel":                          "hint",
 		},
 	}
+
+	// CONTAINER_GITHUB_RELEASES contains the container tools installed from their GitHub releases
+	CONTAINER_GITHUB_RELEASES = map[string]*packagemanager.GitHubRelease{
+		"hadolint": {
+			Repository: "hadolint/hadolint",
+			Asset:      "hadolint-{os}-{arch}",
+			Checksums:  "hadolint-{os}-{arch}.sha256",
+			OS:         map[string]string{"linux": "Linux", "darwin": "Darwin"},
+			Arch:       map[string]string{"amd64": "x86_64"},
+		},
+		"trivy": {
+			Repository: "aquasecurity/trivy",
+			Asset:      "trivy_{version}_{os}-{arch}.tar.gz",
+			Checksums:  "trivy_{version}_checksums.txt",
+			OS:         map[string]string{"linux": "Linux", "darwin": "macOS"},
+			Arch:       map[string]string{"amd64": "64bit", "arm64": "ARM64"},
+		},
+	}
+
+	// CONTAINER_GITHUB_PACKAGE_MANAGER installs the container tools distributed as GitHub releases assets
+	CONTAINER_GITHUB_PACKAGE_MANAGER = packagemanager.NewGitHubReleasePackageManager("container-github", CONTAINER_GITHUB_RELEASES)
 )
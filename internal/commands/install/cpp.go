@@ -14,12 +14,6 @@ var CPP_INSTALLABLE_TOOLCHAIN = &commands.Toolchain{
 		"make",
 		"cmake",
 		"ninja",
-		"clang-tidy",
-		"cppcheck",
-		"clang-format",
-		"valgrind",
-		"lcov",
-		"gcovr",
 		"gdb",
 	},
 	ExportedBinaries: []string{
@@ -30,14 +24,27 @@ var CPP_INSTALLABLE_TOOLCHAIN = &commands.Toolchain{
 		"make",
 		"cmake",
 		"ninja",
-		"clang-tidy",
-		"cppcheck",
-		"clang-format",
-		"valgrind",
-		"lcov",
-		"gcovr",
 		"gdb",
 	},
+	Components: map[string]*commands.Component{
+		"lint": {
+			Description:       "Linters and formatters",
+			Default:           true,
+			InstalledPackages: []string{"clang-tidy", "cppcheck", "clang-format"},
+			ExportedBinaries:  []string{"clang-tidy", "cppcheck", "clang-format"},
+		},
+		"coverage": {
+			Description:       "Code coverage reports",
+			Default:           true,
+			InstalledPackages: []string{"lcov", "gcovr"},
+			ExportedBinaries:  []string{"lcov", "gcovr"},
+		},
+		"debug": {
+			Description:       "Memory debugger",
+			InstalledPackages: []string{"valgrind"},
+			ExportedBinaries:  []string{"valgrind"},
+		},
+	},
 	VSCodeExtensions: []string{
 		"ms-vscode.cpptools",
 		"ms-vscode.cpptools-extension-pack",
@@ -33,19 +33,54 @@ var (
 		},
 		PackageManagers: &map[*packagemanager.PackageManager][]string{
 			packagemanager.GOLANG_PACKAGE_MANAGER: {
-				"github.com/securego/gosec/v2/cmd/gosec@latest",
-				"github.com/golangci/golangci-lint/v2/cmd/golangci-lint@latest",
-				"honnef.co/go/tools/cmd/staticcheck@latest",
 				"github.com/axw/gocov/gocov@latest",
 				"golang.org/x/tools/gopls@latest",
-				"golang.org/x/tools/cmd/godoc@latest",
 				"golang.org/x/tools/cmd/cover@latest",
-				"github.com/go-delve/delve/cmd/dlv@latest",
 				"github.com/cweill/gotests/gotests@latest",
 				"github.com/fatih/gomodifytags@latest",
 				"github.com/josharian/impl@latest",
 			},
 		},
+		Components: map[string]*commands.Component{
+			"lint": {
+				Description: "Linters and security scanners",
+				Default:     true,
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					packagemanager.GOLANG_PACKAGE_MANAGER: {
+						"github.com/securego/gosec/v2/cmd/gosec@latest",
+						"github.com/golangci/golangci-lint/v2/cmd/golangci-lint@latest",
+						"honnef.co/go/tools/cmd/staticcheck@latest",
+					},
+				},
+			},
+			"debug": {
+				Description: "Delve debugger",
+				Default:     true,
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					packagemanager.GOLANG_PACKAGE_MANAGER: {
+						"github.com/go-delve/delve/cmd/dlv@latest",
+					},
+				},
+			},
+			"docs": {
+				Description: "Documentation server",
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					packagemanager.GOLANG_PACKAGE_MANAGER: {
+						"golang.org/x/tools/cmd/godoc@latest",
+					},
+				},
+			},
+		},
+		Versions: &commands.ToolchainVersions{
+			Supported: []string{"1.22", "1.23", "1.24"},
+			Packages: map[string][]string{
+				"apt": {"golang-{version}-go"},
+			},
+			Home: map[string]string{
+				"apt": "/usr/lib/go-{version}",
+			},
+			LinkedBinaries: []string{"go", "gofmt"},
+		},
 		VSCodeExtensions: []string{
 			"golang.go",
 		},
@@ -1,6 +1,9 @@
 package install
 
-import "devbox/internal/commands"
+import (
+	"devbox/internal/commands"
+	"devbox/pkg/packagemanager"
+)
 
 var (
 	// JAVA_INSTALLABLE_TOOLCHAIN defines the Java toolchain with its packages, binaries, and settings.
@@ -41,11 +44,63 @@ var (
 			"mvn",
 			"jacococli",
 		},
+		PackageManagers: &map[*packagemanager.PackageManager][]string{
+			JAVA_DOWNLOAD_PACKAGE_MANAGER: {
+				"checkstyle",
+				"spotbugs",
+			},
+		},
+		Versions: &commands.ToolchainVersions{
+			Supported: []string{"17", "21", "25"},
+			Replaces:  []string{"java-25-openjdk"},
+			Packages: map[string][]string{
+				"apk":    {"openjdk{version}-jdk"},
+				"apt":    {"openjdk-{version}-jdk"},
+				"dnf":    {"java-{version}-openjdk-devel"},
+				"pacman": {"jdk{version}-openjdk"},
+				"yum":    {"java-{version}-openjdk-devel"},
+				"zypper": {"java-{version}-openjdk-devel"},
+			},
+			Home: map[string]string{
+				"apk":    "/usr/lib/jvm/java-{version}-openjdk",
+				"apt":    "/usr/lib/jvm/java-{version}-openjdk-{arch}",
+				"dnf":    "/usr/lib/jvm/java-{version}-openjdk",
+				"pacman": "/usr/lib/jvm/java-{version}-openjdk",
+				"yum":    "/usr/lib/jvm/java-{version}-openjdk",
+				"zypper": "/usr/lib64/jvm/java-{version}-openjdk",
+			},
+			LinkedBinaries: []string{
+				"jar",
+				"java",
+				"javac",
+				"javadoc",
+				"javap",
+				"jdb",
+				"jlink",
+				"jshell",
+			},
+			EnvironmentVariables: map[string]string{
+				"JAVA_HOME": "{home}",
+			},
+		},
 	}
 
-	// JAVA_BINARIES_DOWNLOAD contains the list of binaries to be installed via download
-	JAVA_BINARIES_DOWNLOAD = []string{
-		"checkstyle",
-		"spotbugs",
+	// JAVA_BINARIES_DOWNLOAD contains the binaries installed from their release artifacts.
+	// The artifacts are downloaded from Maven Central, which publishes the SHA-256 checksum of every version next to them.
+	JAVA_BINARIES_DOWNLOAD = map[string]*packagemanager.Download{
+		"checkstyle": {
+			URL:         "https://repo1.maven.org/maven2/com/puppycrawl/tools/checkstyle/{version}/checkstyle-{version}-all.jar",
+			ChecksumURL: "https://repo1.maven.org/maven2/com/puppycrawl/tools/checkstyle/{version}/checkstyle-{version}-all.jar.sha256",
+			Version:     "10.18.1",
+		},
+		"spotbugs": {
+			URL:         "https://repo1.maven.org/maven2/com/github/spotbugs/spotbugs/{version}/spotbugs-{version}.tgz",
+			ChecksumURL: "https://repo1.maven.org/maven2/com/github/spotbugs/spotbugs/{version}/spotbugs-{version}.tgz.sha256",
+			Version:     "4.8.6",
+			Binaries:    []string{"spotbugs-{version}/bin/spotbugs"},
+		},
 	}
+
+	// JAVA_DOWNLOAD_PACKAGE_MANAGER installs the Java binaries distributed as release artifacts
+	JAVA_DOWNLOAD_PACKAGE_MANAGER = packagemanager.NewDownloadPackageManager(JAVA_BINARIES_DOWNLOAD)
 )
@@ -14,8 +14,6 @@ var (
 			"kustomize",
 			"helm",
 			"yamllint",
-			"dot",
-			"k9s",
 		},
 		EnvironmentVariables: map[string]string{
 			"KREW_ROOT":                  "${KREW_ROOT:-${XDG_DATA_HOME}/krew}",
@@ -27,30 +25,71 @@ var (
 			"kustomize",
 			"helm",
 			"yamllint",
-			"dot",
-			"k9s",
 		},
-		PackageManagers: &map[*packagemanager.PackageManager][]string{
-			packagemanager.GOLANG_PACKAGE_MANAGER: {
-				"sigs.k8s.io/krew/cmd/krew@latest",
-				"github.com/norwoodj/helm-docs/cmd/helm-docs@latest",
-				"sigs.k8s.io/kind@latest",
+		Components: map[string]*commands.Component{
+			"plugins": {
+				Description: "krew and kubectl plugins",
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					packagemanager.GOLANG_PACKAGE_MANAGER: {
+						"sigs.k8s.io/krew/cmd/krew@latest",
+					},
+					packagemanager.KREW_PACKAGE_MANAGER: {
+						"ai",
+						"blame",
+						"cost",
+						"debug-shell",
+						"deprecations",
+						"explore",
+						"flame",
+						"kor",
+						"neat",
+						"tree",
+					},
+				},
 			},
-			packagemanager.PYTHON_PACKAGE_MANAGER: {
-				"KubeDiagrams",
+			"docs": {
+				Description:       "Helm charts documentation and cluster diagrams",
+				InstalledPackages: []string{"dot"},
+				ExportedBinaries:  []string{"dot"},
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					packagemanager.GOLANG_PACKAGE_MANAGER: {
+						"github.com/norwoodj/helm-docs/cmd/helm-docs@latest",
+					},
+					packagemanager.PYTHON_TOOLS_PACKAGE_MANAGER: {
+						"KubeDiagrams",
+					},
+				},
 			},
-			packagemanager.KREW_PACKAGE_MANAGER: {
-				"ai",
-				"blame",
-				"cost",
-				"debug-shell",
-				"deprecations",
-				"explore",
-				"flame",
-				"kor",
-				"neat",
-				"tree",
+			"cluster": {
+				Description: "Local clusters with kind",
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					KUBERNETES_GITHUB_PACKAGE_MANAGER: {"kind"},
+				},
 			},
+			"gui": {
+				Description: "k9s terminal UI",
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					KUBERNETES_GITHUB_PACKAGE_MANAGER: {"k9s"},
+				},
+			},
+		},
+	}
+
+	// KUBERNETES_GITHUB_RELEASES contains the Kubernetes tools installed from their GitHub releases
+	KUBERNETES_GITHUB_RELEASES = map[string]*packagemanager.GitHubRelease{
+		"kind": {
+			Repository: "kubernetes-sigs/kind",
+			Asset:      "kind-{os}-{arch}",
+			Checksums:  "kind-{os}-{arch}.sha256sum",
+		},
+		"k9s": {
+			Repository: "derailed/k9s",
+			Asset:      "k9s_{os}_{arch}.tar.gz",
+			Checksums:  "checksums.sha256",
+			OS:         map[string]string{"linux": "Linux", "darwin": "Darwin"},
 		},
 	}
+
+	// KUBERNETES_GITHUB_PACKAGE_MANAGER installs the Kubernetes tools distributed as GitHub releases assets
+	KUBERNETES_GITHUB_PACKAGE_MANAGER = packagemanager.NewGitHubReleasePackageManager("kubernetes-github", KUBERNETES_GITHUB_RELEASES)
 )
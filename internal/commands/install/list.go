@@ -0,0 +1,41 @@
+package install
+
+import (
+	"maps"
+	"slices"
+)
+
+// ToolchainInfo describes an installable toolchain, listed by devbox list
+type ToolchainInfo struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Components  []*ComponentInfo `json:"components,omitempty"`
+	// Versions are the selectable versions patterns (e.g. "1.*"), empty when only the distribution version is available
+	Versions []string `json:"versions,omitempty"`
+}
+
+// ComponentInfo describes an optional component of a toolchain
+type ComponentInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Default components are installed by the default profile
+	Default bool `json:"default"`
+}
+
+// ListToolchains returns the installable toolchains and their components, sorted by name
+func ListToolchains() []*ToolchainInfo {
+	var toolchains []*ToolchainInfo
+	for _, name := range slices.Sorted(maps.Keys(EXISTING_TOOLCHAINS)) {
+		tc := EXISTING_TOOLCHAINS[name]
+		info := &ToolchainInfo{Name: name, Description: tc.Description}
+		for _, componentName := range slices.Sorted(maps.Keys(tc.Components)) {
+			component := tc.Components[componentName]
+			info.Components = append(info.Components, &ComponentInfo{Name: componentName, Description: component.Description, Default: component.Default})
+		}
+		if tc.Versions != nil {
+			info.Versions = tc.Versions.Supported
+		}
+		toolchains = append(toolchains, info)
+	}
+	return toolchains
+}
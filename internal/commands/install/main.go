@@ -2,6 +2,7 @@ package install
 
 import (
 	"devbox/internal/commands"
+	"devbox/internal/envmanager"
 	"fmt"
 
 	"go.uber.org/zap"
@@ -25,36 +26,136 @@ var (
 	}
 )
 
+// InstallToolchains installs the given toolchains, each name can carry a profile or components (e.g. "python:minimal" or "golang:core,lint").
 func InstallToolchains(args *commands.SharedCmdArgs, toolchains ...string) []error {
-	installableToolchains, err := parseToolchains(toolchains)
+	specs, err := ParseToolchainSpecs(toolchains)
 	if err != nil {
-		return []error{err}
+		return []error{&commands.UsageError{Err: err}}
+	}
+	return InstallToolchainSpecs(args, specs...)
+}
+
+// InstallToolchainSpecs installs the toolchains of the given specs, with the components selected by their profile,
+// the command profile by default, and restricted by their options.
+// The environment variables of the selected toolchain versions (e.g. JAVA_HOME) are set through the environment manager.
+func InstallToolchainSpecs(args *commands.SharedCmdArgs, specs ...*ToolchainSpec) []error {
+	installableToolchains, err := ResolveToolchainSpecs(specs, args.Profile)
+	if err != nil {
+		return []error{&commands.UsageError{Err: err}}
+	}
+
+	var environments []map[string]string
+	for _, tc := range installableToolchains {
+		if tc.Version != "" && len(tc.EnvironmentVariables) > 0 {
+			environments = append(environments, tc.EnvironmentVariables)
+		}
+	}
+	if len(environments) > 0 {
+		if errs := envmanager.SystemEnvManager(envmanager.DEFAULT_SYS_ENV_FILE).Set(environments...); errs != nil {
+			return errs
+		}
 	}
 
-	toolChainsNames := make([]string, len(installableToolchains))
-	for i, tc := range installableToolchains {
-		toolChainsNames[i] = tc.Name
+	toolChainsNames := make([]string, len(specs))
+	for i, spec := range specs {
+		toolChainsNames[i] = spec.String()
 	}
 	zap.L().Info("Installing toolchains", zap.Strings("toolchains", toolChainsNames))
 	return commands.InstallToolchains(args, installableToolchains...)
 }
 
-func parseToolchains(toolchains []string) ([]*commands.Toolchain, error) {
-	if len(toolchains) == 0 {
-		return nil, fmt.Errorf("no toolchains specified, use --help to see available toolchains")
+// ParseToolchains resolves the given toolchain names into installable toolchains, with the components of the default profile.
+// Names can carry a profile or components (e.g. "python:minimal"), duplicated names are ignored, unknown names return an error.
+func ParseToolchains(toolchains []string) ([]*commands.Toolchain, error) {
+	return ParseToolchainsWithProfile(toolchains, commands.PROFILE_DEFAULT)
+}
+
+// ParseToolchainsWithProfile resolves the given toolchain names into installable toolchains,
+// with the components of the profile for the names without a profile or components.
+func ParseToolchainsWithProfile(toolchains []string, profile string) ([]*commands.Toolchain, error) {
+	specs, err := ParseToolchainSpecs(toolchains)
+	if err != nil {
+		return nil, err
 	}
-	var parsedToolchains []*commands.Toolchain
-	var toolchainNames = make(map[string]struct{})
-	for _, toolchain := range toolchains {
-		if _, exists := toolchainNames[toolchain]; exists {
+	return ResolveToolchainSpecs(specs, profile)
+}
+
+// ParseMergedToolchainSpecs parses the given toolchain entries into merged toolchain specs, resolved into installable toolchains
+// with the profile. The specs and the toolchains are returned in the same order, the specs keeping the version, the components
+// and the options of the entries (e.g. to install the same toolchains again).
+func ParseMergedToolchainSpecs(toolchains []string, profile string) ([]*ToolchainSpec, []*commands.Toolchain, error) {
+	specs, err := ParseToolchainSpecs(toolchains)
+	if err != nil {
+		return nil, nil, err
+	}
+	specs, err = MergeToolchainSpecs(specs, profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved, err := ResolveToolchainSpecs(specs, profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return specs, resolved, nil
+}
+
+// ParseToolchainSpecs parses the given toolchain entries into toolchain specs.
+func ParseToolchainSpecs(toolchains []string) ([]*ToolchainSpec, error) {
+	specs := make([]*ToolchainSpec, len(toolchains))
+	for i, toolchain := range toolchains {
+		spec, err := ParseToolchainSpec(toolchain)
+		if err != nil {
+			return nil, err
+		}
+		specs[i] = spec
+	}
+	return specs, nil
+}
+
+// MergeToolchainSpecs merges the specs of a same toolchain version into the first one, in the order of the given specs.
+// The given specs are left untouched, the profile is used for the specs without a profile or components.
+func MergeToolchainSpecs(specs []*ToolchainSpec, profile string) ([]*ToolchainSpec, error) {
+	if profile == "" {
+		profile = commands.PROFILE_DEFAULT
+	}
+	var mergedSpecs []*ToolchainSpec
+	var toolchainSpecs = make(map[string]*ToolchainSpec)
+	for _, spec := range specs {
+		key := spec.Name + "@" + spec.Version
+		if existing, exists := toolchainSpecs[key]; exists {
+			if err := existing.merge(spec, profile); err != nil {
+				return nil, err
+			}
 			continue
 		}
-		installableToolchain, exists := EXISTING_TOOLCHAINS[toolchain]
-		if !exists {
-			return nil, fmt.Errorf("unknown toolchain: %s", toolchain)
+		merged := *spec
+		mergedSpecs = append(mergedSpecs, &merged)
+		toolchainSpecs[key] = &merged
+	}
+	return mergedSpecs, nil
+}
+
+// ResolveToolchainSpecs resolves the given toolchain specs into installable toolchains, using the profile
+// for the specs without a profile or components. The specs of a same toolchain version are merged into the first one,
+// unknown toolchains return an error.
+func ResolveToolchainSpecs(specs []*ToolchainSpec, profile string) ([]*commands.Toolchain, error) {
+	if profile == "" {
+		profile = commands.PROFILE_DEFAULT
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no toolchains specified, use --help to see available toolchains")
+	}
+	mergedSpecs, err := MergeToolchainSpecs(specs, profile)
+	if err != nil {
+		return nil, err
+	}
+	var parsedToolchains []*commands.Toolchain
+	for _, spec := range mergedSpecs {
+		installableToolchain, err := spec.Resolve(profile)
+		if err != nil {
+			return nil, err
 		}
 		parsedToolchains = append(parsedToolchains, installableToolchain)
-		toolchainNames[toolchain] = struct{}{}
 	}
 	return parsedToolchains, nil
 }
@@ -26,22 +26,41 @@ var (
 			"npm_config_prefix":   "${XDG_DATA_HOME}/npm",
 			"NPM_CONFIG_REGISTRY": "https://registry.npmjs.org/",
 			"NPM_CONFIG_CACHE":    "${XDG_CACHE_HOME}/npm",
-			"PATH":                "${npm_config_prefix}/bin:${YARN_GLOBAL_FOLDER}/bin:${PATH}",
+			"PNPM_HOME":           "${XDG_DATA_HOME}/pnpm",
+			"BUN_INSTALL":         "${XDG_DATA_HOME}/bun",
+			"PATH":                "${npm_config_prefix}/bin:${PNPM_HOME}:${BUN_INSTALL}/bin:${YARN_GLOBAL_FOLDER}/bin:${PATH}",
 			"YARN_CACHE_FOLDER":   "${XDG_CACHE_HOME}/yarn",
 			"YARN_GLOBAL_FOLDER":  "${XDG_DATA_HOME}/yarn",
 			"YARN_CONFIG_FOLDER":  "${XDG_CONFIG_HOME}/yarn",
 			"YARN_REGISTRY":       "https://registry.yarnpkg.com",
 		},
 		PackageManagers: &map[*packagemanager.PackageManager][]string{
-			packagemanager.NODE_PACKAGE_MANAGER: {
-				"eslint",
-				"prettier",
+			packagemanager.NODE_TOOLS_PACKAGE_MANAGER: {
 				"typescript",
 				"jest",
 				"ts-node",
 				"esbuild",
 			},
 		},
+		Components: map[string]*commands.Component{
+			"lint": {
+				Description: "Linter and formatter",
+				Default:     true,
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					packagemanager.NODE_TOOLS_PACKAGE_MANAGER: {
+						"eslint",
+						"prettier",
+					},
+				},
+			},
+		},
+		Versions: &commands.ToolchainVersions{
+			Supported: []string{"18", "20", "22"},
+			Packages: map[string][]string{
+				"dnf": {"nodejs{version}", "nodejs{version}-npm"},
+			},
+			ExportedBinaries: []string{"node-{version}", "npm-{version}", "npx-{version}"},
+		},
 		VSCodeExtensions: []string{
 			"ms-vscode.vscode-typescript-next",
 			"dbaeumer.vscode-eslint",
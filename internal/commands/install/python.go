@@ -12,6 +12,7 @@ var (
 		InstalledPackages: []string{
 			"python",
 			"pip",
+			"pipx",
 		},
 		ExportedBinaries: []string{
 			"python",
@@ -20,29 +21,58 @@ var (
 		ExportedApplications: []string{},
 		EnvironmentVariables: map[string]string{
 			"PIP_INDEX_URL":             "${PIP_INDEX_URL:-https://pypi.org/simple}",
-			"PIP_BREAK_SYSTEM_PACKAGES": "${PIP_BREAK_SYSTEM_PACKAGES:1}",
+			"PIP_BREAK_SYSTEM_PACKAGES": "${PIP_BREAK_SYSTEM_PACKAGES:-1}",
 			"PIP_CACHE_DIR":             "${PIP_CACHE_DIR:-${XDG_CACHE_HOME}/pip}",
 			"PYTHONUSERBASE":            "${PYTHONUSERBASE:-${XDG_DATA_HOME}/python}",
+			"PIPX_HOME":                 "${PIPX_HOME:-${XDG_DATA_HOME}/pipx}",
+			"PIPX_BIN_DIR":              "${PIPX_BIN_DIR:-${PYTHONUSERBASE}/bin}",
+			"UV_TOOL_DIR":               "${UV_TOOL_DIR:-${XDG_DATA_HOME}/uv/tools}",
+			"UV_TOOL_BIN_DIR":           "${UV_TOOL_BIN_DIR:-${PYTHONUSERBASE}/bin}",
 			"PATH":                      "${PYTHONUSERBASE}/bin:${PATH}",
 		},
 		PackageManagers: &map[*packagemanager.PackageManager][]string{
-			packagemanager.PYTHON_PACKAGE_MANAGER: {
-				"pylint",
-				"black",
-				"bandit",
+			packagemanager.PYTHON_TOOLS_PACKAGE_MANAGER: {
 				"pytest",
-				"mypy",
-				"flake8",
-				"autopep8",
 			},
 		},
+		Components: map[string]*commands.Component{
+			"lint": {
+				Description: "Linters, formatters and type checkers",
+				Default:     true,
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					packagemanager.PYTHON_TOOLS_PACKAGE_MANAGER: {
+						"pylint",
+						"black",
+						"bandit",
+						"mypy",
+						"flake8",
+						"autopep8",
+					},
+				},
+				VSCodeExtensions: []string{"ms-python.pylint"},
+			},
+			"debug": {
+				Description:      "VS Code debugger",
+				Default:          true,
+				VSCodeExtensions: []string{"ms-python.debugpy"},
+			},
+			"docs": {
+				Description:      "Docstrings generation",
+				VSCodeExtensions: []string{"njpwerner.autodocstring"},
+			},
+		},
+		Versions: &commands.ToolchainVersions{
+			Supported: []string{"3.10", "3.11", "3.12", "3.13"},
+			Packages: map[string][]string{
+				"apt": {"python{version}", "python{version}-venv"},
+				"dnf": {"python{version}"},
+			},
+			ExportedBinaries: []string{"python{version}"},
+		},
 		VSCodeExtensions: []string{
 			"ms-python.python",
 			"ms-python.vscode-pylance",
-			"ms-python.debugpy",
 			"ms-python.vscode-python-envs",
-			"njpwerner.autodocstring",
-			"ms-python.pylint",
 		},
 		VSCodeSettings: map[string]any{
 			"python.analysis.autoImportCompletions":                     true,
@@ -25,18 +25,62 @@ var (
 			"rustdoc",
 		},
 		EnvironmentVariables: map[string]string{
-			"CARGO_HOME":  "${XDG_DATA_HOME}/cargo",
-			"RUSTUP_HOME": "${XDG_DATA_HOME}/rustup",
-			"PATH":        "${CARGO_HOME}/bin:${PATH}",
+			"CARGO_HOME": "${XDG_DATA_HOME}/cargo",
+			"PATH":       "${CARGO_HOME}/bin:${PATH}",
 		},
 		PackageManagers: &map[*packagemanager.PackageManager][]string{
 			packagemanager.CARGO_PACKAGE_MANAGER: {
-				"cargo-auditable",
 				"cargo-edit",
-				"cargo-audit",
 				"cargo-watch",
 			},
 		},
+		Components: map[string]*commands.Component{
+			"lint": {
+				Description: "Dependencies vulnerabilities audit",
+				Default:     true,
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					packagemanager.CARGO_PACKAGE_MANAGER: {
+						"cargo-auditable",
+						"cargo-audit",
+					},
+				},
+			},
+			"targets": {
+				Description: "WebAssembly and static musl compilation targets, with a rustup channel",
+				PackageManagers: map[*packagemanager.PackageManager][]string{
+					packagemanager.RUSTUP_PACKAGE_MANAGER: {
+						packagemanager.RUSTUP_TARGET_PREFIX + "wasm32-unknown-unknown",
+						packagemanager.RUSTUP_TARGET_PREFIX + "wasm32-wasip1",
+						packagemanager.RUSTUP_TARGET_PREFIX + "{arch}-unknown-linux-musl",
+					},
+				},
+			},
+		},
+		// The rustup channels (e.g. rust@stable, rust@nightly or rust@1.79.0) replace the distribution compiler
+		Versions: &commands.ToolchainVersions{
+			Supported: []string{"stable", "beta", "nightly", "nightly-*", "1.*"},
+			Replaces:  []string{"cargo", "rustc", "clippy", "rustfmt", "rustdoc"},
+			Packages: map[string][]string{
+				"apk":    {"rustup"},
+				"apt":    {"rustup"},
+				"dnf":    {"rustup"},
+				"pacman": {"rustup"},
+				"zypper": {"rustup"},
+			},
+			ExportedBinaries: []string{"rustup", "rust-analyzer"},
+			EnvironmentVariables: map[string]string{
+				"RUSTUP_HOME": "${XDG_DATA_HOME}/rustup",
+			},
+			PackageManagers: map[*packagemanager.PackageManager][]string{
+				packagemanager.RUSTUP_PACKAGE_MANAGER: {
+					"{version}",
+					packagemanager.RUSTUP_COMPONENT_PREFIX + "clippy",
+					packagemanager.RUSTUP_COMPONENT_PREFIX + "rustfmt",
+					packagemanager.RUSTUP_COMPONENT_PREFIX + "rust-src",
+					packagemanager.RUSTUP_COMPONENT_PREFIX + "rust-analyzer",
+				},
+			},
+		},
 		VSCodeExtensions: []string{
 			"rust-lang.rust-analyzer",
 			"tamasfe.even-better-toml",
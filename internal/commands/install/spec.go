@@ -0,0 +1,267 @@
+package install
+
+import (
+	"bufio"
+	"devbox/internal/commands"
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/utils"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// INCLUDE_DIRECTIVE includes the entries of another install file, relative to the including file
+	INCLUDE_DIRECTIVE = "@include"
+
+	// STDIN_FILE is the install file path reading the entries from the standard input
+	STDIN_FILE = "-"
+)
+
+// ToolchainSpec is a toolchain entry of the command line or of an install file, with the version, the profile or components
+// and the options selecting what is installed (e.g. "java@17", "python:minimal", "golang:core,lint" or "golang --no-ide")
+type ToolchainSpec struct {
+	Name string
+	// Version selects a version of the toolchain, the distribution default version is installed when empty
+	Version string
+	// Profile overrides the profile of the command, it is empty when the components are given
+	Profile    string
+	Components []string
+	NoIde      bool
+	NoExport   bool
+}
+
+func (s *ToolchainSpec) String() string {
+	spec := s.Name
+	if s.Version != "" {
+		spec += "@" + s.Version
+	}
+	if s.Profile != "" {
+		spec += ":" + s.Profile
+	} else if len(s.Components) > 0 {
+		spec += ":" + strings.Join(s.Components, ",")
+	}
+	if s.NoIde {
+		spec += " --no-ide"
+	}
+	if s.NoExport {
+		spec += " --no-export"
+	}
+	return spec
+}
+
+// ParseToolchainSpec parses a toolchain entry: the toolchain name, an optional version after an at sign,
+// an optional profile or comma separated list of components after a colon, then the --no-ide and --no-export options.
+func ParseToolchainSpec(entry string) (*ToolchainSpec, error) {
+	fields := strings.Fields(entry)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty toolchain entry")
+	}
+	name, qualifier, hasQualifier := strings.Cut(fields[0], ":")
+	name, version, hasVersion := strings.Cut(name, "@")
+	if name == "" {
+		return nil, fmt.Errorf("missing toolchain name in %q", entry)
+	}
+	if hasVersion && version == "" {
+		return nil, fmt.Errorf("empty version in %q", fields[0])
+	}
+	spec := &ToolchainSpec{Name: name, Version: version}
+	if slices.Contains(commands.PROFILES, qualifier) {
+		spec.Profile = qualifier
+	} else if hasQualifier {
+		for _, component := range strings.Split(qualifier, ",") {
+			if component = strings.TrimSpace(component); component == "" {
+				return nil, fmt.Errorf("empty component in %q", fields[0])
+			}
+			spec.Components = append(spec.Components, component)
+		}
+	}
+	for _, option := range fields[1:] {
+		switch option {
+		case "--no-ide":
+			spec.NoIde = true
+		case "--no-export":
+			spec.NoExport = true
+		default:
+			return nil, fmt.Errorf("unknown option %q for toolchain %s, expected --no-ide or --no-export", option, name)
+		}
+	}
+	return spec, nil
+}
+
+// Resolve returns the installable toolchain of the spec, with the packages of its components
+// and stripped of what the options exclude. The profile is used when the spec names neither a profile nor components.
+// The versioned packages are resolved for the system package manager when the spec selects a version,
+// the packages only used with a selected version are dropped otherwise.
+// The registered toolchain is returned as is when the spec does not change it.
+func (s *ToolchainSpec) Resolve(profile string) (*commands.Toolchain, error) {
+	toolchain, exists := EXISTING_TOOLCHAINS[s.Name]
+	if !exists {
+		return nil, fmt.Errorf("unknown toolchain: %s", s.Name)
+	}
+	if s.Profile != "" {
+		profile = s.Profile
+	}
+
+	components, err := s.selectedComponents(profile)
+	if err != nil {
+		return nil, err
+	}
+	toolchain, err = toolchain.WithComponents(components...)
+	if err != nil {
+		return nil, err
+	}
+	if s.Version != "" {
+		if toolchain, err = toolchain.WithVersion(s.Version, packagemanager.SystemPackageManager); err != nil {
+			return nil, err
+		}
+	} else {
+		toolchain = toolchain.WithDefaultVersion()
+	}
+	if profile != commands.PROFILE_MINIMAL && !s.NoIde && !s.NoExport {
+		return toolchain, nil
+	}
+
+	resolved := *toolchain
+	if len(s.Components) == 0 && profile == commands.PROFILE_MINIMAL {
+		resolved.PackageManagers = toolchain.VersionPackageManagers()
+		resolved.VSCodeExtensions = nil
+		resolved.VSCodeSettings = nil
+		resolved.PostInstallHooks = nil
+	}
+	if s.NoIde {
+		resolved.VSCodeExtensions = nil
+		resolved.VSCodeSettings = nil
+	}
+	if s.NoExport {
+		resolved.ExportedBinaries = nil
+		resolved.ExportedApplications = nil
+		resolved.NoExport = true
+	}
+	return &resolved, nil
+}
+
+// selectedComponents returns the components given by the spec, or the components of its profile or of the given profile
+func (s *ToolchainSpec) selectedComponents(profile string) ([]string, error) {
+	if len(s.Components) > 0 {
+		return s.Components, nil
+	}
+	toolchain, exists := EXISTING_TOOLCHAINS[s.Name]
+	if !exists {
+		return nil, fmt.Errorf("unknown toolchain: %s", s.Name)
+	}
+	if s.Profile != "" {
+		profile = s.Profile
+	}
+	return toolchain.ProfileComponents(profile)
+}
+
+// merge adds the components of another spec of the same toolchain version to the spec,
+// the --no-ide and --no-export options are only kept when both specs set them.
+func (s *ToolchainSpec) merge(other *ToolchainSpec, profile string) error {
+	components, err := s.selectedComponents(profile)
+	if err != nil {
+		return err
+	}
+	otherComponents, err := other.selectedComponents(profile)
+	if err != nil {
+		return err
+	}
+	s.NoIde = s.NoIde && other.NoIde
+	s.NoExport = s.NoExport && other.NoExport
+	if s.isMinimal(profile) && other.isMinimal(profile) {
+		return nil
+	}
+	// The core component keeps the language packages and IDE tools that the minimal profile strips
+	s.Profile = ""
+	s.Components = utils.MergeStringSlices([]string{commands.COMPONENT_CORE}, components, otherComponents)
+	return nil
+}
+
+// isMinimal reports whether the spec installs the minimal profile, with the given profile when it names none
+func (s *ToolchainSpec) isMinimal(profile string) bool {
+	if s.Profile != "" {
+		profile = s.Profile
+	}
+	return len(s.Components) == 0 && profile == commands.PROFILE_MINIMAL
+}
+
+// ReadInstallFile reads the toolchain entries of an install file, or of the standard input when the path is "-".
+// Blank lines and comments starting with # are ignored, and "@include <path>" lines include the entries of another file,
+// relative to the including file. Errors name the file and the line of the invalid entry.
+func ReadInstallFile(path string) ([]*ToolchainSpec, error) {
+	if path == STDIN_FILE {
+		return readInstallEntries(os.Stdin, "<stdin>", ".", map[string]struct{}{})
+	}
+	return readInstallFile(path, map[string]struct{}{})
+}
+
+// readInstallFile reads the install file, visiting holds the files being read to detect include cycles
+func readInstallFile(path string, visiting map[string]struct{}) ([]*ToolchainSpec, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve install file %s: %w", path, err)
+	}
+	if _, exists := visiting[absPath]; exists {
+		return nil, fmt.Errorf("install file %s includes itself", path)
+	}
+	visiting[absPath] = struct{}{}
+	defer delete(visiting, absPath)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open install file: %w", err)
+	}
+	defer file.Close()
+	return readInstallEntries(file, path, filepath.Dir(path), visiting)
+}
+
+// readInstallEntries parses the entries of the install file named name, includes are resolved from dir
+func readInstallEntries(reader io.Reader, name string, dir string, visiting map[string]struct{}) ([]*ToolchainSpec, error) {
+	var specs []*ToolchainSpec
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if fields := strings.Fields(line); fields[0] == INCLUDE_DIRECTIVE {
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: %s requires a single file path", name, lineNumber, INCLUDE_DIRECTIVE)
+			}
+			include := fields[1]
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(dir, include)
+			}
+			included, err := readInstallFile(include, visiting)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", name, lineNumber, err)
+			}
+			specs = append(specs, included...)
+			continue
+		}
+
+		spec, err := ParseToolchainSpec(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, lineNumber, err)
+		}
+		if _, exists := EXISTING_TOOLCHAINS[spec.Name]; !exists {
+			return nil, fmt.Errorf("%s:%d: unknown toolchain: %s", name, lineNumber, spec.Name)
+		}
+		specs = append(specs, spec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read install file %s: %w", name, err)
+	}
+	return specs, nil
+}
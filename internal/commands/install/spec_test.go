@@ -0,0 +1,397 @@
+package install
+
+import (
+	"devbox/internal/commands"
+	"devbox/pkg/packagemanager"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_ParseToolchainSpec(t *testing.T) {
+	tests := []struct {
+		entry           string
+		want            ToolchainSpec
+		wantErrContains string
+	}{
+		{entry: "golang", want: ToolchainSpec{Name: "golang"}},
+		{entry: "python:minimal", want: ToolchainSpec{Name: "python", Profile: commands.PROFILE_MINIMAL}},
+		{entry: "golang --no-ide --no-export", want: ToolchainSpec{Name: "golang", NoIde: true, NoExport: true}},
+		{entry: "golang:core,lint", want: ToolchainSpec{Name: "golang", Components: []string{"core", "lint"}}},
+		{entry: "golang:lint,", wantErrContains: "empty component"},
+		{entry: "golang --verbose", wantErrContains: `unknown option "--verbose"`},
+		{entry: ":minimal", wantErrContains: "missing toolchain name"},
+		{entry: "java@17", want: ToolchainSpec{Name: "java", Version: "17"}},
+		{entry: "python@3.12:minimal --no-ide", want: ToolchainSpec{Name: "python", Version: "3.12", Profile: commands.PROFILE_MINIMAL, NoIde: true}},
+		{entry: "java@:minimal", wantErrContains: "empty version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			spec, err := ParseToolchainSpec(tt.entry)
+			if tt.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if spec.Name != tt.want.Name || spec.Version != tt.want.Version || spec.Profile != tt.want.Profile || !slices.Equal(spec.Components, tt.want.Components) || spec.NoIde != tt.want.NoIde || spec.NoExport != tt.want.NoExport {
+				t.Fatalf("expected %+v, got: %+v", tt.want, *spec)
+			}
+			if spec.String() != tt.entry {
+				t.Fatalf("expected %q to round trip, got: %q", tt.entry, spec.String())
+			}
+		})
+	}
+}
+
+func Test_ToolchainSpec_Resolve(t *testing.T) {
+	goPackages := func(tc *commands.Toolchain) []string {
+		if tc.PackageManagers == nil {
+			return nil
+		}
+		return (*tc.PackageManagers)[packagemanager.GOLANG_PACKAGE_MANAGER]
+	}
+	dlv := "github.com/go-delve/delve/cmd/dlv@latest"
+	godoc := "golang.org/x/tools/cmd/godoc@latest"
+	golangci := "github.com/golangci/golangci-lint/v2/cmd/golangci-lint@latest"
+
+	tests := []struct {
+		name            string
+		spec            ToolchainSpec
+		profile         string
+		wantGoPackages  []string
+		wantMissing     []string
+		wantErrContains string
+	}{
+		{name: "default profile", spec: ToolchainSpec{Name: "golang"}, profile: commands.PROFILE_DEFAULT, wantGoPackages: []string{dlv, golangci}, wantMissing: []string{godoc}},
+		{name: "full profile", spec: ToolchainSpec{Name: "golang"}, profile: commands.PROFILE_FULL, wantGoPackages: []string{dlv, godoc, golangci}},
+		{name: "components", spec: ToolchainSpec{Name: "golang", Components: []string{"core", "lint"}}, profile: commands.PROFILE_FULL, wantGoPackages: []string{golangci}, wantMissing: []string{dlv, godoc}},
+		{name: "spec profile overrides the command profile", spec: ToolchainSpec{Name: "golang", Profile: commands.PROFILE_FULL}, profile: commands.PROFILE_MINIMAL, wantGoPackages: []string{godoc}},
+		{name: "unknown component", spec: ToolchainSpec{Name: "golang", Components: []string{"gui"}}, profile: commands.PROFILE_DEFAULT, wantErrContains: `unknown component "gui" for toolchain golang, expected one of core, debug, docs, lint`},
+		{name: "unknown profile", spec: ToolchainSpec{Name: "golang"}, profile: "huge", wantErrContains: `unknown profile "huge"`},
+		{name: "unknown toolchain", spec: ToolchainSpec{Name: "cobol"}, profile: commands.PROFILE_DEFAULT, wantErrContains: "unknown toolchain: cobol"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := tt.spec.Resolve(tt.profile)
+			if tt.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, pkg := range tt.wantGoPackages {
+				if !slices.Contains(goPackages(tc), pkg) {
+					t.Fatalf("expected %s to be installed, got: %v", pkg, goPackages(tc))
+				}
+			}
+			for _, pkg := range tt.wantMissing {
+				if slices.Contains(goPackages(tc), pkg) {
+					t.Fatalf("expected %s not to be installed, got: %v", pkg, goPackages(tc))
+				}
+			}
+		})
+	}
+
+	if tc, _ := (&ToolchainSpec{Name: "bash"}).Resolve(commands.PROFILE_FULL); tc != BASH_INSTALLABLE_TOOLCHAIN {
+		t.Fatalf("expected the registered toolchain when the spec does not change it")
+	}
+
+	minimal, err := (&ToolchainSpec{Name: "c", Profile: commands.PROFILE_MINIMAL, NoExport: true}).Resolve(commands.PROFILE_DEFAULT)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if minimal.VSCodeExtensions != nil || minimal.ExportedBinaries != nil || !minimal.NoExport || slices.Contains(minimal.InstalledPackages, "clang-tidy") {
+		t.Fatalf("expected only the core system packages, got: %+v", minimal)
+	}
+	if !slices.Equal(minimal.InstalledPackages, C_INSTALLABLE_TOOLCHAIN.InstalledPackages) {
+		t.Fatalf("expected the core system packages to be kept, got: %v", minimal.InstalledPackages)
+	}
+	if C_INSTALLABLE_TOOLCHAIN.VSCodeExtensions == nil || C_INSTALLABLE_TOOLCHAIN.ExportedBinaries == nil {
+		t.Fatalf("expected the registered toolchain to be left untouched")
+	}
+
+	debug, err := (&ToolchainSpec{Name: "c", Components: []string{"debug"}}).Resolve(commands.PROFILE_DEFAULT)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts := debug.ExportOptions["valgrind"]; opts == nil || !opts.Sudo {
+		t.Fatalf("expected valgrind to be exported with sudo, got: %+v", debug.ExportOptions)
+	}
+	if C_INSTALLABLE_TOOLCHAIN.ExportOptions != nil {
+		t.Fatalf("expected the registered toolchain export options to be left untouched")
+	}
+}
+
+func Test_ResolveToolchainSpecs_MergesSameToolchainVersion(t *testing.T) {
+	specs, err := ParseToolchainSpecs([]string{"golang:lint", "bash", "golang:docs --no-ide"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	toolchains, err := ResolveToolchainSpecs(specs, commands.PROFILE_DEFAULT)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(toolchains) != 2 || toolchains[0].Name != "golang" || toolchains[1].Name != "bash" {
+		t.Fatalf("expected the golang specs to be merged into the first one, got: %v", toolchains)
+	}
+	golang := toolchains[0]
+	if !slices.Equal(golang.SelectedComponents, []string{"docs", "lint"}) {
+		t.Fatalf("expected the components of both specs, got: %v", golang.SelectedComponents)
+	}
+	if golang.VSCodeExtensions == nil {
+		t.Fatalf("expected the IDE tools of the spec without --no-ide to be kept")
+	}
+
+	specs, err = ParseToolchainSpecs([]string{"golang:minimal", "golang:minimal --no-ide"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	toolchains, err = ResolveToolchainSpecs(specs, commands.PROFILE_DEFAULT)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(toolchains) != 1 || toolchains[0].SelectedComponents != nil || toolchains[0].VSCodeExtensions != nil {
+		t.Fatalf("expected the minimal golang toolchain, got: %+v", toolchains)
+	}
+}
+
+func Test_ToolchainSpec_ResolveVersion(t *testing.T) {
+	systemPackageManager := packagemanager.SystemPackageManager
+	t.Cleanup(func() { packagemanager.SystemPackageManager = systemPackageManager })
+	dnf, _ := packagemanager.GetSystemPackageManager("dnf")
+	apt, _ := packagemanager.GetSystemPackageManager("apt")
+
+	tests := []struct {
+		name            string
+		spec            ToolchainSpec
+		pm              *packagemanager.PackageManager
+		wantName        string
+		wantPackages    []string
+		wantReplaced    []string
+		wantExported    []string
+		wantEnv         map[string]string
+		wantErrContains string
+	}{
+		{
+			name:         "java side by side version",
+			spec:         ToolchainSpec{Name: "java", Version: "17"},
+			pm:           dnf,
+			wantName:     "java@17",
+			wantPackages: []string{"java-17-openjdk-devel", "maven"},
+			wantReplaced: []string{"java-25-openjdk"},
+			wantExported: []string{"java", filepath.Join(commands.VERSIONED_BINARIES_DIR, "java17"), filepath.Join(commands.VERSIONED_BINARIES_DIR, "javac17")},
+			wantEnv:      map[string]string{"JAVA_HOME": "/usr/lib/jvm/java-17-openjdk"},
+		},
+		{
+			name:         "python versioned package",
+			spec:         ToolchainSpec{Name: "python", Version: "3.12"},
+			pm:           apt,
+			wantName:     "python@3.12",
+			wantPackages: []string{"python", "python3.12", "python3.12-venv"},
+			wantExported: []string{"python", "python3.12"},
+		},
+		{
+			name:         "rustup pinned channel",
+			spec:         ToolchainSpec{Name: "rust", Version: "1.79.0"},
+			pm:           dnf,
+			wantName:     "rust@1.79.0",
+			wantPackages: []string{"rustup"},
+			wantReplaced: []string{"cargo", "rustc", "clippy"},
+			wantExported: []string{"rustup", "cargo"},
+			wantEnv:      map[string]string{"RUSTUP_HOME": "${XDG_DATA_HOME}/rustup"},
+		},
+		{name: "unsupported rustup channel", spec: ToolchainSpec{Name: "rust", Version: "2.0"}, pm: dnf, wantErrContains: `unsupported version "2.0" for toolchain rust`},
+		{name: "unsupported version", spec: ToolchainSpec{Name: "java", Version: "8"}, pm: dnf, wantErrContains: `unsupported version "8" for toolchain java, expected one of 17, 21, 25`},
+		{name: "version not packaged", spec: ToolchainSpec{Name: "node", Version: "20"}, pm: apt, wantErrContains: "version 20 of toolchain node is not available with apt"},
+		{name: "toolchain without versions", spec: ToolchainSpec{Name: "bash", Version: "5"}, pm: dnf, wantErrContains: "toolchain bash has no selectable version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packagemanager.SystemPackageManager = tt.pm
+			tc, err := tt.spec.Resolve(commands.PROFILE_DEFAULT)
+			if tt.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.Name != tt.wantName || tc.Version != tt.spec.Version {
+				t.Fatalf("expected toolchain %s, got: %s version %s", tt.wantName, tc.Name, tc.Version)
+			}
+			for _, pkg := range tt.wantPackages {
+				if !slices.Contains(tc.InstalledPackages, pkg) {
+					t.Fatalf("expected %s to be installed, got: %v", pkg, tc.InstalledPackages)
+				}
+			}
+			for _, pkg := range tt.wantReplaced {
+				if slices.Contains(tc.InstalledPackages, pkg) {
+					t.Fatalf("expected %s to be replaced, got: %v", pkg, tc.InstalledPackages)
+				}
+			}
+			for _, binary := range tt.wantExported {
+				if !slices.Contains(tc.ExportedBinaries, binary) {
+					t.Fatalf("expected %s to be exported, got: %v", binary, tc.ExportedBinaries)
+				}
+			}
+			for key, value := range tt.wantEnv {
+				if tc.EnvironmentVariables[key] != value {
+					t.Fatalf("expected %s=%s, got: %v", key, value, tc.EnvironmentVariables)
+				}
+			}
+		})
+	}
+
+	if !slices.Contains(JAVA_INSTALLABLE_TOOLCHAIN.InstalledPackages, "java-25-openjdk") || JAVA_INSTALLABLE_TOOLCHAIN.EnvironmentVariables != nil {
+		t.Fatalf("expected the registered toolchain to be left untouched")
+	}
+}
+
+func Test_ReadInstallFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write install file: %v", err)
+		}
+		return path
+	}
+	write("shared/base.txt", "# shared toolchains\nbash\ngithub --no-ide\n")
+	valid := write("valid.txt", "# team toolchains\n\ngolang   # the backend\npython:minimal\n@include shared/base.txt\n")
+	invalid := write("invalid.txt", "golang\n# comment\ncobol\n")
+	invalidInclude := write("invalid-include.txt", "golang\n@include invalid.txt\n")
+	cycle := write("cycle.txt", "@include cycle.txt\n")
+	missingInclude := write("missing-include.txt", "@include missing.txt\n")
+
+	tests := []struct {
+		name            string
+		file            string
+		want            []string
+		wantErrContains string
+	}{
+		{name: "comments and includes", file: valid, want: []string{"golang", "python:minimal", "bash", "github --no-ide"}},
+		{name: "unknown toolchain", file: invalid, wantErrContains: invalid + ":3: unknown toolchain: cobol"},
+		{name: "error in included file", file: invalidInclude, wantErrContains: invalidInclude + ":2: " + filepath.Join(dir, "invalid.txt") + ":3: unknown toolchain: cobol"},
+		{name: "include cycle", file: cycle, wantErrContains: "includes itself"},
+		{name: "missing include", file: missingInclude, wantErrContains: missingInclude + ":1: failed to open install file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specs, err := ReadInstallFile(tt.file)
+			if tt.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := make([]string, len(specs))
+			for i, spec := range specs {
+				got[i] = spec.String()
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_ToolchainSpec_ResolveRustup(t *testing.T) {
+	systemPackageManager := packagemanager.SystemPackageManager
+	t.Cleanup(func() { packagemanager.SystemPackageManager = systemPackageManager })
+	packagemanager.SystemPackageManager, _ = packagemanager.GetSystemPackageManager("apt")
+
+	tests := []struct {
+		name       string
+		spec       string
+		wantRustup []string
+	}{
+		{"distribution compiler", "rust:full", nil},
+		{"channel with components", "rust@nightly", []string{"nightly", "component:clippy", "component:rustfmt", "component:rust-src", "component:rust-analyzer"}},
+		{"channel with targets", "rust@stable:targets", []string{"stable", "component:clippy", "component:rustfmt", "component:rust-src", "component:rust-analyzer", "target:wasm32-unknown-unknown", "target:wasm32-wasip1", "target:{arch}-unknown-linux-musl"}},
+		{"minimal channel", "rust@beta:minimal", []string{"beta", "component:clippy", "component:rustfmt", "component:rust-src", "component:rust-analyzer"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseToolchainSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tc, err := spec.Resolve(commands.PROFILE_DEFAULT)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var rustup []string
+			if tc.PackageManagers != nil {
+				rustup = (*tc.PackageManagers)[packagemanager.RUSTUP_PACKAGE_MANAGER]
+			}
+			if !slices.Equal(rustup, tt.wantRustup) {
+				t.Fatalf("expected rustup packages %q, got: %q", tt.wantRustup, rustup)
+			}
+			if spec.Version == "" && tc.EnvironmentVariables["RUSTUP_HOME"] != "" {
+				t.Fatalf("expected RUSTUP_HOME not to be set with the distribution compiler")
+			}
+		})
+	}
+}
+
+func Test_ListToolchains(t *testing.T) {
+	toolchains := ListToolchains()
+	if len(toolchains) != len(EXISTING_TOOLCHAINS) {
+		t.Fatalf("expected %d toolchains, got: %d", len(EXISTING_TOOLCHAINS), len(toolchains))
+	}
+	if !slices.IsSortedFunc(toolchains, func(a, b *ToolchainInfo) int { return strings.Compare(a.Name, b.Name) }) {
+		t.Fatalf("expected the toolchains to be sorted by name")
+	}
+	golang := toolchains[slices.IndexFunc(toolchains, func(tc *ToolchainInfo) bool { return tc.Name == "golang" })]
+	if !slices.Equal(golang.Versions, []string{"1.22", "1.23", "1.24"}) || len(golang.Components) == 0 {
+		t.Fatalf("expected the golang versions and components, got: %+v", golang)
+	}
+}
+
+func Test_JavaBinariesDownload_Verified(t *testing.T) {
+	for name, download := range JAVA_BINARIES_DOWNLOAD {
+		if len(download.Checksums) == 0 && download.ChecksumURL == "" {
+			t.Errorf("expected the %s download to declare its checksums or checksum URL, it is refused otherwise", name)
+		}
+	}
+}
+
+func Test_ToolchainsPackageManagers_UniqueNames(t *testing.T) {
+	// The packages are grouped and locked by package manager name
+	names := make(map[string]*packagemanager.PackageManager)
+	for _, tc := range EXISTING_TOOLCHAINS {
+		managers := []map[*packagemanager.PackageManager][]string{}
+		if tc.PackageManagers != nil {
+			managers = append(managers, *tc.PackageManagers)
+		}
+		for _, component := range tc.Components {
+			managers = append(managers, component.PackageManagers)
+		}
+		for _, packageManagers := range managers {
+			for pm := range packageManagers {
+				if existing, exists := names[pm.Name]; exists && existing != pm {
+					t.Errorf("expected a unique package manager name, %s is used by two package managers", pm.Name)
+				}
+				names[pm.Name] = pm
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// LOCK_FILE_VERSION is the version of the lock file format written by devbox
+	LOCK_FILE_VERSION = 1
+)
+
+var (
+	// DEFAULT_LOCK_FILE is the lock file read by devbox install --locked and written by devbox lock
+	DEFAULT_LOCK_FILE = utils.Getenv("DEVBOX_LOCK_FILE", "devbox.lock")
+)
+
+// LockFile holds the exact versions of the toolchains packages, to install the same versions on every machine
+type LockFile struct {
+	Version int `json:"version"`
+	// Toolchains are the toolchain specs, with their version, components and options (e.g. "golang:lint --no-ide")
+	Toolchains []string `json:"toolchains"`
+	// Packages are the locked versions of the packages, by package manager name then by package.
+	// The packages of the package managers selecting another one on each machine are locked by the selecting package manager name
+	// (e.g. "python-tools" rather than "uv"), so that the lock file installs the same versions whichever is available.
+	Packages map[string]map[string]string `json:"packages"`
+}
+
+// LockDrift is a package whose installed version differs from its locked version,
+// the installed version is empty when the package is not installed
+type LockDrift struct {
+	PackageManager string `json:"package_manager"`
+	Package        string `json:"package"`
+	Locked         string `json:"locked"`
+	Installed      string `json:"installed,omitempty"`
+}
+
+func (d *LockDrift) String() string {
+	installed := d.Installed
+	if installed == "" {
+		installed = "not installed"
+	}
+	return fmt.Sprintf("%s %s: locked %s, installed %s", d.PackageManager, d.Package, d.Locked, installed)
+}
+
+// NewLockFile creates an empty lock file for the given toolchains.
+func NewLockFile(toolchains []string) *LockFile {
+	return &LockFile{
+		Version:    LOCK_FILE_VERSION,
+		Toolchains: toolchains,
+		Packages:   make(map[string]map[string]string),
+	}
+}
+
+// ReadLockFile reads the lock file, it returns an error if the file does not exist or its version is not supported.
+func ReadLockFile(file string) (*LockFile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	lockFile := NewLockFile(nil)
+	if err := json.Unmarshal(data, lockFile); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", file, err)
+	}
+	if lockFile.Version != LOCK_FILE_VERSION {
+		return nil, fmt.Errorf("unsupported lock file version %d in %s, expected %d", lockFile.Version, file, LOCK_FILE_VERSION)
+	}
+	if lockFile.Packages == nil {
+		lockFile.Packages = make(map[string]map[string]string)
+	}
+	return lockFile, nil
+}
+
+// Write writes the lock file, the packages are sorted so the file can be reviewed and diffed.
+func (l *LockFile) Write(file string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
+// Set locks the package of the package manager at the version.
+func (l *LockFile) Set(pm string, pkg string, version string) {
+	if l.Packages[pm] == nil {
+		l.Packages[pm] = make(map[string]string)
+	}
+	l.Packages[pm][pkg] = version
+}
+
+// versions returns the locked versions of the packages of the package manager. The lock files written before the packages
+// were locked by the selecting package manager name are read with the name of the selected package manager.
+func (l *LockFile) versions(pm *packagemanager.PackageManager) map[string]string {
+	if versions, exists := l.Packages[pm.Name]; exists {
+		return versions
+	}
+	return l.Packages[pm.Resolve().Name]
+}
+
+// Install installs the packages with the package manager at their locked versions, the package manager being resolved
+// once the locked versions are read. The packages missing from the lock file, and the packages of the package managers
+// not supporting pinned versions, are installed at their latest version.
+func (l *LockFile) Install(pm *packagemanager.PackageManager, packages []string) []error {
+	if pm == nil {
+		return pm.Install(packages)
+	}
+	versions := l.versions(pm)
+	pm = pm.Resolve()
+	if pm.PinFormat == "" {
+		if len(versions) > 0 {
+			zap.L().Warn("Pinning versions is not supported, installing the latest versions", zap.String("package_manager", pm.Name))
+		}
+		return pm.Install(packages)
+	}
+
+	var locked, unlocked []string
+	for _, pkg := range packages {
+		if _, exists := versions[pkg]; exists {
+			locked = append(locked, pkg)
+		} else {
+			unlocked = append(unlocked, pkg)
+		}
+	}
+
+	var errs []error
+	if len(unlocked) > 0 {
+		zap.L().Warn("Packages are not locked, installing their latest version", zap.Strings("packages", unlocked), zap.String("package_manager", pm.Name))
+		errs = append(errs, pm.Install(unlocked)...)
+	}
+	if len(locked) > 0 {
+		errs = append(errs, pm.InstallPinned(locked, versions)...)
+	}
+	return errs
+}
+
+// Drift compares the installed versions of the packages with their locked versions, queried with the resolved package managers.
+// The packages missing from the lock file are ignored, errors are returned for the package managers whose
+// installed versions can not be queried.
+func (l *LockFile) Drift(packages map[*packagemanager.PackageManager][]string) ([]*LockDrift, []error) {
+	var drifts []*LockDrift
+	var errs []error
+	for pm, pmPackages := range packages {
+		versions := l.versions(pm)
+		locked := slices.DeleteFunc(slices.Clone(pmPackages), func(pkg string) bool {
+			_, exists := versions[pkg]
+			return !exists
+		})
+		if len(locked) == 0 {
+			continue
+		}
+		installed, err := pm.Resolve().Query(locked)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to check the locked versions of %s: %w", pm.Name, err))
+			continue
+		}
+		for _, pkg := range locked {
+			if installed[pkg].Version != versions[pkg] {
+				drifts = append(drifts, &LockDrift{PackageManager: pm.Name, Package: pkg, Locked: versions[pkg], Installed: installed[pkg].Version})
+			}
+		}
+	}
+	slices.SortFunc(drifts, func(a, b *LockDrift) int {
+		if c := strings.Compare(a.PackageManager, b.PackageManager); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Package, b.Package)
+	})
+	return drifts, errs
+}
@@ -0,0 +1,77 @@
+package lock
+
+import (
+	"devbox/internal/commands"
+	"devbox/internal/commands/install"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// LockToolchains resolves the installed versions of the packages of the given toolchains into a lock file.
+// The packages must be installed, and the packages of the package managers not supporting pinned versions are not locked.
+// The lock file keeps the toolchain specs, so that install --locked selects the same components and options.
+func LockToolchains(args *commands.SharedCmdArgs, toolchainNames []string) (*commands.LockFile, []error) {
+	specs, toolchains, err := install.ParseMergedToolchainSpecs(toolchainNames, args.Profile)
+	if err != nil {
+		return nil, []error{&commands.UsageError{Err: err}}
+	}
+	entries := make([]string, len(specs))
+	for i, spec := range specs {
+		entries[i] = spec.String()
+	}
+
+	lockFile := commands.NewLockFile(entries)
+	var errs []error
+	for pm, packages := range commands.ToolchainsPackages(args, toolchains...) {
+		// The packages are locked by the package manager name of the toolchains, and queried with the package manager it selects
+		resolved := pm.Resolve()
+		if resolved.PinFormat == "" {
+			zap.L().Warn("Pinning versions is not supported, the packages are not locked", zap.String("package_manager", pm.Name), zap.Strings("packages", packages))
+			continue
+		}
+		versions, err := resolved.Query(packages)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to lock the packages of %s: %w", pm.Name, err))
+			continue
+		}
+		for _, pkg := range packages {
+			if !versions[pkg].Installed {
+				errs = append(errs, fmt.Errorf("package %s is not installed with %s, install the toolchains before locking them", pkg, resolved.Name))
+				continue
+			}
+			lockFile.Set(pm.Name, pkg, versions[pkg].Version)
+		}
+	}
+	return lockFile, errs
+}
+
+// InstallLocked installs the given toolchains at the versions of the lock file, or the toolchains of the lock file when none is given.
+// The packages whose installed version differs from the lock file are reported before the installation,
+// the packages still drifting after the installation are returned as errors.
+func InstallLocked(args *commands.SharedCmdArgs, lockFile *commands.LockFile, toolchainNames []string) []error {
+	if len(toolchainNames) == 0 {
+		toolchainNames = lockFile.Toolchains
+	}
+	toolchains, err := install.ParseToolchainsWithProfile(toolchainNames, args.Profile)
+	if err != nil {
+		return []error{&commands.UsageError{Err: err}}
+	}
+
+	packages := commands.ToolchainsPackages(args, toolchains...)
+	drifts, _ := lockFile.Drift(packages)
+	for _, drift := range drifts {
+		zap.L().Warn("Package drifted from the lock file", zap.Stringer("drift", drift))
+	}
+
+	lockedArgs := *args
+	lockedArgs.Lock = lockFile
+	errs := commands.InstallToolchains(&lockedArgs, toolchains...)
+
+	drifts, driftErrs := lockFile.Drift(packages)
+	errs = append(errs, driftErrs...)
+	for _, drift := range drifts {
+		errs = append(errs, fmt.Errorf("package still drifting from the lock file after the installation: %s", drift))
+	}
+	return errs
+}
@@ -0,0 +1,174 @@
+package lock
+
+import (
+	"devbox/internal/commands"
+	"devbox/pkg/packagemanager"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	// avoid zap global logger side effects
+	zap.ReplaceGlobals(zap.NewNop())
+	os.Exit(m.Run())
+}
+
+// stubPackageManager writes a package manager script recording its arguments in the returned log file,
+// the installed versions are queried from installed.
+func stubPackageManager(t *testing.T, installed map[string]string) (*packagemanager.PackageManager, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping package manager script tests on Windows")
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "calls.log")
+	script := "#!/bin/sh\necho \"$@\" >> \"" + logFile + "\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "fakepm"), []byte(script), 0o700); err != nil {
+		t.Fatalf("failed to write package manager script: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	pm := &packagemanager.PackageManager{
+		Name:         "fakepm",
+		InstallCmd:   "install",
+		MultiInstall: true,
+		PinFormat:    "{name}=={version}",
+		QueryVersions: func(pm *packagemanager.PackageManager, packages []string) (map[string]packagemanager.VersionInfo, error) {
+			return packagemanager.MatchVersions(packages, installed, strings.ToLower), nil
+		},
+	}
+	return pm, logFile
+}
+
+func Test_LockFile_ReadWrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "devbox.lock")
+	lockFile := commands.NewLockFile([]string{"python"})
+	lockFile.Set("pip", "black", "24.4.2")
+	lockFile.Set("code", "ms-python.python", "2024.8.1")
+	if err := lockFile.Write(file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	read, err := commands.ReadLockFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(read.Toolchains, []string{"python"}) || read.Packages["pip"]["black"] != "24.4.2" || read.Packages["code"]["ms-python.python"] != "2024.8.1" {
+		t.Fatalf("unexpected lock file: %+v", read)
+	}
+
+	if err := os.WriteFile(file, []byte(`{"version": 42}`), 0644); err != nil {
+		t.Fatalf("failed to write lock file: %v", err)
+	}
+	if _, err := commands.ReadLockFile(file); err == nil || !strings.Contains(err.Error(), "unsupported lock file version 42") {
+		t.Fatalf("expected unsupported version error, got: %v", err)
+	}
+}
+
+func Test_LockFile_Install(t *testing.T) {
+	pm, logFile := stubPackageManager(t, nil)
+	lockFile := commands.NewLockFile(nil)
+	lockFile.Set(pm.Name, "black", "24.4.2")
+
+	if errs := lockFile.Install(pm, []string{"black", "ruff"}); errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("failed to read calls log: %v", err)
+	}
+	want := []string{"install ruff", "install black==24.4.2"}
+	if calls := strings.Split(strings.TrimSpace(string(data)), "\n"); !slices.Equal(calls, want) {
+		t.Fatalf("expected calls %q, got: %q", want, calls)
+	}
+}
+
+func Test_LockFile_Drift(t *testing.T) {
+	pm, _ := stubPackageManager(t, map[string]string{"black": "24.4.2", "ruff": "0.5.0"})
+	lockFile := commands.NewLockFile(nil)
+	lockFile.Set(pm.Name, "black", "24.4.2")
+	lockFile.Set(pm.Name, "ruff", "0.4.0")
+	lockFile.Set(pm.Name, "mypy", "1.10.0")
+
+	drifts, errs := lockFile.Drift(map[*packagemanager.PackageManager][]string{pm: {"black", "ruff", "mypy", "isort"}})
+	if errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	got := make([]string, len(drifts))
+	for i, drift := range drifts {
+		got[i] = drift.String()
+	}
+	want := []string{
+		"fakepm mypy: locked 1.10.0, installed not installed",
+		"fakepm ruff: locked 0.4.0, installed 0.5.0",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected drifts %q, got: %q", want, got)
+	}
+
+	unsupported := &packagemanager.PackageManager{Name: "fakepm"}
+	if _, errs := lockFile.Drift(map[*packagemanager.PackageManager][]string{unsupported: {"black"}}); len(errs) != 1 {
+		t.Fatalf("expected a query error, got: %v", errs)
+	}
+}
+
+func Test_LockFile_SelectingPackageManager(t *testing.T) {
+	pm, logFile := stubPackageManager(t, map[string]string{"black": "24.4.2"})
+	tools := &packagemanager.PackageManager{Name: "fake-tools", Candidates: []*packagemanager.PackageManager{pm}}
+
+	t.Run("locked by the selecting package manager", func(t *testing.T) {
+		t.Cleanup(func() { os.Remove(logFile) })
+		lockFile := commands.NewLockFile(nil)
+		lockFile.Set(tools.Name, "black", "24.4.2")
+
+		if errs := lockFile.Install(tools, []string{"black"}); errs != nil {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		data, err := os.ReadFile(logFile)
+		if err != nil {
+			t.Fatalf("failed to read calls log: %v", err)
+		}
+		if calls := strings.TrimSpace(string(data)); calls != "install black==24.4.2" {
+			t.Fatalf("expected the locked version to be installed with %s, got calls: %q", pm.Name, calls)
+		}
+		if drifts, errs := lockFile.Drift(map[*packagemanager.PackageManager][]string{tools: {"black"}}); drifts != nil || errs != nil {
+			t.Fatalf("expected no drift, got: %v %v", drifts, errs)
+		}
+	})
+
+	t.Run("locked by the selected package manager", func(t *testing.T) {
+		t.Cleanup(func() { os.Remove(logFile) })
+		lockFile := commands.NewLockFile(nil)
+		lockFile.Set(pm.Name, "black", "24.4.1")
+
+		if errs := lockFile.Install(tools, []string{"black"}); errs != nil {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		data, err := os.ReadFile(logFile)
+		if err != nil {
+			t.Fatalf("failed to read calls log: %v", err)
+		}
+		if calls := strings.TrimSpace(string(data)); calls != "install black==24.4.1" {
+			t.Fatalf("expected the previous lock file versions to be read, got calls: %q", calls)
+		}
+	})
+}
+
+func Test_LockToolchains_Specs(t *testing.T) {
+	args := &commands.SharedCmdArgs{SkipIde: true, UserScope: true}
+	// the packages are not installed in the tests, only the locked toolchains are checked
+	lockFile, _ := LockToolchains(args, []string{"golang:lint --no-ide", "bash"})
+	if lockFile == nil {
+		t.Fatal("expected a lock file")
+	}
+	want := []string{"golang:lint --no-ide", "bash"}
+	if !slices.Equal(lockFile.Toolchains, want) {
+		t.Fatalf("expected toolchains %q, got: %q", want, lockFile.Toolchains)
+	}
+}
@@ -0,0 +1,192 @@
+package project
+
+import (
+	"devbox/internal/commands"
+	"devbox/internal/commands/install"
+	"devbox/internal/envmanager"
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/utils"
+	"devbox/pkg/vscode"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Plan is what devbox sync installs to satisfy the project manifest
+type Plan struct {
+	// Toolchains are the specs of the manifest toolchains with at least one package missing (e.g. "go@1.22:lint --no-ide")
+	Toolchains []string
+	// Packages are the missing extra packages of the manifest, by package manager
+	Packages map[*packagemanager.PackageManager][]string
+}
+
+// IsEmpty reports whether nothing is missing.
+func (p *Plan) IsEmpty() bool {
+	return len(p.Toolchains) == 0 && len(p.Packages) == 0
+}
+
+// MarshalJSON returns the plan as JSON, the missing packages being keyed by package manager name
+func (p *Plan) MarshalJSON() ([]byte, error) {
+	packages := make(map[string][]string, len(p.Packages))
+	for pm, pmPackages := range p.Packages {
+		packages[pm.Name] = pmPackages
+	}
+	return json.Marshal(struct {
+		Toolchains []string            `json:"toolchains"`
+		Packages   map[string][]string `json:"packages"`
+	}{p.Toolchains, packages})
+}
+
+// PackageManagers returns the package managers of the missing packages, sorted by name for a stable output
+func (p *Plan) PackageManagers() []*packagemanager.PackageManager {
+	packageManagers := slices.Collect(maps.Keys(p.Packages))
+	slices.SortFunc(packageManagers, func(a, b *packagemanager.PackageManager) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return packageManagers
+}
+
+// PlanSync computes the toolchains and packages of the manifest missing on the machine.
+// Packages whose installed version can not be queried are considered missing, their installation is expected to be idempotent.
+func PlanSync(args *commands.SharedCmdArgs, manifest *Manifest) (*Plan, []error) {
+	plan := &Plan{Packages: make(map[*packagemanager.PackageManager][]string)}
+	if len(manifest.Toolchains) > 0 {
+		specs, err := manifest.toolchainSpecs(args.Profile)
+		if err != nil {
+			return nil, []error{err}
+		}
+		// The merged specs resolve each into a single toolchain, in the same order
+		toolchains, err := install.ResolveToolchainSpecs(specs, args.Profile)
+		if err != nil {
+			return nil, []error{err}
+		}
+		for i, tc := range toolchains {
+			for pm, packages := range commands.ToolchainsPackages(args, tc) {
+				if len(missingPackages(pm, packages)) > 0 {
+					// The spec keeps the version, the components and the options of the manifest entry for the installation
+					plan.Toolchains = append(plan.Toolchains, specs[i].String())
+					break
+				}
+			}
+		}
+	}
+
+	packages, errs := manifest.packageManagers(args)
+	for pm, pmPackages := range packages {
+		if missing := missingPackages(pm, pmPackages); len(missing) > 0 {
+			plan.Packages[pm] = missing
+		}
+	}
+	return plan, errs
+}
+
+// Sync installs the toolchains and packages of the manifest missing on the machine,
+// and applies the manifest environment variables and VS Code settings.
+// A toolchain is considered missing when at least one of its packages is not installed.
+func Sync(args *commands.SharedCmdArgs, manifest *Manifest) []error {
+	plan, errs := PlanSync(args, manifest)
+	if plan == nil {
+		return errs
+	}
+	packagemanager.SetTimeouts(manifest.Timeouts)
+
+	if len(manifest.Env) > 0 {
+		errs = append(errs, envmanager.SystemEnvManager(envmanager.DEFAULT_SYS_ENV_FILE).Set(manifest.Env)...)
+	}
+
+	if len(plan.Toolchains) > 0 {
+		errs = append(errs, install.InstallToolchains(args, plan.Toolchains...)...)
+	} else if len(manifest.Toolchains) > 0 {
+		zap.L().Info("The manifest toolchains are already installed", zap.Strings("toolchains", manifest.Toolchains))
+	}
+
+	// The extra packages are installed as a toolchain of their own, so they follow the same steps as the toolchains packages
+	projectToolchain := &commands.Toolchain{
+		Name:           manifest.File,
+		VSCodeSettings: manifest.VSCode.Settings,
+	}
+	projectPackageManagers := make(map[*packagemanager.PackageManager][]string)
+	for pm, packages := range plan.Packages {
+		switch pm {
+		case packagemanager.SystemPackageManager:
+			projectToolchain.InstalledPackages = packages
+		case vscode.VSCODE_PACKAGE_MANAGER:
+			projectToolchain.VSCodeExtensions = packages
+		default:
+			projectPackageManagers[pm] = packages
+		}
+	}
+	if len(projectPackageManagers) > 0 {
+		projectToolchain.PackageManagers = &projectPackageManagers
+	}
+	if len(plan.Packages) > 0 || (len(manifest.VSCode.Settings) > 0 && !args.SkipIde) {
+		errs = append(errs, commands.InstallToolchains(args, projectToolchain)...)
+	}
+
+	return errs
+}
+
+// toolchainSpecs parses the manifest toolchains, the entries of a same toolchain version being merged
+func (m *Manifest) toolchainSpecs(profile string) ([]*install.ToolchainSpec, error) {
+	specs, err := install.ParseToolchainSpecs(m.Toolchains)
+	if err != nil {
+		return nil, err
+	}
+	return install.MergeToolchainSpecs(specs, profile)
+}
+
+// packageManagers resolves the package managers of the manifest extra packages and VS Code extensions.
+// The packages of another system package manager than the detected one are skipped,
+// as well as the system packages when installing user-scoped and the extensions when skipping the IDE tools.
+func (m *Manifest) packageManagers(args *commands.SharedCmdArgs) (map[*packagemanager.PackageManager][]string, []error) {
+	packages := make(map[*packagemanager.PackageManager][]string)
+	var errs []error
+	for name, pmPackages := range m.Packages {
+		if len(pmPackages) == 0 {
+			continue
+		}
+		if name == vscode.VSCODE_PACKAGE_MANAGER.Name {
+			errs = append(errs, fmt.Errorf("%s: declare the VS Code extensions in vscode.extensions instead of packages.%s", m.File, name))
+			continue
+		}
+		if _, err := packagemanager.GetSystemPackageManager(name); err == nil {
+			if packagemanager.SystemPackageManager == nil || packagemanager.SystemPackageManager.Name != name {
+				zap.L().Info("Skipping the packages of another system package manager", zap.String("package_manager", name), zap.Strings("packages", pmPackages))
+				continue
+			}
+			if args.UserScope {
+				zap.L().Info("Installing user-scoped, skipping the system packages installation", zap.Strings("packages", pmPackages))
+				continue
+			}
+			packages[packagemanager.SystemPackageManager] = utils.MergeStringSlices(pmPackages)
+			continue
+		}
+		pm, err := packagemanager.GetLanguagePackageManager(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.File, err))
+			continue
+		}
+		packages[pm] = utils.MergeStringSlices(pmPackages)
+	}
+	if !args.SkipIde && len(m.VSCode.Extensions) > 0 {
+		packages[vscode.VSCODE_PACKAGE_MANAGER] = utils.MergeStringSlices(m.VSCode.Extensions)
+	}
+	return packages, errs
+}
+
+// missingPackages returns the packages not reported as installed by the package manager,
+// every package is returned when the installed versions can not be queried
+func missingPackages(pm *packagemanager.PackageManager, packages []string) []string {
+	versions, err := pm.Resolve().Query(packages)
+	if err != nil {
+		zap.L().Debug("Failed to query the installed packages, considering them missing", zap.String("package_manager", pm.Name), zap.Error(err))
+		return packages
+	}
+	return slices.DeleteFunc(slices.Clone(packages), func(pkg string) bool {
+		return versions[pkg].Installed
+	})
+}
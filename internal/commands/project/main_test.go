@@ -0,0 +1,81 @@
+package project
+
+import (
+	"devbox/internal/commands"
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/vscode"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_PlanSync(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping package manager script tests on Windows")
+	}
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"pip":  "#!/bin/sh\necho '[{\"name\": \"black\", \"version\": \"24.4.2\"}]'\n",
+		"code": "#!/bin/sh\necho 'ms-python.python@2024.8.1'\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0700); err != nil {
+			t.Fatalf("failed to write %s script: %v", name, err)
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	otherSystemPackageManager := "dnf"
+	if packagemanager.SystemPackageManager != nil && packagemanager.SystemPackageManager.Name == "dnf" {
+		otherSystemPackageManager = "apt"
+	}
+	manifest := &Manifest{
+		File: "devbox.yaml",
+		Packages: map[string][]string{
+			"pip":                     {"black", "ruff"},
+			otherSystemPackageManager: {"jq"},
+			"unknown":                 {"foo"},
+		},
+		VSCode: VSCodeManifest{Extensions: []string{"ms-python.python", "charliermarsh.ruff"}},
+	}
+
+	plan, errs := PlanSync(&commands.SharedCmdArgs{}, manifest)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "unsupported language package manager: unknown") {
+		t.Fatalf("expected unknown package manager error, got: %v", errs)
+	}
+	if len(plan.Toolchains) != 0 || len(plan.Packages) != 2 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if got := plan.Packages[packagemanager.PYTHON_PACKAGE_MANAGER]; !slices.Equal(got, []string{"ruff"}) {
+		t.Fatalf("expected ruff to be missing, got: %v", got)
+	}
+	if got := plan.Packages[vscode.VSCODE_PACKAGE_MANAGER]; !slices.Equal(got, []string{"charliermarsh.ruff"}) {
+		t.Fatalf("expected charliermarsh.ruff to be missing, got: %v", got)
+	}
+	if got := plan.PackageManagers(); !slices.Equal(got, []*packagemanager.PackageManager{vscode.VSCODE_PACKAGE_MANAGER, packagemanager.PYTHON_PACKAGE_MANAGER}) {
+		t.Fatalf("expected the package managers sorted by name, got: %v", got)
+	}
+	if data, err := json.Marshal(plan); err != nil || !strings.Contains(string(data), `"pip":["ruff"]`) {
+		t.Fatalf("expected the packages keyed by package manager name, got: %s, %v", data, err)
+	}
+
+	// The extensions are skipped with the IDE tools
+	plan, _ = PlanSync(&commands.SharedCmdArgs{SkipIde: true}, manifest)
+	if _, exists := plan.Packages[vscode.VSCODE_PACKAGE_MANAGER]; exists {
+		t.Fatalf("expected the extensions to be skipped, got: %+v", plan)
+	}
+
+	// The missing toolchains keep the components and options of their manifest entry
+	plan, errs = PlanSync(&commands.SharedCmdArgs{}, &Manifest{Toolchains: []string{"golang:lint --no-ide", "bash"}})
+	if errs != nil || !slices.Equal(plan.Toolchains, []string{"golang:lint --no-ide", "bash"}) {
+		t.Fatalf("expected the manifest toolchain specs, got: %v, %v", plan.Toolchains, errs)
+	}
+
+	if _, errs := PlanSync(&commands.SharedCmdArgs{}, &Manifest{Toolchains: []string{"cobol"}}); len(errs) != 1 {
+		t.Fatalf("expected an unknown toolchain error, got: %v", errs)
+	}
+}
@@ -0,0 +1,80 @@
+package project
+
+import (
+	"bytes"
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/utils"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// MANIFEST_FILE is the name of the project manifest, searched in the current directory and its parents
+	MANIFEST_FILE = utils.Getenv("DEVBOX_MANIFEST_FILE", "devbox.yaml")
+)
+
+// VSCodeManifest declares the VS Code extensions and settings required by the project
+type VSCodeManifest struct {
+	Extensions []string       `yaml:"extensions,omitempty"`
+	Settings   map[string]any `yaml:"settings,omitempty"`
+}
+
+// Manifest is the devbox.yaml project manifest, declaring the toolchains, the extra packages per package manager,
+// the VS Code extensions and settings and the environment variables required to work on the project
+type Manifest struct {
+	// File is the path the manifest was read from
+	File       string              `yaml:"-"`
+	Toolchains []string            `yaml:"toolchains,omitempty"`
+	Packages   map[string][]string `yaml:"packages,omitempty"`
+	VSCode     VSCodeManifest      `yaml:"vscode,omitempty"`
+	Env        map[string]string   `yaml:"env,omitempty"`
+	// Timeouts override the timeouts of the package managers commands, by package manager name (e.g. "dnf: 2h")
+	Timeouts map[string]time.Duration `yaml:"timeouts,omitempty"`
+}
+
+// FindManifest returns the path of the project manifest in the directory or its closest parent.
+func FindManifest(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %w", err)
+	}
+	for current := dir; ; current = filepath.Dir(current) {
+		file := filepath.Join(current, MANIFEST_FILE)
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			return file, nil
+		}
+		if parent := filepath.Dir(current); parent == current {
+			return "", fmt.Errorf("no %s found in %s or its parents", MANIFEST_FILE, dir)
+		}
+	}
+}
+
+// ReadManifest parses the project manifest file, unknown fields are rejected to report typos.
+func ReadManifest(file string) (*Manifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	manifest := &Manifest{File: file}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(manifest); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", file, err)
+	}
+	for name, timeout := range manifest.Timeouts {
+		_, systemErr := packagemanager.GetSystemPackageManager(name)
+		if _, err := packagemanager.GetLanguagePackageManager(name); err != nil && systemErr != nil {
+			return nil, fmt.Errorf("invalid timeout in manifest %s: unknown package manager %s", file, name)
+		}
+		if timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout in manifest %s: the %s timeout must be positive, got %s", file, name, timeout)
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,136 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	// avoid zap global logger side effects
+	zap.ReplaceGlobals(zap.NewNop())
+	os.Exit(m.Run())
+}
+
+func writeManifest(t *testing.T, dir string, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, MANIFEST_FILE)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	return file
+}
+
+func Test_FindManifest(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "cmd", "devbox")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("failed to create directories: %v", err)
+	}
+
+	if _, err := FindManifest(nested); err == nil || !strings.Contains(err.Error(), "no devbox.yaml found") {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+
+	want := writeManifest(t, root, "toolchains: [golang]\n")
+	if got, err := FindManifest(nested); err != nil || got != want {
+		t.Fatalf("expected %s, got: %s, %v", want, got, err)
+	}
+}
+
+func Test_ReadManifest(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		wantErrContains string
+		check           func(t *testing.T, m *Manifest)
+	}{
+		{
+			name: "full manifest",
+			content: `toolchains: [golang, python]
+packages:
+  dnf: [jq]
+  pip: [pre-commit]
+vscode:
+  extensions: [redhat.vscode-yaml]
+  settings:
+    editor.formatOnSave: true
+env:
+  GOFLAGS: -mod=vendor
+`,
+			check: func(t *testing.T, m *Manifest) {
+				if !slices.Equal(m.Toolchains, []string{"golang", "python"}) || !slices.Equal(m.Packages["pip"], []string{"pre-commit"}) {
+					t.Fatalf("unexpected toolchains or packages: %+v", m)
+				}
+				if !slices.Equal(m.VSCode.Extensions, []string{"redhat.vscode-yaml"}) || m.VSCode.Settings["editor.formatOnSave"] != true {
+					t.Fatalf("unexpected vscode section: %+v", m.VSCode)
+				}
+				if m.Env["GOFLAGS"] != "-mod=vendor" {
+					t.Fatalf("unexpected env: %v", m.Env)
+				}
+			},
+		},
+		{
+			name:    "empty manifest",
+			content: "",
+			check: func(t *testing.T, m *Manifest) {
+				if len(m.Toolchains) != 0 || len(m.Packages) != 0 {
+					t.Fatalf("expected an empty manifest, got: %+v", m)
+				}
+			},
+		},
+		{
+			name:    "timeouts",
+			content: "timeouts:\n  dnf: 2h\n  cargo: 90m\n",
+			check: func(t *testing.T, m *Manifest) {
+				if m.Timeouts["dnf"] != 2*time.Hour || m.Timeouts["cargo"] != 90*time.Minute {
+					t.Fatalf("unexpected timeouts: %v", m.Timeouts)
+				}
+			},
+		},
+		{
+			name:            "timeout of an unknown package manager",
+			content:         "timeouts:\n  maven: 1h\n",
+			wantErrContains: "unknown package manager maven",
+		},
+		{
+			name:            "invalid timeout",
+			content:         "timeouts:\n  dnf: forever\n",
+			wantErrContains: "failed to parse manifest",
+		},
+		{
+			name:            "negative timeout",
+			content:         "timeouts:\n  dnf: -1h\n",
+			wantErrContains: "the dnf timeout must be positive",
+		},
+		{
+			name:            "unknown field",
+			content:         "toolchain: [golang]\n",
+			wantErrContains: "field toolchain not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeManifest(t, t.TempDir(), tt.content)
+			manifest, err := ReadManifest(file)
+			if tt.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if manifest.File != file {
+				t.Fatalf("expected file %s, got: %s", file, manifest.File)
+			}
+			tt.check(t, manifest)
+		})
+	}
+}
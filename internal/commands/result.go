@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/progress"
+	"devbox/pkg/utils"
+	"errors"
+	"slices"
+	"time"
+)
+
+// Exit codes of devbox, documented in the README
+const (
+	// EXIT_SUCCESS is returned when every task succeeded
+	EXIT_SUCCESS = 0
+	// EXIT_FAILURE is returned when the command failed, without any task succeeding
+	EXIT_FAILURE = 1
+	// EXIT_USAGE is returned on invalid flags, arguments or toolchains
+	EXIT_USAGE = 2
+	// EXIT_PARTIAL is returned when some tasks failed while others succeeded
+	EXIT_PARTIAL = 3
+	// EXIT_ENVIRONMENT is returned when the environment prevents devbox from running, e.g. outside of a distrobox or without sudo
+	EXIT_ENVIRONMENT = 4
+)
+
+// Statuses of the command results
+const (
+	STATUS_SUCCEEDED = "succeeded"
+	STATUS_PARTIAL   = "partial"
+	STATUS_FAILED    = "failed"
+)
+
+// UsageError is an error of the toolchains or arguments given by the user, e.g. an unknown toolchain
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string { return e.Err.Error() }
+func (e *UsageError) Unwrap() error { return e.Err }
+
+// EnvironmentError is an error of the environment devbox runs in, e.g. a missing distrobox command on the host
+type EnvironmentError struct {
+	Err error
+}
+
+func (e *EnvironmentError) Error() string { return e.Err.Error() }
+func (e *EnvironmentError) Unwrap() error { return e.Err }
+
+// Result is the structured result of a command, printed with --output json
+type Result struct {
+	Command  string `json:"command"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+	// Duration is the duration of the command in seconds
+	Duration float64                 `json:"duration"`
+	Tasks    []*progress.TaskReport  `json:"tasks"`
+	Retries  []*packagemanager.Retry `json:"retries,omitempty"`
+	Errors   []*ErrorReport          `json:"errors,omitempty"`
+	Data     any                     `json:"data,omitempty"`
+}
+
+// ErrorReport is an error of a command, with the package manager, the packages and the toolchains it belongs to when known
+type ErrorReport struct {
+	Message        string   `json:"message"`
+	Kind           string   `json:"kind"`
+	PackageManager string   `json:"package_manager,omitempty"`
+	Packages       []string `json:"packages,omitempty"`
+	Toolchains     []string `json:"toolchains,omitempty"`
+}
+
+// NewResult builds the result of the command started at the given time, from its errors, the reports of its tasks
+// and its retried package manager commands. The data is the command specific output (e.g. the doctor checks).
+func NewResult(command string, started time.Time, errs []error, data any) *Result {
+	result := &Result{
+		Command:  command,
+		Duration: time.Since(started).Seconds(),
+		Tasks:    progress.Tasks(),
+		Retries:  packagemanager.Retries(),
+		Data:     data,
+	}
+	for _, err := range errs {
+		if err != nil {
+			result.Errors = append(result.Errors, newErrorReport(err, result.Tasks))
+		}
+	}
+	result.ExitCode = ExitCode(errs, result.Tasks)
+	switch result.ExitCode {
+	case EXIT_SUCCESS:
+		result.Status = STATUS_SUCCEEDED
+	case EXIT_PARTIAL:
+		result.Status = STATUS_PARTIAL
+	default:
+		result.Status = STATUS_FAILED
+	}
+	return result
+}
+
+// ExitCode returns the exit code of a command from its errors and the reports of its tasks:
+// EXIT_USAGE and EXIT_ENVIRONMENT take precedence, then EXIT_PARTIAL when a task succeeded, and EXIT_FAILURE otherwise.
+func ExitCode(errs []error, tasks []*progress.TaskReport) int {
+	errs = slices.DeleteFunc(slices.Clone(errs), func(err error) bool { return err == nil })
+	if len(errs) == 0 {
+		return EXIT_SUCCESS
+	}
+	if slices.ContainsFunc(errs, IsUsageError) {
+		return EXIT_USAGE
+	}
+	if slices.ContainsFunc(errs, IsEnvironmentError) {
+		return EXIT_ENVIRONMENT
+	}
+	if slices.ContainsFunc(tasks, func(task *progress.TaskReport) bool { return task.Status == progress.Succeeded }) {
+		return EXIT_PARTIAL
+	}
+	return EXIT_FAILURE
+}
+
+// IsUsageError reports whether the error comes from the toolchains or arguments given by the user
+func IsUsageError(err error) bool {
+	var usageErr *UsageError
+	return errors.As(err, &usageErr) || errors.Is(err, ErrNoToolchain)
+}
+
+// IsEnvironmentError reports whether the error comes from the environment devbox runs in rather than from a package
+func IsEnvironmentError(err error) bool {
+	var environmentErr *EnvironmentError
+	return errors.As(err, &environmentErr) || errors.Is(err, utils.ErrDistroboxNotAvailable) || errors.Is(err, packagemanager.ErrUnsupportedPackageManager) ||
+		errors.Is(err, packagemanager.ErrSudoPassword) || errors.Is(err, packagemanager.ErrSudoNotInstalled)
+}
+
+// newErrorReport returns the report of the error, its toolchains are the ones of the task of its packages
+func newErrorReport(err error, tasks []*progress.TaskReport) *ErrorReport {
+	report := &ErrorReport{Message: err.Error(), Kind: errorKind(err)}
+	var packageErr *packagemanager.PackageError
+	if !errors.As(err, &packageErr) {
+		return report
+	}
+	report.PackageManager, report.Packages = packageErr.PackageManager, packageErr.Packages
+	for _, task := range tasks {
+		if task.PackageManager == packageErr.PackageManager && len(packageErr.Packages) > 0 && slices.Contains(task.Packages, packageErr.Packages[0]) {
+			report.Toolchains = task.Toolchains
+			break
+		}
+	}
+	return report
+}
+
+// errorKind returns the kind of the error reported in the results: usage, environment, timeout or failure
+func errorKind(err error) string {
+	switch {
+	case IsUsageError(err):
+		return "usage"
+	case IsEnvironmentError(err):
+		return "environment"
+	case errors.Is(err, packagemanager.ErrCommandTimeout):
+		return "timeout"
+	default:
+		return "failure"
+	}
+}
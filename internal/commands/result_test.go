@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/progress"
+	"devbox/pkg/utils"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_ExitCode(t *testing.T) {
+	succeeded := &progress.TaskReport{Name: "dnf: 2 packages", Status: progress.Succeeded}
+	failed := &progress.TaskReport{Name: "cargo: 1 package", Status: progress.Failed}
+	packageErr := &packagemanager.PackageError{PackageManager: "cargo", Packages: []string{"ripgrep"}, Err: errors.New("failed to install")}
+	tests := []struct {
+		name  string
+		errs  []error
+		tasks []*progress.TaskReport
+		want  int
+	}{
+		{name: "success", errs: []error{nil}, tasks: []*progress.TaskReport{succeeded}, want: EXIT_SUCCESS},
+		{name: "partial", errs: []error{packageErr}, tasks: []*progress.TaskReport{succeeded, failed}, want: EXIT_PARTIAL},
+		{name: "failure", errs: []error{packageErr}, tasks: []*progress.TaskReport{failed}, want: EXIT_FAILURE},
+		{name: "failure without task", errs: []error{errors.New("failed to write the env file")}, want: EXIT_FAILURE},
+		{name: "usage", errs: []error{&UsageError{Err: errors.New("unknown toolchain: cobol")}}, want: EXIT_USAGE},
+		{name: "no toolchain", errs: []error{ErrNoToolchain}, want: EXIT_USAGE},
+		{name: "environment", errs: []error{packageErr, &packagemanager.PackageError{Err: packagemanager.ErrSudoPassword}}, tasks: []*progress.TaskReport{succeeded, failed}, want: EXIT_ENVIRONMENT},
+		{name: "outside distrobox", errs: []error{fmt.Errorf("export: %w", utils.ErrDistroboxNotAvailable)}, want: EXIT_ENVIRONMENT},
+		{name: "missing command", errs: []error{&EnvironmentError{Err: errors.New("distrobox command is not available")}}, want: EXIT_ENVIRONMENT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.errs, tt.tasks); got != tt.want {
+				t.Fatalf("expected exit code %d, got: %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_NewResult(t *testing.T) {
+	task := progress.Start(&progress.Task{Name: "cargo: 2 packages", PackageManager: "cargo", Packages: []string{"ripgrep", "bat"}, Toolchains: []string{"rust"}})
+	errs := []error{
+		&packagemanager.PackageError{PackageManager: "cargo", Packages: []string{"bat"}, Err: fmt.Errorf("failed: %w", packagemanager.ErrCommandTimeout)},
+		errors.New("failed to update the settings"),
+	}
+	task.Done(errs[:1])
+
+	result := NewResult("install", time.Now().Add(-time.Second), errs, nil)
+	if result.Command != "install" || result.Status != STATUS_FAILED || result.ExitCode != EXIT_FAILURE || result.Duration < 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got: %+v", result.Errors)
+	}
+	timeout := result.Errors[0]
+	if timeout.Kind != "timeout" || timeout.PackageManager != "cargo" || !slices.Equal(timeout.Packages, []string{"bat"}) || !slices.Equal(timeout.Toolchains, []string{"rust"}) {
+		t.Fatalf("expected the timeout of bat in the rust toolchain, got: %+v", timeout)
+	}
+	if other := result.Errors[1]; other.Kind != "failure" || other.PackageManager != "" || other.Message != "failed to update the settings" {
+		t.Fatalf("unexpected error report: %+v", other)
+	}
+}
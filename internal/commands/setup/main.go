@@ -132,7 +132,8 @@ func SetupDevbox(args *commands.SharedCmdArgs) []error {
 	if !args.SkipIde {
 		maxSends += 2 // vscode extension install + settings update
 	}
-	if !args.NoExport {
+	shouldExport := args.ShouldExport()
+	if shouldExport {
 		maxSends += 2 // export binaries + export apps
 	}
 	errChan := make(chan []error, maxSends) // Channel to collect errors from goroutines
@@ -142,8 +143,15 @@ func SetupDevbox(args *commands.SharedCmdArgs) []error {
 		DEFAULT_DEV_APPS = append(DEFAULT_DEV_APPS, DEFAULT_IDE)
 	}
 
-	// Install generic utility software development / unix binaries
-	errChan <- packagemanager.SystemPackageManager.Install(DEFAULT_DEV_BINARIES)
+	// Install generic utility software development / unix binaries, unless they were baked in the container image
+	// or installing user-scoped, as system packages require root privileges
+	if args.UserScope {
+		zap.L().Info("Installing user-scoped, skipping the system packages installation")
+	} else if !commands.IsBakedInImage(commands.IMAGE_SETUP_ENTRY) {
+		errChan <- packagemanager.SystemPackageManager.Install(DEFAULT_DEV_BINARIES)
+	} else if !args.SkipIde {
+		errChan <- packagemanager.SystemPackageManager.Install([]string{DEFAULT_IDE})
+	}
 
 	// Install the VSCode extensions for Go development
 	if !args.SkipIde {
@@ -160,7 +168,7 @@ func SetupDevbox(args *commands.SharedCmdArgs) []error {
 	}
 
 	// Export the generic development binaries to the user's environment
-	if !args.NoExport {
+	if shouldExport {
 		wg.Add(2)
 		go func() {
 			defer wg.Done()
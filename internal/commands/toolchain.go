@@ -2,14 +2,119 @@ package commands
 
 import (
 	"devbox/pkg/packagemanager"
+	"devbox/pkg/progress"
 	"devbox/pkg/utils"
 	"devbox/pkg/vscode"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// COMPONENT_CORE is the name of the toolchain packages installed whatever the selected components
+	COMPONENT_CORE = "core"
+
+	// PROFILE_MINIMAL only installs the core system packages of the toolchains and their exports
+	PROFILE_MINIMAL = "minimal"
+	// PROFILE_DEFAULT installs the core packages and the default components of the toolchains
+	PROFILE_DEFAULT = "default"
+	// PROFILE_FULL installs the core packages and every component of the toolchains
+	PROFILE_FULL = "full"
+)
+
+var (
+	skippedExportsWarning sync.Once
+
+	// PROFILES are the supported toolchain profiles
+	PROFILES = []string{PROFILE_MINIMAL, PROFILE_DEFAULT, PROFILE_FULL}
 )
 
 type SharedCmdArgs struct {
-	SkipIde  bool
-	NoExport bool
+	SkipIde   bool
+	NoExport  bool
+	UserScope bool
+	// Profile selects the components of the toolchains given without explicit components
+	Profile string
+	// Lock holds the versions the packages are installed at, the latest versions are installed when nil
+	Lock *LockFile
+}
+
+// ShouldExport reports whether the binaries and applications must be exported to the host system.
+// Exports are only possible from inside a distrobox, a single warning is logged when they are skipped.
+func (args *SharedCmdArgs) ShouldExport() bool {
+	if args.NoExport {
+		return false
+	}
+	env := utils.CurrentEnvironment()
+	if env.Distrobox {
+		return true
+	}
+	skippedExportsWarning.Do(func() {
+		zap.L().Warn("devbox is not running inside a distrobox, skipping the exports to the host system", zap.String("environment", env.String()))
+	})
+	return false
+}
+
+// toolchainsNames returns the names of the toolchains matching the filter, suffixed with their selected version (e.g. "java@17")
+func toolchainsNames(toolchains []*Toolchain, filter func(tc *Toolchain) bool) []string {
+	var names []string
+	for _, tc := range toolchains {
+		if filter(tc) {
+			names = append(names, tc.Name)
+		}
+	}
+	return names
+}
+
+// countOf returns the count followed by the singular or plural noun (e.g. "3 packages")
+func countOf(count int, singular string, plural string) string {
+	if count == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", count, plural)
+}
+
+// installPackages installs the packages with the package manager, at the versions of the lock file if one is used.
+// The lock file reads the versions locked for the package manager itself, before resolving it.
+// The binaries of the exported packages installed by the package managers outside of the PATH (e.g. the downloads) are then exported,
+// the exported packages being the packages of the toolchains not installed with --no-export.
+// The installation is displayed as a progress task of the toolchains, and its errors carry the package manager and the packages.
+func (args *SharedCmdArgs) installPackages(pm *packagemanager.PackageManager, packages []string, exported []string, toolchains []string) (errs []error) {
+	resolved := pm.Resolve()
+	name := "unsupported package manager"
+	if resolved != nil {
+		name = resolved.Name
+	}
+	task := progress.Start(&progress.Task{
+		Name:           fmt.Sprintf("%s: %s", name, countOf(len(packages), "package", "packages")),
+		PackageManager: name,
+		Packages:       packages,
+		Toolchains:     toolchains,
+	})
+	defer func() {
+		for i, err := range errs {
+			var packageErr *packagemanager.PackageError
+			if err != nil && !errors.As(err, &packageErr) {
+				errs[i] = &packagemanager.PackageError{PackageManager: name, Packages: packages, Err: err}
+			}
+		}
+		task.Done(errs)
+	}()
+
+	if args.Lock == nil {
+		errs = resolved.Install(packages)
+	} else {
+		errs = args.Lock.Install(pm, packages)
+	}
+	if errs == nil && resolved != nil && resolved.InstalledBinaries != nil && len(exported) > 0 && args.ShouldExport() {
+		errs = utils.ExportDistroboxBinaries(resolved.InstalledBinaries(resolved, exported))
+	}
+	return errs
 }
 
 type Toolchain struct {
@@ -18,11 +123,98 @@ type Toolchain struct {
 	InstalledPackages    []string
 	ExportedBinaries     []string
 	ExportedApplications []string
+	ExportOptions        map[string]*utils.DistroboxExportOptions
 	PackageManagers      *map[*packagemanager.PackageManager][]string
 	VSCodeExtensions     []string
 	VSCodeSettings       map[string]any
 	EnvironmentVariables map[string]string
 	PostInstallHooks     *func(args *SharedCmdArgs) []error
+	// Components are the optional packages of the toolchain, installed on top of its core packages
+	Components map[string]*Component
+	// SelectedComponents are the sorted names of the components added to the core packages, empty for the core packages only
+	SelectedComponents []string
+	// Versions are the versions of the toolchain selectable with a "name@version" spec
+	Versions *ToolchainVersions
+	// Version is the selected version, which also suffixes the Name (e.g. "java@17"), empty for the distribution default version
+	Version string
+	// LinkedBinaries are the version suffixed links to create before the exports, by link path
+	LinkedBinaries map[string]string
+	// NoExport skips the exports of the toolchain, including the binaries installed outside of the PATH (e.g. the downloads)
+	NoExport bool
+}
+
+// Component is a named group of optional packages of a toolchain (e.g. "lint", "debug", "docs" or "gui")
+type Component struct {
+	Description string
+	// Default components are installed by the default profile
+	Default              bool
+	InstalledPackages    []string
+	ExportedBinaries     []string
+	ExportedApplications []string
+	PackageManagers      map[*packagemanager.PackageManager][]string
+	VSCodeExtensions     []string
+	// ExportOptions are the export options of the component binaries and applications, by name
+	ExportOptions map[string]*utils.DistroboxExportOptions
+}
+
+// ProfileComponents returns the sorted names of the components installed by the profile.
+func (it *Toolchain) ProfileComponents(profile string) ([]string, error) {
+	var names []string
+	switch profile {
+	case PROFILE_MINIMAL:
+	case PROFILE_DEFAULT, PROFILE_FULL:
+		for name, component := range it.Components {
+			if component.Default || profile == PROFILE_FULL {
+				names = append(names, name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown profile %q, expected one of %s", profile, strings.Join(PROFILES, ", "))
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// WithComponents returns a copy of the toolchain with the packages of the given components added to its core packages.
+// The core component is always installed, the toolchain itself is returned when no other component is given.
+func (it *Toolchain) WithComponents(names ...string) (*Toolchain, error) {
+	names = slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+		return name == COMPONENT_CORE
+	})
+	if len(names) == 0 {
+		return it, nil
+	}
+
+	resolved := *it
+	packageManagers := make(map[*packagemanager.PackageManager][]string)
+	if it.PackageManagers != nil {
+		maps.Copy(packageManagers, *it.PackageManagers)
+	}
+	resolved.ExportOptions = maps.Clone(it.ExportOptions)
+	for _, name := range names {
+		component, exists := it.Components[name]
+		if !exists {
+			available := append([]string{COMPONENT_CORE}, slices.Sorted(maps.Keys(it.Components))...)
+			return nil, fmt.Errorf("unknown component %q for toolchain %s, expected one of %s", name, it.Name, strings.Join(available, ", "))
+		}
+		resolved.InstalledPackages = utils.MergeStringSlices(resolved.InstalledPackages, component.InstalledPackages)
+		resolved.ExportedBinaries = utils.MergeStringSlices(resolved.ExportedBinaries, component.ExportedBinaries)
+		resolved.ExportedApplications = utils.MergeStringSlices(resolved.ExportedApplications, component.ExportedApplications)
+		resolved.VSCodeExtensions = utils.MergeStringSlices(resolved.VSCodeExtensions, component.VSCodeExtensions)
+		for pm, packages := range component.PackageManagers {
+			packageManagers[pm] = utils.MergeStringSlices(packageManagers[pm], packages)
+		}
+		if len(component.ExportOptions) > 0 && resolved.ExportOptions == nil {
+			resolved.ExportOptions = make(map[string]*utils.DistroboxExportOptions, len(component.ExportOptions))
+		}
+		maps.Copy(resolved.ExportOptions, component.ExportOptions)
+	}
+	if len(packageManagers) > 0 {
+		resolved.PackageManagers = &packageManagers
+	}
+	resolved.SelectedComponents = utils.MergeStringSlices(it.SelectedComponents, names)
+	slices.Sort(resolved.SelectedComponents)
+	return &resolved, nil
 }
 
 // Install installs the toolchain by performing the following steps:
@@ -66,7 +258,11 @@ func (it *Toolchain) Install(args *SharedCmdArgs) []error {
 			go func(pm *packagemanager.PackageManager, pkgs []string) {
 				defer wgOverall.Done()
 				wgPackages.Wait()
-				errChan <- pm.Install(pkgs)
+				var exported []string
+				if !it.NoExport {
+					exported = pkgs
+				}
+				errChan <- args.installPackages(pm, pkgs, exported, []string{it.Name})
 			}(pkgManager, packages)
 		}
 	}
@@ -88,31 +284,33 @@ func (it *Toolchain) Install(args *SharedCmdArgs) []error {
 
 // InstallSystemPackages installs the system packages specified by the toolchain.
 // It uses the system package manager to install the packages.
+// System packages are skipped when installing user-scoped.
 func (it *Toolchain) InstallSystemPackages(args *SharedCmdArgs) []error {
-	if len(it.InstalledPackages) > 0 {
-		return packagemanager.SystemPackageManager.Install(it.InstalledPackages)
+	if len(it.InstalledPackages) > 0 && !args.UserScope {
+		return args.installPackages(packagemanager.SystemPackageManager, it.InstalledPackages, nil, []string{it.Name})
 	}
 	return nil
 }
 
-// ExportSystemPackages exports the binaries and applications specified by the toolchain.
+// ExportSystemPackages exports the binaries and applications specified by the toolchain,
+// applying the export options registered for each of them.
 func (it *Toolchain) ExportSystemPackages(args *SharedCmdArgs) []error {
 	mergedSystemPackages := append(it.ExportedBinaries, it.ExportedApplications...)
-	if !args.NoExport && len(mergedSystemPackages) > 0 {
+	if len(mergedSystemPackages) > 0 && args.ShouldExport() {
 		var wg sync.WaitGroup
 		errChan := make(chan []error, 2)
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			errChan <- utils.ExportDistroboxBinaries(mergedSystemPackages)
+			errChan <- utils.ExportDistroboxBinariesWithOptions(mergedSystemPackages, it.ExportOptions)
 		}()
 
 		if len(it.ExportedApplications) > 0 {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				errChan <- utils.ExportDistroboxApplications(it.ExportedApplications)
+				errChan <- utils.ExportDistroboxApplicationsWithOptions(it.ExportedApplications, it.ExportOptions)
 			}()
 		}
 
@@ -139,7 +337,7 @@ func (it *Toolchain) InstallIDETools(args *SharedCmdArgs) []error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			errChan <- vscode.VSCODE_PACKAGE_MANAGER.Install(it.VSCodeExtensions)
+			errChan <- args.installPackages(vscode.VSCODE_PACKAGE_MANAGER, it.VSCodeExtensions, nil, []string{it.Name})
 		}()
 	}
 
@@ -2,11 +2,14 @@ package commands
 
 import (
 	"devbox/pkg/packagemanager"
+	"devbox/pkg/progress"
 	"devbox/pkg/utils"
 	"devbox/pkg/vscode"
 	"errors"
 	"maps"
 	"sync"
+
+	"go.uber.org/zap"
 )
 
 var (
@@ -15,39 +18,49 @@ var (
 
 // InstallToolchains installs the given toolchains by performing the following steps:
 // 1. Install the system packages specified by the toolchains.
-// 2. Export the binaries and applications specified by the toolchains.
+// 2. Link the versioned binaries, then export the binaries and applications specified by the toolchains.
 // 3. Install the IDE tools (like VSCode extensions) specified by the toolchains.
 // 4. Install the recommended development packages using the toolchains' package managers.
 // 5. Run any extra installation steps specified by the toolchains.
 // It uses goroutines to perform steps 1, 2, and 3 in parallel for better performance.
+// Steps 1 and 4 are skipped for the toolchains already baked in the container image,
+// step 1 is also skipped when installing user-scoped as system packages require root privileges.
 func InstallToolchains(args *SharedCmdArgs, toolchains ...*Toolchain) []error {
 	if len(toolchains) == 0 {
 		return []error{ErrNoToolchain}
 	}
+	notBakedToolchains := filterBakedToolchains(toolchains)
+
 	errChan := make(chan []error, 4)
 	wgPackages := sync.WaitGroup{}
 	wgOverall := sync.WaitGroup{}
 
-	wgPackages.Add(1)
-	wgOverall.Add(1)
-	go func() {
-		defer wgPackages.Done()
-		defer wgOverall.Done()
-		errChan <- InstallToolchainsBinaries(toolchains...)
-	}()
+	if len(notBakedToolchains) > 0 {
+		if !args.UserScope {
+			wgPackages.Add(1)
+			wgOverall.Add(1)
+			go func() {
+				defer wgPackages.Done()
+				defer wgOverall.Done()
+				errChan <- InstallToolchainsBinaries(args, notBakedToolchains...)
+			}()
+		} else {
+			zap.L().Info("Installing user-scoped, skipping the system packages installation")
+		}
 
-	wgOverall.Add(1)
-	go func() {
-		defer wgOverall.Done()
-		wgPackages.Wait()
-		errChan <- ExportToolchainsPackages(args, toolchains...)
-	}()
+		wgOverall.Add(1)
+		go func() {
+			defer wgOverall.Done()
+			wgPackages.Wait()
+			errChan <- InstallToolchainsPackages(args, notBakedToolchains...)
+		}()
+	}
 
 	wgOverall.Add(1)
 	go func() {
 		defer wgOverall.Done()
 		wgPackages.Wait()
-		errChan <- InstallToolchainsPackages(toolchains...)
+		errChan <- append(LinkToolchainsBinaries(toolchains...), ExportToolchainsPackages(args, toolchains...)...)
 	}()
 
 	wgOverall.Add(1)
@@ -62,8 +75,8 @@ func InstallToolchains(args *SharedCmdArgs, toolchains ...*Toolchain) []error {
 }
 
 // InstallToolchainsBinaries installs the system packages specified by the given toolchains.
-// It uses the system package manager to install the packages.
-func InstallToolchainsBinaries(toolchains ...*Toolchain) []error {
+// It uses the system package manager to install the packages, at their locked versions when a lock file is used.
+func InstallToolchainsBinaries(args *SharedCmdArgs, toolchains ...*Toolchain) []error {
 	if len(toolchains) == 0 {
 		return []error{ErrNoToolchain}
 	}
@@ -72,12 +85,18 @@ func InstallToolchainsBinaries(toolchains ...*Toolchain) []error {
 	for i, tc := range toolchains {
 		toolChainsRawMergedBinaries[i] = tc.InstalledPackages
 	}
-	return packagemanager.SystemPackageManager.Install(utils.MergeStringSlices(toolChainsRawMergedBinaries...))
+	packages := utils.MergeStringSlices(toolChainsRawMergedBinaries...)
+	if len(packages) == 0 {
+		return nil
+	}
+	return args.installPackages(packagemanager.SystemPackageManager, packages, nil,
+		toolchainsNames(toolchains, func(tc *Toolchain) bool { return len(tc.InstalledPackages) > 0 }))
 }
 
 // ExportToolchainsPackages exports the binaries and applications specified by the given toolchains.
+// Exports are skipped when devbox is not running inside a distrobox.
 func ExportToolchainsPackages(args *SharedCmdArgs, toolchains ...*Toolchain) []error {
-	if args.NoExport {
+	if !args.ShouldExport() {
 		return nil
 	}
 
@@ -87,16 +106,44 @@ func ExportToolchainsPackages(args *SharedCmdArgs, toolchains ...*Toolchain) []e
 
 	mergedExportedBinaries := make([][]string, len(toolchains))
 	mergedExportedApplications := make([][]string, len(toolchains))
+	mergedExportOptions := make(map[string]*utils.DistroboxExportOptions)
 
 	for i, tc := range toolchains {
 		mergedExportedBinaries[i] = append(tc.ExportedBinaries, tc.ExportedApplications...)
 		mergedExportedApplications[i] = tc.ExportedApplications
+		maps.Copy(mergedExportOptions, tc.ExportOptions)
 	}
 
+	// The binaries and applications exports each run from a single list snapshot, through a bounded worker pool
+	var wg sync.WaitGroup
 	errChan := make(chan []error, 2)
-	errChan <- utils.ExportDistroboxBinaries(utils.MergeStringSlices(mergedExportedBinaries...))
-	errChan <- utils.ExportDistroboxApplications(utils.MergeStringSlices(mergedExportedApplications...))
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		binaries := utils.MergeStringSlices(mergedExportedBinaries...)
+		var task *progress.Task
+		if len(binaries) > 0 {
+			task = progress.Start(&progress.Task{Name: "export: " + countOf(len(binaries), "binary", "binaries"), PackageManager: utils.DISTROBOX_EXPORT_COMMAND,
+				Packages: binaries, Toolchains: toolchainsNames(toolchains, func(tc *Toolchain) bool { return len(tc.ExportedBinaries)+len(tc.ExportedApplications) > 0 })})
+		}
+		errs := utils.ExportDistroboxBinariesWithOptions(binaries, mergedExportOptions)
+		task.Done(errs)
+		errChan <- errs
+	}()
+	go func() {
+		defer wg.Done()
+		applications := utils.MergeStringSlices(mergedExportedApplications...)
+		var task *progress.Task
+		if len(applications) > 0 {
+			task = progress.Start(&progress.Task{Name: "export: " + countOf(len(applications), "application", "applications"), PackageManager: utils.DISTROBOX_EXPORT_COMMAND,
+				Packages: applications, Toolchains: toolchainsNames(toolchains, func(tc *Toolchain) bool { return len(tc.ExportedApplications) > 0 })})
+		}
+		errs := utils.ExportDistroboxApplicationsWithOptions(applications, mergedExportOptions)
+		task.Done(errs)
+		errChan <- errs
+	}()
 
+	wg.Wait()
 	close(errChan)
 	return utils.MergeErrors(errChan)
 }
@@ -125,7 +172,8 @@ func InstallToolchainsIDETools(args *SharedCmdArgs, toolchains ...*Toolchain) []
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			errChan <- vscode.VSCODE_PACKAGE_MANAGER.Install(unDuplicatedPlugins)
+			errChan <- args.installPackages(vscode.VSCODE_PACKAGE_MANAGER, unDuplicatedPlugins, nil,
+				toolchainsNames(toolchains, func(tc *Toolchain) bool { return len(tc.VSCodeExtensions) > 0 }))
 		}()
 	}
 
@@ -133,7 +181,15 @@ func InstallToolchainsIDETools(args *SharedCmdArgs, toolchains ...*Toolchain) []
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			errChan <- []error{vscode.SystemVSCode.UpdateSettings(ideSettingsMerged)}
+			task := progress.Start(&progress.Task{Name: "code: settings",
+				Toolchains: toolchainsNames(toolchains, func(tc *Toolchain) bool { return len(tc.VSCodeSettings) > 0 })})
+			err := vscode.SystemVSCode.UpdateSettings(ideSettingsMerged)
+			if err != nil {
+				task.Done([]error{err})
+			} else {
+				task.Done(nil)
+			}
+			errChan <- []error{err}
 		}()
 	}
 
@@ -143,14 +199,16 @@ func InstallToolchainsIDETools(args *SharedCmdArgs, toolchains ...*Toolchain) []
 }
 
 // InstallToolchainsPackages installs the recommended development packages using the package managers specified by the given toolchains.
-// Every installation is done in parallel using goroutines.
-func InstallToolchainsPackages(toolchains ...*Toolchain) []error {
+// Every installation is done in parallel using goroutines, the package managers installed by others wait for them.
+func InstallToolchainsPackages(args *SharedCmdArgs, toolchains ...*Toolchain) []error {
 	if len(toolchains) == 0 {
 		return []error{ErrNoToolchain}
 	}
 
 	// Group toolchains by their package manager to avoid duplicate installations
 	packageManagerToPackages := make(map[*packagemanager.PackageManager][]string)
+	// The packages of the toolchains installed with --no-export are installed without exporting their binaries
+	packageManagerToExported := make(map[*packagemanager.PackageManager][]string)
 	for _, tc := range toolchains {
 		if tc.PackageManagers != nil {
 			for pkgManager, packages := range *tc.PackageManagers {
@@ -160,10 +218,25 @@ func InstallToolchainsPackages(toolchains ...*Toolchain) []error {
 				} else {
 					packageManagerToPackages[pkgManager] = packages
 				}
+				if !tc.NoExport {
+					packageManagerToExported[pkgManager] = utils.MergeStringSlices(packageManagerToExported[pkgManager], packages)
+				}
 			}
 		}
 	}
 
+	// The package managers installed by others (e.g. cargo by rustup) wait for them
+	provided := make(map[string]struct{})
+	var wgProviders sync.WaitGroup
+	for pkgManager := range packageManagerToPackages {
+		if len(pkgManager.Provides) > 0 {
+			wgProviders.Add(1)
+		}
+		for _, name := range pkgManager.Provides {
+			provided[name] = struct{}{}
+		}
+	}
+
 	errChan := make(chan []error, len(packageManagerToPackages))
 	var wg sync.WaitGroup
 
@@ -171,7 +244,18 @@ func InstallToolchainsPackages(toolchains ...*Toolchain) []error {
 		wg.Add(1)
 		go func(pm *packagemanager.PackageManager, pkgs []string) {
 			defer wg.Done()
-			errChan <- pm.Install(pkgs)
+			if len(pm.Provides) > 0 {
+				defer wgProviders.Done()
+			} else if _, isProvided := provided[pm.Name]; isProvided {
+				wgProviders.Wait()
+			}
+			errChan <- args.installPackages(pm, pkgs, packageManagerToExported[pm], toolchainsNames(toolchains, func(tc *Toolchain) bool {
+				if tc.PackageManagers == nil {
+					return false
+				}
+				_, uses := (*tc.PackageManagers)[pm]
+				return uses
+			}))
 		}(pkgManager, packages)
 	}
 
@@ -179,3 +263,31 @@ func InstallToolchainsPackages(toolchains ...*Toolchain) []error {
 	close(errChan)
 	return utils.MergeErrors(errChan)
 }
+
+// ToolchainsPackages groups the packages of the given toolchains by package manager: the system packages,
+// the language packages and the VS Code extensions.
+// The packages baked in the container image are skipped, as well as the system packages when installing user-scoped
+// and the VS Code extensions when skipping the IDE tools. The package managers selecting another one (e.g. python-tools)
+// are kept as is, to be resolved when their packages are installed or queried.
+func ToolchainsPackages(args *SharedCmdArgs, toolchains ...*Toolchain) map[*packagemanager.PackageManager][]string {
+	packageManagerToPackages := make(map[*packagemanager.PackageManager][]string)
+	if !args.SkipIde {
+		for _, tc := range toolchains {
+			packageManagerToPackages[vscode.VSCODE_PACKAGE_MANAGER] = utils.MergeStringSlices(packageManagerToPackages[vscode.VSCODE_PACKAGE_MANAGER], tc.VSCodeExtensions)
+		}
+	}
+	for _, tc := range filterBakedToolchains(toolchains) {
+		if !args.UserScope && packagemanager.SystemPackageManager != nil {
+			packageManagerToPackages[packagemanager.SystemPackageManager] = utils.MergeStringSlices(packageManagerToPackages[packagemanager.SystemPackageManager], tc.InstalledPackages)
+		}
+		if tc.PackageManagers != nil {
+			for pm, packages := range *tc.PackageManagers {
+				packageManagerToPackages[pm] = utils.MergeStringSlices(packageManagerToPackages[pm], packages)
+			}
+		}
+	}
+	maps.DeleteFunc(packageManagerToPackages, func(_ *packagemanager.PackageManager, packages []string) bool {
+		return len(packages) == 0
+	})
+	return packageManagerToPackages
+}
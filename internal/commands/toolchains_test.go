@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/progress"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_InstallToolchains_SelectsToolsPackageManagerAfterSystemPackages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping package manager script tests on Windows")
+	}
+	chmod, err := exec.LookPath("chmod")
+	if err != nil {
+		t.Skip("chmod is not available")
+	}
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	// The system package manager installs pipx, which is not on the fresh PATH before
+	pipx := "#!/bin/sh\necho \"pipx $*\" >> " + calls + "\n"
+	scripts := map[string]string{
+		"dnf": "#!/bin/sh\nprintf '%s' '" + pipx + "' > " + filepath.Join(dir, "pipx") + "\n" + chmod + " +x " + filepath.Join(dir, "pipx") + "\n",
+		"pip": "#!/bin/sh\necho \"pip $*\" >> " + calls + "\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0700); err != nil {
+			t.Fatalf("failed to write %s script: %v", name, err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	systemPackageManager := packagemanager.SystemPackageManager
+	t.Cleanup(func() { packagemanager.SystemPackageManager = systemPackageManager })
+	packagemanager.SystemPackageManager = &packagemanager.PackageManager{Name: "dnf", InstallCmd: "install", MultiInstall: true}
+
+	if pm := packagemanager.PYTHON_TOOLS_PACKAGE_MANAGER.Resolve(); pm != packagemanager.PYTHON_PACKAGE_MANAGER {
+		t.Fatalf("expected the pip fallback before pipx is installed, got: %s", pm.Name)
+	}
+	toolchain := &Toolchain{
+		Name:              "python",
+		InstalledPackages: []string{"pipx"},
+		PackageManagers: &map[*packagemanager.PackageManager][]string{
+			packagemanager.PYTHON_TOOLS_PACKAGE_MANAGER: {"black"},
+		},
+	}
+	if errs := InstallToolchains(&SharedCmdArgs{SkipIde: true, NoExport: true}, toolchain); errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatalf("failed to read the calls: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "pipx install black" {
+		t.Fatalf("expected black to be installed with the pipx installed by the system packages, got: %q", got)
+	}
+}
+
+func Test_InstallToolchains_ReportsVersionedToolchainName(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping package manager script tests on Windows")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "dnf"), []byte("#!/bin/sh\nexit 0\n"), 0700); err != nil {
+		t.Fatalf("failed to write dnf script: %v", err)
+	}
+	t.Setenv("PATH", dir)
+	systemPackageManager := packagemanager.SystemPackageManager
+	t.Cleanup(func() { packagemanager.SystemPackageManager = systemPackageManager })
+	packagemanager.SystemPackageManager = &packagemanager.PackageManager{Name: "dnf", InstallCmd: "install", MultiInstall: true}
+
+	toolchain := &Toolchain{
+		Name:              "java",
+		InstalledPackages: []string{"java"},
+		Versions: &ToolchainVersions{
+			Supported: []string{"17", "21"},
+			Packages:  map[string][]string{"dnf": {"java-{version}-openjdk"}},
+		},
+	}
+	versioned, err := toolchain.WithVersion("17", packagemanager.SystemPackageManager)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if errs := InstallToolchains(&SharedCmdArgs{SkipIde: true, NoExport: true}, versioned); errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	tasks := progress.Tasks()
+	if task := tasks[len(tasks)-1]; !slices.Equal(task.Toolchains, []string{"java@17"}) {
+		t.Fatalf("expected the task of the java@17 toolchain, got: %v", task.Toolchains)
+	}
+}
+
+func Test_FilterBakedToolchains_KeepsComponentsNotBakedInImage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "toolchains")
+	if err := os.WriteFile(file, []byte("golang:lint\nsetup\n"), 0600); err != nil {
+		t.Fatalf("failed to write image toolchains file: %v", err)
+	}
+	imageToolchainsFile := IMAGE_TOOLCHAINS_FILE
+	t.Cleanup(func() { IMAGE_TOOLCHAINS_FILE = imageToolchainsFile })
+	IMAGE_TOOLCHAINS_FILE = file
+
+	golang := &Toolchain{
+		Name:              "golang",
+		InstalledPackages: []string{"go"},
+		Components: map[string]*Component{
+			"lint":  {InstalledPackages: []string{"golangci-lint"}},
+			"debug": {InstalledPackages: []string{"delve"}},
+		},
+	}
+	tests := []struct {
+		name       string
+		components []string
+		expected   []string
+	}{
+		{name: "baked components", components: []string{"lint"}},
+		{name: "core packages only", components: nil},
+		{name: "component not baked", components: []string{"debug", "lint"}, expected: []string{"delve"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := golang.WithComponents(tt.components...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			remaining := filterBakedToolchains([]*Toolchain{tc})
+			if tt.expected == nil {
+				if len(remaining) != 0 {
+					t.Fatalf("expected no remaining toolchain, got: %+v", remaining[0])
+				}
+				return
+			}
+			if len(remaining) != 1 || !slices.Equal(remaining[0].InstalledPackages, tt.expected) {
+				t.Fatalf("expected the %v packages to remain, got: %+v", tt.expected, remaining)
+			}
+		})
+	}
+}
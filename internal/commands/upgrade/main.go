@@ -0,0 +1,144 @@
+package upgrade
+
+import (
+	"devbox/internal/commands"
+	"devbox/internal/commands/install"
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/utils"
+	"devbox/pkg/vscode"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Change is the version change of a package upgraded by devbox, versions are empty when they could not be queried
+type Change struct {
+	PackageManager string `json:"package_manager"`
+	Package        string `json:"package"`
+	Before         string `json:"before,omitempty"`
+	After          string `json:"after,omitempty"`
+}
+
+// Changed reports whether the upgrade changed the package version.
+func (c *Change) Changed() bool {
+	return c.Before != c.After
+}
+
+// UpgradeToolchains upgrades the packages installed by the given toolchains, or by every known toolchain when none is given.
+// Without explicit toolchains, only the packages reported as installed by their package manager are upgraded.
+// System packages are skipped when upgrading user-scoped, and packages baked in the container image are left to the image.
+func UpgradeToolchains(args *commands.SharedCmdArgs, toolchainNames []string) ([]*Change, []error) {
+	explicit := len(toolchainNames) > 0
+	profile := args.Profile
+	if !explicit {
+		// Only the installed packages are upgraded, every component is considered
+		toolchainNames = slices.Sorted(maps.Keys(install.EXISTING_TOOLCHAINS))
+		profile = commands.PROFILE_FULL
+	}
+	toolchains, err := install.ParseToolchainsWithProfile(toolchainNames, profile)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	systemPackages := make([][]string, 0, len(toolchains))
+	languagePackages := make(map[*packagemanager.PackageManager][][]string)
+	extensions := make([][]string, 0, len(toolchains))
+	for _, tc := range toolchains {
+		if !args.SkipIde {
+			extensions = append(extensions, tc.VSCodeExtensions)
+		}
+		if commands.IsBakedInImage(tc.Name) {
+			zap.L().Info("Toolchain packages are installed in the image, rebuild the image to upgrade them", zap.String("toolchain", tc.Name))
+			continue
+		}
+		if !args.UserScope {
+			systemPackages = append(systemPackages, tc.InstalledPackages)
+		}
+		if tc.PackageManagers != nil {
+			for pm, packages := range *tc.PackageManagers {
+				pm = pm.Resolve()
+				languagePackages[pm] = append(languagePackages[pm], packages)
+			}
+		}
+	}
+
+	// The system packages are upgraded first, the language package managers may be upgraded with them
+	changes, errs := UpgradePackages(packagemanager.SystemPackageManager, utils.MergeStringSlices(systemPackages...), explicit)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	upgrade := func(pm *packagemanager.PackageManager, packages []string) {
+		defer wg.Done()
+		pmChanges, pmErrs := UpgradePackages(pm, packages, explicit)
+		mutex.Lock()
+		defer mutex.Unlock()
+		changes = append(changes, pmChanges...)
+		errs = append(errs, pmErrs...)
+	}
+	for pm, packages := range languagePackages {
+		wg.Add(1)
+		go upgrade(pm, utils.MergeStringSlices(packages...))
+	}
+	if mergedExtensions := utils.MergeStringSlices(extensions...); len(mergedExtensions) > 0 {
+		wg.Add(1)
+		go upgrade(vscode.VSCODE_PACKAGE_MANAGER, mergedExtensions)
+	}
+	wg.Wait()
+
+	// Upgrades can move the binaries, their host wrappers must follow
+	if args.ShouldExport() {
+		errs = append(errs, utils.RepairDistroboxBinaries()...)
+	}
+
+	slices.SortStableFunc(changes, func(a, b *Change) int {
+		return strings.Compare(a.PackageManager, b.PackageManager)
+	})
+	return changes, utils.MergeErrors(errs)
+}
+
+// UpgradePackages upgrades the packages with the package manager and reports their versions before and after the upgrade.
+// When the packages are not explicitly requested, only the packages reported as installed are upgraded,
+// and nothing is upgraded if the package manager can not query the installed versions.
+func UpgradePackages(pm *packagemanager.PackageManager, packages []string, explicit bool) ([]*Change, []error) {
+	if pm == nil || len(packages) == 0 {
+		return nil, nil
+	}
+
+	before, err := pm.Query(packages)
+	if err != nil {
+		if !explicit {
+			zap.L().Warn("Skipping the upgrade, the installed packages can not be determined, name the toolchains to upgrade them", zap.String("package_manager", pm.Name), zap.Error(err))
+			return nil, nil
+		}
+		zap.L().Warn("Failed to query the installed versions, the versions changes will not be reported", zap.String("package_manager", pm.Name), zap.Error(err))
+	}
+
+	if !explicit {
+		packages = slices.DeleteFunc(slices.Clone(packages), func(pkg string) bool {
+			return !before[pkg].Installed
+		})
+		if len(packages) == 0 {
+			return nil, nil
+		}
+	}
+
+	errs := pm.Upgrade(packages)
+
+	after, err := pm.Query(packages)
+	if err != nil && before != nil {
+		zap.L().Warn("Failed to query the upgraded versions", zap.String("package_manager", pm.Name), zap.Error(err))
+	}
+	changes := make([]*Change, len(packages))
+	for i, pkg := range packages {
+		changes[i] = &Change{
+			PackageManager: pm.Name,
+			Package:        pkg,
+			Before:         before[pkg].Version,
+			After:          after[pkg].Version,
+		}
+	}
+	return changes, errs
+}
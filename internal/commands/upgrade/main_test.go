@@ -0,0 +1,115 @@
+package upgrade
+
+import (
+	"devbox/pkg/packagemanager"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	// avoid zap global logger side effects
+	zap.ReplaceGlobals(zap.NewNop())
+	os.Exit(m.Run())
+}
+
+// stubPackageManager writes a package manager script recording its arguments in the returned log file.
+// The queried versions are taken from before until the script ran, then from after.
+func stubPackageManager(t *testing.T, before, after map[string]string) (*packagemanager.PackageManager, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping package manager script tests on Windows")
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "calls.log")
+	script := "#!/bin/sh\necho \"$@\" >> \"" + logFile + "\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "fakepm"), []byte(script), 0o700); err != nil {
+		t.Fatalf("failed to write package manager script: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	pm := &packagemanager.PackageManager{
+		Name:         "fakepm",
+		InstallCmd:   "install",
+		MultiInstall: true,
+		UpgradeCmd:   []string{"upgrade"},
+		QueryVersions: func(pm *packagemanager.PackageManager, packages []string) (map[string]packagemanager.VersionInfo, error) {
+			installed := before
+			if _, err := os.Stat(logFile); err == nil {
+				installed = after
+			}
+			return packagemanager.MatchVersions(packages, installed, strings.ToLower), nil
+		},
+	}
+	return pm, logFile
+}
+
+func readCalls(t *testing.T, logFile string) string {
+	t.Helper()
+	data, err := os.ReadFile(logFile)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("failed to read calls log: %v", err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func Test_UpgradePackages(t *testing.T) {
+	before := map[string]string{"black": "24.1.0", "ruff": "0.4.0"}
+	after := map[string]string{"black": "24.4.2", "ruff": "0.4.0"}
+
+	t.Run("explicit upgrades every package", func(t *testing.T) {
+		pm, logFile := stubPackageManager(t, before, after)
+		changes, errs := UpgradePackages(pm, []string{"black", "ruff", "mypy"}, true)
+		if errs != nil {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		if calls := readCalls(t, logFile); calls != "upgrade black ruff mypy" {
+			t.Fatalf("unexpected calls: %q", calls)
+		}
+		if len(changes) != 3 {
+			t.Fatalf("expected 3 changes, got: %d", len(changes))
+		}
+		if c := changes[0]; c.Package != "black" || c.Before != "24.1.0" || c.After != "24.4.2" || !c.Changed() {
+			t.Fatalf("unexpected black change: %+v", c)
+		}
+		if c := changes[1]; c.Changed() {
+			t.Fatalf("expected ruff to be up to date: %+v", c)
+		}
+	})
+
+	t.Run("implicit upgrades installed packages only", func(t *testing.T) {
+		pm, logFile := stubPackageManager(t, before, after)
+		changes, errs := UpgradePackages(pm, []string{"black", "mypy"}, false)
+		if errs != nil {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		if calls := readCalls(t, logFile); calls != "upgrade black" {
+			t.Fatalf("unexpected calls: %q", calls)
+		}
+		if len(changes) != 1 || changes[0].PackageManager != "fakepm" {
+			t.Fatalf("unexpected changes: %+v", changes)
+		}
+	})
+
+	t.Run("implicit skips the package managers that can not query", func(t *testing.T) {
+		pm, logFile := stubPackageManager(t, before, after)
+		pm.QueryVersions = func(*packagemanager.PackageManager, []string) (map[string]packagemanager.VersionInfo, error) {
+			return nil, errors.New("query failed")
+		}
+		changes, errs := UpgradePackages(pm, []string{"black"}, false)
+		if changes != nil || errs != nil || readCalls(t, logFile) != "" {
+			t.Fatalf("expected nothing to be upgraded, got changes %+v and errors %v", changes, errs)
+		}
+
+		// explicit upgrades without reporting the versions
+		changes, errs = UpgradePackages(pm, []string{"black"}, true)
+		if errs != nil || len(changes) != 1 || changes[0].Before != "" || changes[0].After != "" {
+			t.Fatalf("unexpected result, changes %+v and errors %v", changes, errs)
+		}
+	})
+}
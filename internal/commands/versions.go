@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"devbox/pkg/packagemanager"
+	"devbox/pkg/utils"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+const (
+	// VERSION_PLACEHOLDER is replaced by the selected version in the versioned packages, homes, binaries and environment variables
+	VERSION_PLACEHOLDER = "{version}"
+	// ARCH_PLACEHOLDER is replaced by the Go architecture name (e.g. amd64 or arm64) in the versioned homes
+	ARCH_PLACEHOLDER = "{arch}"
+	// HOME_PLACEHOLDER is replaced by the installation directory of the selected version in the environment variables
+	HOME_PLACEHOLDER = "{home}"
+)
+
+var (
+	// VERSIONED_BINARIES_DIR holds the version suffixed links to the binaries of the toolchain versions (e.g. java17)
+	VERSIONED_BINARIES_DIR = utils.DEVBOX_BIN_DIR
+)
+
+// ToolchainVersions describes the versions of a toolchain installable side by side with a "name@version" spec (e.g. "java@17").
+// Packages and homes are given per system package manager name, a version is not available with the package managers missing from Packages.
+type ToolchainVersions struct {
+	// Supported are the selectable versions, as path.Match patterns (e.g. "1.*" for the pinned Rust releases)
+	Supported []string
+	// Replaces are the core system packages replaced by the versioned packages
+	Replaces []string
+	// Packages are the versioned system packages templates
+	Packages map[string][]string
+	// Home is the installation directory template of a version, required by LinkedBinaries and the {home} placeholder
+	Home map[string]string
+	// LinkedBinaries are the binaries of the home bin directory, linked with the version as suffix in VERSIONED_BINARIES_DIR and exported
+	LinkedBinaries []string
+	// ExportedBinaries are the binaries templates already installed with a versioned name by the packages (e.g. python{version})
+	ExportedBinaries []string
+	// EnvironmentVariables are the environment variables templates set when the version is installed (e.g. JAVA_HOME)
+	EnvironmentVariables map[string]string
+	// PackageManagers are the language packages templates installing the version (e.g. the rustup channel).
+	// These package managers are only used with a selected version, their packages are dropped from the default version.
+	PackageManagers map[*packagemanager.PackageManager][]string
+}
+
+// WithVersion returns a copy of the toolchain installing the given version with the system package manager:
+// the replaced packages are swapped for the versioned ones, and the versioned binaries and environment variables are added.
+func (it *Toolchain) WithVersion(version string, pm *packagemanager.PackageManager) (*Toolchain, error) {
+	if it.Versions == nil {
+		return nil, fmt.Errorf("toolchain %s has no selectable version", it.Name)
+	}
+	if !slices.ContainsFunc(it.Versions.Supported, func(pattern string) bool {
+		matched, _ := path.Match(pattern, version)
+		return matched
+	}) {
+		return nil, fmt.Errorf("unsupported version %q for toolchain %s, expected one of %s", version, it.Name, strings.Join(it.Versions.Supported, ", "))
+	}
+	if pm == nil {
+		return nil, errors.New("no supported system package manager found, the toolchain versions can not be resolved")
+	}
+	packages, exists := it.Versions.Packages[pm.Name]
+	if !exists {
+		return nil, fmt.Errorf("version %s of toolchain %s is not available with %s", version, it.Name, pm.Name)
+	}
+	home := strings.NewReplacer(VERSION_PLACEHOLDER, version, ARCH_PLACEHOLDER, runtime.GOARCH).Replace(it.Versions.Home[pm.Name])
+	if home == "" && len(it.Versions.LinkedBinaries) > 0 {
+		return nil, fmt.Errorf("version %s of toolchain %s has no home with %s", version, it.Name, pm.Name)
+	}
+	expand := strings.NewReplacer(VERSION_PLACEHOLDER, version, HOME_PLACEHOLDER, home)
+
+	resolved := *it
+	resolved.Name = it.Name + "@" + version
+	resolved.Version = version
+	resolved.InstalledPackages = slices.DeleteFunc(slices.Clone(it.InstalledPackages), func(pkg string) bool {
+		return slices.Contains(it.Versions.Replaces, pkg)
+	})
+	for _, pkg := range packages {
+		resolved.InstalledPackages = utils.MergeStringSlices(resolved.InstalledPackages, []string{expand.Replace(pkg)})
+	}
+
+	resolved.ExportedBinaries = slices.Clone(it.ExportedBinaries)
+	for _, binary := range it.Versions.ExportedBinaries {
+		resolved.ExportedBinaries = utils.MergeStringSlices(resolved.ExportedBinaries, []string{expand.Replace(binary)})
+	}
+	if len(it.Versions.LinkedBinaries) > 0 {
+		resolved.LinkedBinaries = make(map[string]string, len(it.Versions.LinkedBinaries))
+		for _, binary := range it.Versions.LinkedBinaries {
+			link := filepath.Join(VERSIONED_BINARIES_DIR, binary+version)
+			resolved.LinkedBinaries[link] = filepath.Join(home, "bin", binary)
+			resolved.ExportedBinaries = append(resolved.ExportedBinaries, link)
+		}
+	}
+
+	if len(it.Versions.PackageManagers) > 0 {
+		packageManagers := make(map[*packagemanager.PackageManager][]string)
+		if it.PackageManagers != nil {
+			maps.Copy(packageManagers, *it.PackageManagers)
+		}
+		for pm, templates := range it.Versions.PackageManagers {
+			expanded := make([]string, len(templates))
+			for i, template := range templates {
+				expanded[i] = expand.Replace(template)
+			}
+			packageManagers[pm] = utils.MergeStringSlices(expanded, packageManagers[pm])
+		}
+		resolved.PackageManagers = &packageManagers
+	}
+
+	resolved.EnvironmentVariables = maps.Clone(it.EnvironmentVariables)
+	if resolved.EnvironmentVariables == nil {
+		resolved.EnvironmentVariables = make(map[string]string)
+	}
+	for key, value := range it.Versions.EnvironmentVariables {
+		resolved.EnvironmentVariables[key] = expand.Replace(value)
+	}
+	if len(resolved.LinkedBinaries) > 0 {
+		resolved.EnvironmentVariables["PATH"] = VERSIONED_BINARIES_DIR + ":${PATH}"
+	}
+	return &resolved, nil
+}
+
+// WithDefaultVersion returns a copy of the toolchain installing the distribution default version,
+// without the packages of the package managers only used with a selected version (e.g. the rustup targets).
+// The toolchain itself is returned when it has no such package manager.
+func (it *Toolchain) WithDefaultVersion() *Toolchain {
+	if it.Versions == nil || len(it.Versions.PackageManagers) == 0 || it.PackageManagers == nil {
+		return it
+	}
+	packageManagers := maps.Clone(*it.PackageManagers)
+	maps.DeleteFunc(packageManagers, func(pm *packagemanager.PackageManager, _ []string) bool {
+		_, versioned := it.Versions.PackageManagers[pm]
+		return versioned
+	})
+	resolved := *it
+	resolved.PackageManagers = &packageManagers
+	return &resolved
+}
+
+// VersionPackageManagers returns the packages of the package managers installing the selected version (e.g. the rustup channel),
+// nil when there is none.
+func (it *Toolchain) VersionPackageManagers() *map[*packagemanager.PackageManager][]string {
+	if it.Version == "" || it.Versions == nil || it.PackageManagers == nil {
+		return nil
+	}
+	packageManagers := make(map[*packagemanager.PackageManager][]string)
+	for pm := range it.Versions.PackageManagers {
+		if packages, exists := (*it.PackageManagers)[pm]; exists {
+			packageManagers[pm] = packages
+		}
+	}
+	if len(packageManagers) == 0 {
+		return nil
+	}
+	return &packageManagers
+}
+
+// LinkToolchainsBinaries links the versioned binaries of the given toolchains in VERSIONED_BINARIES_DIR,
+// the existing links are replaced. The binaries must be installed.
+func LinkToolchainsBinaries(toolchains ...*Toolchain) []error {
+	var errs []error
+	for _, tc := range toolchains {
+		for _, link := range slices.Sorted(maps.Keys(tc.LinkedBinaries)) {
+			if err := linkBinary(link, tc.LinkedBinaries[link]); err != nil {
+				errs = append(errs, fmt.Errorf("failed to link %s of toolchain %s: %w", filepath.Base(link), tc.Name, err))
+			}
+		}
+	}
+	return errs
+}
+
+// linkBinary creates the link to the binary, or replaces the existing link when it targets another path
+func linkBinary(link string, binary string) error {
+	if _, err := os.Stat(binary); err != nil {
+		return fmt.Errorf("binary not found: %w", err)
+	}
+	if target, err := os.Readlink(link); err == nil && target == binary {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(binary, link)
+}
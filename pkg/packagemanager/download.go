@@ -0,0 +1,447 @@
+package packagemanager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"devbox/pkg/utils"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DOWNLOAD_FORMAT_TAR_GZ extracts the artifact as a gzipped tarball
+	DOWNLOAD_FORMAT_TAR_GZ = "tar.gz"
+	// DOWNLOAD_FORMAT_ZIP extracts the artifact as a zip archive
+	DOWNLOAD_FORMAT_ZIP = "zip"
+	// DOWNLOAD_FORMAT_JAR installs the artifact as a Java archive, run by a wrapper script
+	DOWNLOAD_FORMAT_JAR = "jar"
+	// DOWNLOAD_FORMAT_BINARY installs the artifact as the binary itself
+	DOWNLOAD_FORMAT_BINARY = "binary"
+)
+
+var (
+	// DOWNLOAD_BIN_DIR receives the downloaded binaries, or the links to the binaries of the extracted archives
+	DOWNLOAD_BIN_DIR = utils.DEVBOX_BIN_DIR
+
+	// DOWNLOAD_DIR receives the extracted archives and the installed versions, one entry per package
+	DOWNLOAD_DIR = filepath.Join(utils.DEVBOX_DATA_DIR, "downloads")
+
+	// DOWNLOAD_HTTP_CLIENT fetches the artifacts and their checksums
+	DOWNLOAD_HTTP_CLIENT = &http.Client{Timeout: 10 * time.Minute}
+)
+
+// Download describes a release artifact installed by a download package manager.
+// The URL templates accept the {version}, {os} and {arch} placeholders.
+type Download struct {
+	// URL is the artifact URL template
+	URL string
+	// Version is the installed version, unless the package is pinned to another one
+	Version string
+	// Format is one of tar.gz, zip, jar or binary, it is detected from the URL extension when empty
+	Format string
+	// Checksums are the SHA-256 checksums of the artifacts, keyed by "{version}/{os}/{arch}", or by "{version}" for the platform independent artifacts
+	Checksums map[string]string
+	// ChecksumURL is the URL template of a checksums file in the sha256sum format, or holding the single checksum of the artifact,
+	// used when Checksums has no entry
+	ChecksumURL string
+	// Insecure installs the artifact unverified when no checksum is declared, the installation fails otherwise.
+	// It is only meant for the artifacts whose publisher provides no checksum.
+	Insecure bool
+	// Binaries are the paths of the binaries in the archive templates, linked with their base name in DOWNLOAD_BIN_DIR.
+	// The archives install the binary named after the package at their root when empty.
+	Binaries []string
+	// OS and Arch rename the Go operating system and architecture names in the templates (e.g. "amd64" to "x86_64")
+	OS   map[string]string
+	Arch map[string]string
+}
+
+// NewDownloadPackageManager returns a package manager installing the given downloads, keyed by package name.
+// The packages can be pinned to another version than the declared one with "name@version".
+func NewDownloadPackageManager(downloads map[string]*Download) *PackageManager {
+	return &PackageManager{
+		Name:         "download",
+		MultiInstall: true,
+		PinFormat:    "{name}@{version}",
+		InstallPackages: func(pm *PackageManager, packages []string) []error {
+			var errs []error
+			for _, pkg := range packages {
+				name, version, _ := strings.Cut(pkg, "@")
+				download, exists := downloads[name]
+				if !exists {
+					errs = append(errs, fmt.Errorf("unknown download package: %s", name))
+					continue
+				}
+				if version == "" {
+					version = download.Version
+				}
+				zap.L().Info("Installing package", zap.String("package", name), zap.String("version", version), zap.String("package_manager", pm.Name))
+				if err := download.Install(name, version); err != nil {
+					zap.L().Error("Error installing package", zap.String("package", name), zap.String("package_manager", pm.Name), zap.Error(err))
+					errs = append(errs, fmt.Errorf("failed to install package %s using %s: %w", name, pm.Name, err))
+					continue
+				}
+				zap.L().Info("Successfully installed package", zap.String("package", name), zap.String("package_manager", pm.Name))
+			}
+			return errs
+		},
+		InstalledBinaries: func(pm *PackageManager, packages []string) []string {
+			var binaries []string
+			for _, pkg := range packages {
+				name, _, _ := strings.Cut(pkg, "@")
+				if download, exists := downloads[name]; exists {
+					for _, binary := range download.binaryNames(name) {
+						binaries = append(binaries, filepath.Join(DOWNLOAD_BIN_DIR, binary))
+					}
+				}
+			}
+			return binaries
+		},
+		QueryVersions: queryDownloadVersions,
+	}
+}
+
+// Install downloads the artifact of the version, verifies its checksum and installs its binaries.
+// The installed version is recorded in DOWNLOAD_DIR.
+func (d *Download) Install(name string, version string) error {
+	expand := d.replacer(version)
+	url := expand.Replace(d.URL)
+
+	if err := os.MkdirAll(DOWNLOAD_DIR, 0755); err != nil {
+		return err
+	}
+	artifact, err := os.CreateTemp(DOWNLOAD_DIR, "."+name+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(artifact.Name())
+	defer artifact.Close()
+
+	checksum, err := fetch(url, artifact)
+	if err != nil {
+		return err
+	}
+	if err := d.verify(name, version, url, checksum); err != nil {
+		return err
+	}
+	if _, err := artifact.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(DOWNLOAD_BIN_DIR, 0755); err != nil {
+		return err
+	}
+	switch format := d.format(url); format {
+	case DOWNLOAD_FORMAT_BINARY:
+		err = installFile(artifact, filepath.Join(DOWNLOAD_BIN_DIR, name), 0755)
+	case DOWNLOAD_FORMAT_JAR:
+		err = d.installJar(name, artifact)
+	case DOWNLOAD_FORMAT_TAR_GZ, DOWNLOAD_FORMAT_ZIP:
+		err = d.installArchive(name, format, artifact, expand)
+	default:
+		err = fmt.Errorf("unsupported download format %q, expected one of %s", format, strings.Join([]string{DOWNLOAD_FORMAT_TAR_GZ, DOWNLOAD_FORMAT_ZIP, DOWNLOAD_FORMAT_JAR, DOWNLOAD_FORMAT_BINARY}, ", "))
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(DOWNLOAD_DIR, name+".version"), []byte(version+"\n"), 0644)
+}
+
+// replacer replaces the placeholders of the templates for the version and the current platform
+func (d *Download) replacer(version string) *strings.Replacer {
+	return strings.NewReplacer("{version}", version, "{os}", platformName(d.OS, runtime.GOOS), "{arch}", platformName(d.Arch, runtime.GOARCH))
+}
+
+// platformName returns the renamed operating system or architecture name, or the Go name when it is not renamed
+func platformName(names map[string]string, name string) string {
+	if renamed, exists := names[name]; exists {
+		return renamed
+	}
+	return name
+}
+
+// format returns the declared format, or the format matching the URL extension
+func (d *Download) format(url string) string {
+	if d.Format != "" {
+		return d.Format
+	}
+	switch {
+	case strings.HasSuffix(url, ".tar.gz"), strings.HasSuffix(url, ".tgz"):
+		return DOWNLOAD_FORMAT_TAR_GZ
+	case strings.HasSuffix(url, ".zip"):
+		return DOWNLOAD_FORMAT_ZIP
+	case strings.HasSuffix(url, ".jar"):
+		return DOWNLOAD_FORMAT_JAR
+	default:
+		return DOWNLOAD_FORMAT_BINARY
+	}
+}
+
+// binaryNames returns the names of the binaries installed in DOWNLOAD_BIN_DIR
+func (d *Download) binaryNames(name string) []string {
+	if len(d.Binaries) == 0 {
+		return []string{name}
+	}
+	names := make([]string, len(d.Binaries))
+	for i, binary := range d.Binaries {
+		names[i] = path.Base(binary)
+	}
+	return names
+}
+
+// verify compares the artifact checksum with the declared one, or with the one of the checksums file.
+// Artifacts without a checksum are refused, unless the download is Insecure, in which case they are installed with a warning.
+func (d *Download) verify(name string, version string, url string, checksum string) error {
+	expected := d.Checksums[d.replacer(version).Replace("{version}/{os}/{arch}")]
+	if expected == "" {
+		expected = d.Checksums[version]
+	}
+	if expected == "" && d.ChecksumURL != "" {
+		var err error
+		if expected, err = fetchChecksum(d.replacer(version).Replace(d.ChecksumURL), path.Base(url)); err != nil {
+			return err
+		}
+	}
+	if expected == "" {
+		if !d.Insecure {
+			return fmt.Errorf("no sha256 checksum declared for %s %s, refusing to install %s (sha256 %s)", name, version, url, checksum)
+		}
+		zap.L().Warn("No checksum declared, the insecure download is not verified", zap.String("package", name), zap.String("url", url), zap.String("sha256", checksum))
+		return nil
+	}
+	if !strings.EqualFold(expected, checksum) {
+		return fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", url, expected, checksum)
+	}
+	return nil
+}
+
+// installJar installs the Java archive in DOWNLOAD_DIR, and a wrapper script running it in DOWNLOAD_BIN_DIR
+func (d *Download) installJar(name string, artifact io.Reader) error {
+	jar := filepath.Join(DOWNLOAD_DIR, name+".jar")
+	if err := installFile(artifact, jar, 0644); err != nil {
+		return err
+	}
+	wrapper := fmt.Sprintf("#!/bin/sh\nexec java -jar %q \"$@\"\n", jar)
+	return installFile(strings.NewReader(wrapper), filepath.Join(DOWNLOAD_BIN_DIR, name), 0755)
+}
+
+// installArchive extracts the archive in DOWNLOAD_DIR, replacing the previous version, and links its binaries in DOWNLOAD_BIN_DIR
+func (d *Download) installArchive(name string, format string, artifact *os.File, expand *strings.Replacer) error {
+	extractDir, err := os.MkdirTemp(DOWNLOAD_DIR, "."+name+"-extract-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(extractDir)
+
+	if format == DOWNLOAD_FORMAT_ZIP {
+		err = extractZip(artifact, extractDir)
+	} else {
+		err = extractTarGz(artifact, extractDir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s archive: %w", format, err)
+	}
+
+	binaries := slices.Clone(d.Binaries)
+	if len(binaries) == 0 {
+		binaries = []string{name}
+	}
+	for i, binary := range binaries {
+		binaries[i] = filepath.FromSlash(expand.Replace(binary))
+		if _, err := os.Stat(filepath.Join(extractDir, binaries[i])); err != nil {
+			return fmt.Errorf("binary %s not found in the archive", binaries[i])
+		}
+	}
+
+	installDir := filepath.Join(DOWNLOAD_DIR, name)
+	if err := os.RemoveAll(installDir); err != nil {
+		return err
+	}
+	if err := os.Rename(extractDir, installDir); err != nil {
+		return err
+	}
+	for _, binary := range binaries {
+		link := filepath.Join(DOWNLOAD_BIN_DIR, filepath.Base(binary))
+		if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Symlink(filepath.Join(installDir, binary), link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetch writes the content of the URL to the writer and returns its SHA-256 checksum
+func fetch(url string, writer io.Writer) (string, error) {
+	zap.L().Debug("Downloading", zap.String("url", url))
+	response, err := DOWNLOAD_HTTP_CLIENT.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", url, response.Status)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(writer, hash), response.Body); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fetchChecksum returns the checksum of the file from a checksums file in the sha256sum format ("<checksum>  <file>" lines).
+// A checksums file holding a single checksum without file name is accepted.
+func fetchChecksum(url string, file string) (string, error) {
+	var content strings.Builder
+	if _, err := fetch(url, &content); err != nil {
+		return "", err
+	}
+	if fields := strings.Fields(content.String()); len(fields) == 1 {
+		return fields[0], nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(content.String()))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == file {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("no checksum found for %s in %s", file, url)
+}
+
+// installFile writes the content of the reader to the file, through a temporary file renamed once complete
+func installFile(reader io.Reader, file string, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// archivePath returns the path of the archive entry in the directory, rejecting the entries escaping it
+func archivePath(dir string, entry string) (string, error) {
+	target := filepath.Join(dir, filepath.FromSlash(entry))
+	if target != dir && !strings.HasPrefix(target, dir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %s escapes the extraction directory", entry)
+	}
+	return target, nil
+}
+
+// extractTarGz extracts the directories, regular files and symbolic links of the gzipped tarball in the directory
+func extractTarGz(reader io.Reader, dir string) error {
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := archivePath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = installFile(tarReader, target, header.FileInfo().Mode().Perm())
+			}
+		case tar.TypeSymlink:
+			if _, err = archivePath(dir, path.Join(path.Dir(header.Name), header.Linkname)); err == nil && !filepath.IsAbs(header.Linkname) {
+				if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+					err = os.Symlink(header.Linkname, target)
+				}
+			} else if err == nil {
+				err = fmt.Errorf("archive link %s escapes the extraction directory", header.Name)
+			}
+		default:
+			zap.L().Debug("Skipping archive entry", zap.String("entry", header.Name), zap.Any("type", header.Typeflag))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip extracts the directories and files of the zip archive in the directory
+func extractZip(artifact *os.File, dir string) error {
+	info, err := artifact.Stat()
+	if err != nil {
+		return err
+	}
+	zipReader, err := zip.NewReader(artifact, info.Size())
+	if err != nil {
+		return err
+	}
+	for _, file := range zipReader.File {
+		target, err := archivePath(dir, file.Name)
+		if err != nil {
+			return err
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		content, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = installFile(content, target, file.Mode().Perm())
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryDownloadVersions reads the installed versions of the packages recorded in DOWNLOAD_DIR
+func queryDownloadVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	versions := make(map[string]VersionInfo, len(packages))
+	for _, pkg := range packages {
+		name, _, _ := strings.Cut(pkg, "@")
+		version, err := os.ReadFile(filepath.Join(DOWNLOAD_DIR, name+".version"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		versions[pkg] = VersionInfo{Name: name, Version: strings.TrimSpace(string(version)), Installed: err == nil}
+	}
+	return versions, nil
+}
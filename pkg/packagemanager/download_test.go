@@ -0,0 +1,184 @@
+package packagemanager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// serveDownloads serves the files at their path and points the download directories to temporary directories
+func serveDownloads(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping download tests on Windows")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, exists := files[r.URL.Path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+
+	binDir, downloadDir := DOWNLOAD_BIN_DIR, DOWNLOAD_DIR
+	t.Cleanup(func() { DOWNLOAD_BIN_DIR, DOWNLOAD_DIR = binDir, downloadDir })
+	DOWNLOAD_BIN_DIR = filepath.Join(t.TempDir(), "bin")
+	DOWNLOAD_DIR = filepath.Join(t.TempDir(), "downloads")
+	return server.URL
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		tarWriter.Write([]byte(content))
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+	return buffer.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	for name, content := range files {
+		header := &zip.FileHeader{Name: name}
+		header.SetMode(0755)
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("failed to write zip header: %v", err)
+		}
+		writer.Write([]byte(content))
+	}
+	zipWriter.Close()
+	return buffer.Bytes()
+}
+
+func Test_DownloadPackageManager_Install(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho yq\n")
+	tarball := tarGz(t, map[string]string{"tool-1.2.0/bin/tool": "#!/bin/sh\necho tool\n", "tool-1.2.0/lib/tool.jar": "jar"})
+	archive := zipArchive(t, map[string]string{"zipped": "#!/bin/sh\necho zipped\n"})
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+	url := serveDownloads(t, map[string][]byte{
+		"/yq/4.44.3/yq_" + platform:    binary,
+		"/tool/1.2.0/tool.tgz":         tarball,
+		"/zipped/2.0.0/zipped.zip":     archive,
+		"/zipped/2.0.0/SHA256SUMS":     []byte(sha256Hex([]byte("other")) + "  other.zip\n" + sha256Hex(archive) + " *zipped.zip\n"),
+		"/corrupted/1.0.0/corrupted":   []byte("tampered"),
+		"/unverified/1.0.0/unverified": binary,
+		"/insecure/1.0.0/insecure":     binary,
+		"/single/1.0.0/single":         binary,
+		"/single/1.0.0/single.sha256":  []byte(sha256Hex(binary) + "\n"),
+	})
+
+	pm := NewDownloadPackageManager(map[string]*Download{
+		"yq": {
+			URL:       url + "/yq/{version}/yq_{os}_{arch}",
+			Version:   "4.44.3",
+			Checksums: map[string]string{"4.44.3/" + runtime.GOOS + "/" + runtime.GOARCH: sha256Hex(binary)},
+		},
+		"tool": {
+			URL:       url + "/tool/{version}/tool.tgz",
+			Version:   "1.2.0",
+			Checksums: map[string]string{"1.2.0": sha256Hex(tarball)},
+			Binaries:  []string{"tool-{version}/bin/tool"},
+		},
+		"zipped": {
+			URL:         url + "/zipped/{version}/zipped.zip",
+			Version:     "1.0.0",
+			ChecksumURL: url + "/zipped/{version}/SHA256SUMS",
+		},
+		// Maven Central publishes the single checksum of each artifact next to it
+		"single": {
+			URL:         url + "/single/{version}/single",
+			Version:     "1.0.0",
+			ChecksumURL: url + "/single/{version}/single.sha256",
+		},
+		"corrupted": {
+			URL:       url + "/corrupted/{version}/corrupted",
+			Version:   "1.0.0",
+			Checksums: map[string]string{"1.0.0": sha256Hex([]byte("original"))},
+		},
+		"unverified": {
+			URL:     url + "/unverified/{version}/unverified",
+			Version: "1.0.0",
+		},
+		"insecure": {
+			URL:      url + "/insecure/{version}/insecure",
+			Version:  "1.0.0",
+			Insecure: true,
+		},
+	})
+
+	if errs := pm.InstallPinned([]string{"yq", "tool", "zipped", "single"}, map[string]string{"zipped": "2.0.0"}); errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for _, name := range []string{"yq", "tool", "zipped", "single"} {
+		info, err := os.Stat(filepath.Join(DOWNLOAD_BIN_DIR, name))
+		if err != nil || info.Mode().Perm()&0100 == 0 {
+			t.Fatalf("expected %s to be installed as an executable, got: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(DOWNLOAD_DIR, "tool", "tool-1.2.0", "lib", "tool.jar")); err != nil {
+		t.Fatalf("expected the archive to be extracted: %v", err)
+	}
+
+	versions, err := pm.Query([]string{"yq", "zipped", "missing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if versions["yq"].Version != "4.44.3" || versions["zipped"].Version != "2.0.0" || versions["missing"].Installed {
+		t.Fatalf("unexpected versions: %+v", versions)
+	}
+
+	wantBinaries := []string{filepath.Join(DOWNLOAD_BIN_DIR, "yq"), filepath.Join(DOWNLOAD_BIN_DIR, "tool")}
+	if binaries := pm.InstalledBinaries(pm, []string{"yq", "tool@1.2.0"}); !slices.Equal(binaries, wantBinaries) {
+		t.Fatalf("expected binaries %q, got: %q", wantBinaries, binaries)
+	}
+
+	errs := pm.Install([]string{"corrupted", "unverified", "unknown"})
+	if len(errs) != 3 || !strings.Contains(errs[0].Error(), "checksum mismatch") || !strings.Contains(errs[1].Error(), "no sha256 checksum declared for unverified 1.0.0") ||
+		!strings.Contains(errs[2].Error(), "unknown download package: unknown") {
+		t.Fatalf("expected checksum mismatch, missing checksum and unknown package errors, got: %v", errs)
+	}
+	for _, name := range []string{"corrupted", "unverified"} {
+		if _, err := os.Stat(filepath.Join(DOWNLOAD_BIN_DIR, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected the %s download not to be installed, got: %v", name, err)
+		}
+	}
+	if errs := pm.Install([]string{"insecure"}); errs != nil {
+		t.Fatalf("expected the insecure download to be installed unverified, got: %v", errs)
+	}
+}
+
+func Test_ExtractTarGz_RejectsEscapingEntries(t *testing.T) {
+	dir := t.TempDir()
+	archive := tarGz(t, map[string]string{"../escape": "content"})
+	if err := extractTarGz(bytes.NewReader(archive), dir); err == nil || !strings.Contains(err.Error(), "escapes the extraction directory") {
+		t.Fatalf("expected escaping entry error, got: %v", err)
+	}
+}
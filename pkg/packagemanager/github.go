@@ -0,0 +1,191 @@
+package packagemanager
+
+import (
+	"devbox/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// GITHUB_LATEST_TAG resolves the latest release of the repository
+	GITHUB_LATEST_TAG = "latest"
+)
+
+var (
+	// GITHUB_API_URL is the base URL of the GitHub releases API, it can point to a mirror serving the same JSON documents
+	GITHUB_API_URL = utils.Getenv("DEVBOX_GITHUB_API_URL", "https://api.github.com")
+)
+
+// GitHubRelease describes a tool installed from the assets of its GitHub releases.
+// The asset patterns are path.Match patterns accepting the {version}, {os} and {arch} placeholders,
+// {version} being the release tag without its "v" prefix.
+type GitHubRelease struct {
+	// Repository is the "owner/name" repository publishing the releases
+	Repository string
+	// Tag is the installed release tag, unless the package is pinned to another one, the latest release is installed when empty
+	Tag string
+	// Asset is the pattern of the installed asset
+	Asset string
+	// Checksums is the pattern of the checksums asset in the sha256sum format, the installation fails when empty unless Insecure
+	Checksums string
+	// Insecure installs the asset unverified when the release has no checksums asset
+	Insecure bool
+	// Format is one of tar.gz, zip, jar or binary, it is detected from the asset extension when empty
+	Format string
+	// Binaries are the paths of the binaries in the archive templates, the binary named after the package is installed when empty
+	Binaries []string
+	// OS and Arch rename the Go operating system and architecture names in the patterns (e.g. "amd64" to "x86_64")
+	OS   map[string]string
+	Arch map[string]string
+}
+
+// gitHubReleaseDocument is the release document of the GitHub API, restricted to the used fields
+type gitHubReleaseDocument struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// NewGitHubReleasePackageManager returns a package manager installing the given GitHub releases assets, keyed by package name.
+// Its name must be unique among the package managers, as the packages are grouped and locked by package manager name.
+// The packages can be pinned to another release tag than the declared one with "name@tag".
+func NewGitHubReleasePackageManager(name string, releases map[string]*GitHubRelease) *PackageManager {
+	return &PackageManager{
+		Name:         name,
+		MultiInstall: true,
+		PinFormat:    "{name}@{version}",
+		InstallPackages: func(pm *PackageManager, packages []string) []error {
+			var errs []error
+			for _, pkg := range packages {
+				name, tag, _ := strings.Cut(pkg, "@")
+				release, exists := releases[name]
+				if !exists {
+					errs = append(errs, fmt.Errorf("unknown GitHub release package: %s", name))
+					continue
+				}
+				zap.L().Info("Installing package", zap.String("package", name), zap.String("repository", release.Repository), zap.String("package_manager", pm.Name))
+				if err := release.Install(name, tag); err != nil {
+					zap.L().Error("Error installing package", zap.String("package", name), zap.String("package_manager", pm.Name), zap.Error(err))
+					errs = append(errs, fmt.Errorf("failed to install package %s using %s: %w", name, pm.Name, err))
+					continue
+				}
+				zap.L().Info("Successfully installed package", zap.String("package", name), zap.String("package_manager", pm.Name))
+			}
+			return errs
+		},
+		InstalledBinaries: func(pm *PackageManager, packages []string) []string {
+			var binaries []string
+			for _, pkg := range packages {
+				name, _, _ := strings.Cut(pkg, "@")
+				if release, exists := releases[name]; exists {
+					for _, binary := range (&Download{Binaries: release.Binaries}).binaryNames(name) {
+						binaries = append(binaries, filepath.Join(DOWNLOAD_BIN_DIR, binary))
+					}
+				}
+			}
+			return binaries
+		},
+		QueryVersions: queryDownloadVersions,
+	}
+}
+
+// Install resolves the release of the tag, the declared tag or the latest release when empty,
+// then downloads its asset matching the platform, verifies it against the checksums asset and installs its binaries.
+// The release tag is recorded as the installed version.
+func (r *GitHubRelease) Install(name string, tag string) error {
+	if tag == "" {
+		tag = r.Tag
+	}
+	release, err := r.fetchRelease(tag)
+	if err != nil {
+		return err
+	}
+	download, err := r.download(release)
+	if err != nil {
+		return err
+	}
+	return download.Install(name, release.TagName)
+}
+
+// fetchRelease fetches the release document of the tag, the latest release when empty or "latest".
+// The GITHUB_TOKEN environment variable authenticates the requests when set.
+func (r *GitHubRelease) fetchRelease(tag string) (*gitHubReleaseDocument, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/tags/%s", strings.TrimSuffix(GITHUB_API_URL, "/"), r.Repository, tag)
+	if tag == "" || tag == GITHUB_LATEST_TAG {
+		url = fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimSuffix(GITHUB_API_URL, "/"), r.Repository)
+	}
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	zap.L().Debug("Fetching release", zap.String("url", url))
+	response, err := DOWNLOAD_HTTP_CLIENT.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the release of %s: %w", r.Repository, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the release of %s from %s: %s", r.Repository, url, response.Status)
+	}
+	release := &gitHubReleaseDocument{}
+	if err := json.NewDecoder(response.Body).Decode(release); err != nil {
+		return nil, fmt.Errorf("failed to parse the release of %s: %w", r.Repository, err)
+	}
+	return release, nil
+}
+
+// download returns the download of the release asset matching the platform, verified by the checksums asset
+func (r *GitHubRelease) download(release *gitHubReleaseDocument) (*Download, error) {
+	expand := (&Download{OS: r.OS, Arch: r.Arch}).replacer(strings.TrimPrefix(release.TagName, "v"))
+	download := &Download{Format: r.Format, Binaries: make([]string, len(r.Binaries)), Insecure: r.Insecure}
+	for i, binary := range r.Binaries {
+		download.Binaries[i] = expand.Replace(binary)
+	}
+
+	var err error
+	if download.URL, err = release.assetURL(expand.Replace(r.Asset)); err != nil {
+		return nil, fmt.Errorf("release %s of %s: %w", release.TagName, r.Repository, err)
+	}
+	if r.Checksums != "" {
+		if download.ChecksumURL, err = release.assetURL(expand.Replace(r.Checksums)); err != nil {
+			return nil, fmt.Errorf("release %s of %s: %w", release.TagName, r.Repository, err)
+		}
+	}
+	return download, nil
+}
+
+// assetURL returns the download URL of the single asset matching the pattern
+func (d *gitHubReleaseDocument) assetURL(pattern string) (string, error) {
+	var matches []string
+	url := ""
+	for _, asset := range d.Assets {
+		if matched, err := path.Match(pattern, asset.Name); err != nil {
+			return "", fmt.Errorf("invalid asset pattern %q: %w", pattern, err)
+		} else if matched {
+			matches = append(matches, asset.Name)
+			url = asset.BrowserDownloadURL
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no asset matching %q", pattern)
+	case 1:
+		return url, nil
+	default:
+		return "", fmt.Errorf("several assets matching %q: %s", pattern, strings.Join(matches, ", "))
+	}
+}
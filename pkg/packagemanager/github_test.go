@@ -0,0 +1,83 @@
+package packagemanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_GitHubReleasePackageManager_Install(t *testing.T) {
+	files := make(map[string][]byte)
+	url := serveDownloads(t, files)
+	apiURL := GITHUB_API_URL
+	t.Cleanup(func() { GITHUB_API_URL = apiURL })
+	GITHUB_API_URL = url
+
+	asset := "tool_" + runtime.GOOS + "_" + runtime.GOARCH
+	release := func(tag string, binary []byte, checksum string) {
+		files["/assets/"+tag+"/"+asset] = binary
+		files["/assets/"+tag+"/checksums.txt"] = []byte(checksum + "  " + asset + "\n")
+		files["/repos/owner/tool/releases/tags/"+tag] = []byte(fmt.Sprintf(`{"tag_name": %q, "assets": [
+			{"name": "tool_plan9_386", "browser_download_url": "%s/assets/%s/tool_plan9_386"},
+			{"name": %q, "browser_download_url": "%s/assets/%s/%s"},
+			{"name": "checksums.txt", "browser_download_url": "%s/assets/%s/checksums.txt"}
+		]}`, tag, url, tag, asset, url, tag, asset, url, tag))
+	}
+	latest := []byte("#!/bin/sh\necho v2.0.0\n")
+	release("v1.0.0", []byte("#!/bin/sh\necho v1.0.0\n"), sha256Hex([]byte("#!/bin/sh\necho v1.0.0\n")))
+	release("v2.0.0", latest, sha256Hex(latest))
+	release("v3.0.0", []byte("tampered"), sha256Hex(latest))
+	files["/repos/owner/tool/releases/latest"] = files["/repos/owner/tool/releases/tags/v2.0.0"]
+
+	pm := NewGitHubReleasePackageManager("github", map[string]*GitHubRelease{
+		"tool": {
+			Repository: "owner/tool",
+			Asset:      "tool_{os}_{arch}",
+			Checksums:  "checksums.txt",
+		},
+		"ambiguous": {
+			Repository: "owner/tool",
+			Asset:      "tool_*",
+		},
+	})
+	installed := func() string {
+		t.Helper()
+		content, err := os.ReadFile(filepath.Join(DOWNLOAD_BIN_DIR, "tool"))
+		if err != nil {
+			t.Fatalf("expected the tool to be installed: %v", err)
+		}
+		versions, _ := pm.Query([]string{"tool"})
+		return strings.TrimPrefix(strings.TrimSpace(string(content)), "#!/bin/sh\n") + " " + versions["tool"].Version
+	}
+
+	if errs := pm.Install([]string{"tool"}); errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got := installed(); got != "echo v2.0.0 v2.0.0" {
+		t.Fatalf("expected the latest release to be installed, got: %q", got)
+	}
+
+	if errs := pm.InstallPinned([]string{"tool"}, map[string]string{"tool": "v1.0.0"}); errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got := installed(); got != "echo v1.0.0 v1.0.0" {
+		t.Fatalf("expected the pinned release to be installed, got: %q", got)
+	}
+
+	errs := pm.Install([]string{"tool@v3.0.0", "ambiguous@v1.0.0", "tool@v9.9.9"})
+	want := []string{"checksum mismatch", "several assets matching", "404 Not Found"}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got: %v", len(want), errs)
+	}
+	for i, err := range errs {
+		if !strings.Contains(err.Error(), want[i]) {
+			t.Fatalf("expected error containing %q, got: %v", want[i], err)
+		}
+	}
+	if got := installed(); got != "echo v1.0.0 v1.0.0" {
+		t.Fatalf("expected the failed installations to keep the installed release, got: %q", got)
+	}
+}
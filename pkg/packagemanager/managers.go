@@ -1,41 +1,201 @@
 package packagemanager
 
-import "devbox/pkg/utils"
+import (
+	"devbox/pkg/utils"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
 
 var (
+	// PIP_TIMEOUT is the timeout of the python package managers
+	PIP_TIMEOUT = 20 * time.Minute
+
+	// LANGUAGE_PACKAGE_MANAGERS are the package managers of the toolchains languages
+	LANGUAGE_PACKAGE_MANAGERS = []*PackageManager{
+		GOLANG_PACKAGE_MANAGER,
+		KREW_PACKAGE_MANAGER,
+		PYTHON_PACKAGE_MANAGER,
+		PIPX_PACKAGE_MANAGER,
+		UV_PACKAGE_MANAGER,
+		NODE_PACKAGE_MANAGER,
+		PNPM_PACKAGE_MANAGER,
+		BUN_PACKAGE_MANAGER,
+		CARGO_PACKAGE_MANAGER,
+		RUSTUP_PACKAGE_MANAGER,
+	}
+
 	GOLANG_PACKAGE_MANAGER = &PackageManager{
-		Name:         "go",
-		InstallCmd:   "install",
-		MultiInstall: false,
-		SudoRequired: false,
+		Name:                "go",
+		Retry:               GO_RETRY_POLICY,
+		InstallCmd:          "install",
+		MultiInstall:        false,
+		SudoRequired:        false,
+		UpgradeCmd:          []string{"install"},
+		LatestVersionSuffix: "@latest",
+		QueryVersions:       queryGoVersions,
+		PinFormat:           "{name}@{version}",
+		// The modules are compiled from source, a large tool like golangci-lint lasting minutes on a cold cache
+		Timeout: 45 * time.Minute,
 	}
 
 	KREW_PACKAGE_MANAGER = &PackageManager{
-		Name:         "krew",
-		InstallCmd:   "install",
-		SudoRequired: false,
-		MultiInstall: true,
+		Name:          "krew",
+		InstallCmd:    "install",
+		SudoRequired:  false,
+		MultiInstall:  true,
+		UpgradeCmd:    []string{"upgrade"},
+		QueryVersions: queryKrewVersions,
 	}
 
 	PYTHON_PACKAGE_MANAGER = &PackageManager{
-		Name:         "pip",
-		InstallCmd:   "install",
-		MultiInstall: true,
-		SudoRequired: false,
+		Name:          "pip",
+		Retry:         PIP_RETRY_POLICY,
+		InstallCmd:    "install",
+		MultiInstall:  true,
+		SudoRequired:  false,
+		UpgradeCmd:    []string{"install", "-U"},
+		QueryVersions: queryPipVersions,
+		PinFormat:     "{name}=={version}",
+		// The packages are mostly downloaded as wheels, a longer command is likely stuck on a network or build issue
+		Timeout: PIP_TIMEOUT,
+		// pip is the fallback of the python CLI tools, it must be able to install them next to the distribution packages (PEP 668)
+		Environment: map[string]string{
+			"PIP_BREAK_SYSTEM_PACKAGES": "1",
+		},
+	}
+
+	// PIPX_PACKAGE_MANAGER installs each python CLI tool in its own virtual environment, with its entry points on PATH
+	PIPX_PACKAGE_MANAGER = &PackageManager{
+		Name:          "pipx",
+		Retry:         PIP_RETRY_POLICY,
+		InstallCmd:    "install",
+		MultiInstall:  true,
+		SudoRequired:  false,
+		UpgradeCmd:    []string{"upgrade"},
+		QueryVersions: queryPipxVersions,
+		PinFormat:     "{name}=={version}",
+		Timeout:       PIP_TIMEOUT,
 	}
 
+	// UV_PACKAGE_MANAGER installs each python CLI tool in its own virtual environment with uv tool, with its entry points on PATH
+	UV_PACKAGE_MANAGER = &PackageManager{
+		Name:          "uv",
+		Retry:         PIP_RETRY_POLICY,
+		InstallCmd:    "tool install",
+		MultiInstall:  false,
+		SudoRequired:  false,
+		UpgradeCmd:    []string{"tool", "upgrade"},
+		QueryVersions: queryUvVersions,
+		PinFormat:     "{name}=={version}",
+		Timeout:       PIP_TIMEOUT,
+	}
+
+	// PYTHON_TOOLS_PACKAGE_MANAGER installs the python CLI tools with uv, then pipx, then pip, depending on their availability
+	// once the system packages are installed (pipx is installed by the python toolchain)
+	PYTHON_TOOLS_PACKAGE_MANAGER = &PackageManager{
+		Name:       "python-tools",
+		Candidates: []*PackageManager{UV_PACKAGE_MANAGER, PIPX_PACKAGE_MANAGER, PYTHON_PACKAGE_MANAGER},
+	}
+
+	// NODE_PACKAGE_MANAGER installs the node packages globally in npm_config_prefix, a user directory by default so that no privilege is required
 	NODE_PACKAGE_MANAGER = &PackageManager{
-		Name:             "npm",
-		InstallCmd:       "install",
-		MultiInstall:     true,
-		NoInteractiveArg: utils.StrPtr("--user"),
+		Name:          "npm",
+		Retry:         NPM_RETRY_POLICY,
+		InstallCmd:    "install -g",
+		MultiInstall:  true,
+		SudoRequired:  false,
+		UpgradeCmd:    []string{"update", "-g"},
+		QueryVersions: queryNpmVersions,
+		PinFormat:     "{name}@{version}",
+		Environment: map[string]string{
+			"npm_config_prefix": filepath.Join(utils.XDG_DATA_HOME, "npm"),
+		},
+	}
+
+	// PNPM_PACKAGE_MANAGER installs the node packages globally in PNPM_HOME
+	PNPM_PACKAGE_MANAGER = &PackageManager{
+		Name:          "pnpm",
+		Retry:         NPM_RETRY_POLICY,
+		InstallCmd:    "add -g",
+		MultiInstall:  true,
+		SudoRequired:  false,
+		UpgradeCmd:    []string{"update", "-g"},
+		QueryVersions: queryPnpmVersions,
+		PinFormat:     "{name}@{version}",
+		Environment: map[string]string{
+			"PNPM_HOME": filepath.Join(utils.XDG_DATA_HOME, "pnpm"),
+		},
+	}
+
+	// BUN_PACKAGE_MANAGER installs the node packages globally in BUN_INSTALL, upgrades add the latest version again
+	BUN_PACKAGE_MANAGER = &PackageManager{
+		Name:                "bun",
+		Retry:               NPM_RETRY_POLICY,
+		InstallCmd:          "add -g",
+		MultiInstall:        true,
+		SudoRequired:        false,
+		UpgradeCmd:          []string{"add", "-g"},
+		LatestVersionSuffix: "@latest",
+		QueryVersions:       queryBunVersions,
+		PinFormat:           "{name}@{version}",
+		Environment: map[string]string{
+			"BUN_INSTALL": filepath.Join(utils.XDG_DATA_HOME, "bun"),
+		},
+	}
+
+	// NODE_TOOLS_PACKAGE_MANAGER installs the node CLI tools with pnpm, then bun, then npm, depending on their availability
+	// once the system packages are installed
+	NODE_TOOLS_PACKAGE_MANAGER = &PackageManager{
+		Name:       "node-tools",
+		Candidates: []*PackageManager{PNPM_PACKAGE_MANAGER, BUN_PACKAGE_MANAGER, NODE_PACKAGE_MANAGER},
 	}
 
 	CARGO_PACKAGE_MANAGER = &PackageManager{
 		Name:             "cargo",
+		Retry:            CARGO_RETRY_POLICY,
 		InstallCmd:       "install",
 		NoInteractiveArg: nil,
 		SudoRequired:     false,
 		MultiInstall:     true,
+		UpgradeCmd:       []string{"install", "--force"},
+		QueryVersions:    queryCargoVersions,
+		PinFormat:        "{name} --version {version}",
+		// The crates are compiled from source, which can last much longer than a download
+		Timeout: time.Hour,
 	}
 )
+
+// GetLanguagePackageManager returns the supported language package manager with the given name.
+func GetLanguagePackageManager(name string) (*PackageManager, error) {
+	for _, pm := range LANGUAGE_PACKAGE_MANAGERS {
+		if pm.Name == name {
+			return pm, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported language package manager: %s", name)
+}
+
+// Resolve returns the package manager running the commands: the candidate selected with SelectPackageManager,
+// or the package manager itself when it has no candidates. The candidates are looked up on every call,
+// so that the ones installed meanwhile (e.g. pipx by the system packages) are selected.
+func (pm *PackageManager) Resolve() *PackageManager {
+	if pm == nil || len(pm.Candidates) == 0 {
+		return pm
+	}
+	return SelectPackageManager(pm.Candidates...)
+}
+
+// SelectPackageManager returns the first package manager whose binary is on PATH, the last one when none is found.
+func SelectPackageManager(candidates ...*PackageManager) *PackageManager {
+	for _, pm := range candidates {
+		if _, err := exec.LookPath(pm.Name); err == nil {
+			zap.L().Debug("Selected package manager", zap.String("package_manager", pm.Name))
+			return pm
+		}
+	}
+	return candidates[len(candidates)-1]
+}
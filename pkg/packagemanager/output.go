@@ -0,0 +1,109 @@
+package packagemanager
+
+import (
+	"bytes"
+	"devbox/pkg/utils"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// OUTPUT_TAIL_LINES is the number of last output lines of a failed package manager command kept in its error
+var OUTPUT_TAIL_LINES = utils.GetenvInt("DEVBOX_OUTPUT_TAIL_LINES", 20)
+
+// commandOutput streams the output lines of a package manager command to the debug logs, prefixed with the
+// package manager name (e.g. "[dnf] Installing: go"), and keeps the last lines for the error context.
+// Only complete lines are logged, so the output of the concurrent commands never interleaves mid-line.
+type commandOutput struct {
+	prefix string
+	mutex  sync.Mutex
+	tail   []string
+	// stderr is the whole standard error, matched against the retry policy patterns
+	stderr bytes.Buffer
+}
+
+// lineWriter splits a stream of the command output into lines
+type lineWriter struct {
+	output  *commandOutput
+	stream  string
+	partial []byte
+}
+
+// newCommandOutput returns the output of a command of the package manager
+func newCommandOutput(name string) *commandOutput {
+	return &commandOutput{prefix: "[" + name + "] "}
+}
+
+// Stdout and Stderr return the writers of the command standard output and error
+func (o *commandOutput) Stdout() *lineWriter { return &lineWriter{output: o, stream: "stdout"} }
+func (o *commandOutput) Stderr() *lineWriter { return &lineWriter{output: o, stream: "stderr"} }
+
+// Tail returns the last lines of the output, prefixed with the package manager name
+func (o *commandOutput) Tail() string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return strings.Join(o.tail, "\n")
+}
+
+// StderrString returns the whole standard error of the command
+func (o *commandOutput) StderrString() string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.stderr.String()
+}
+
+// line logs a complete line of the stream and keeps it in the tail
+func (o *commandOutput) line(stream string, line string) {
+	line = strings.TrimRight(line, " \t")
+	if line == "" {
+		return
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.tail = append(o.tail, o.prefix+line)
+	if len(o.tail) > OUTPUT_TAIL_LINES {
+		o.tail = o.tail[len(o.tail)-OUTPUT_TAIL_LINES:]
+	}
+	zap.L().Debug(o.prefix+line, zap.String("stream", stream))
+}
+
+// Write logs the complete lines of p, ended by a line feed or a carriage return (e.g. the progress bars),
+// and keeps the last partial line until the next write.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if w.stream == "stderr" {
+		w.output.mutex.Lock()
+		w.output.stderr.Write(p)
+		w.output.mutex.Unlock()
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		index := bytes.IndexAny(w.partial, "\r\n")
+		if index < 0 {
+			break
+		}
+		w.output.line(w.stream, string(w.partial[:index]))
+		w.partial = w.partial[index+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs the last partial line, once the command has exited
+func (w *lineWriter) Flush() {
+	if len(w.partial) > 0 {
+		w.output.line(w.stream, string(w.partial))
+		w.partial = nil
+	}
+}
+
+// runStreamed runs the command of the packages with runWatched, streaming its output
+func (pm *PackageManager) runStreamed(cmd *exec.Cmd, packages []string) (*commandOutput, error) {
+	output := newCommandOutput(pm.Name)
+	stdout, stderr := output.Stdout(), output.Stderr()
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	err := pm.runWatched(cmd, packages)
+	stdout.Flush()
+	stderr.Flush()
+	return output, err
+}
@@ -0,0 +1,120 @@
+package packagemanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// observeLogs records the debug logs of the test
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	core, logs := observer.New(zap.DebugLevel)
+	t.Cleanup(zap.ReplaceGlobals(zap.New(core)))
+	return logs
+}
+
+func Test_CommandOutput_Lines(t *testing.T) {
+	tailLines := OUTPUT_TAIL_LINES
+	t.Cleanup(func() { OUTPUT_TAIL_LINES = tailLines })
+	OUTPUT_TAIL_LINES = 3
+	logs := observeLogs(t)
+
+	output := newCommandOutput("dnf")
+	stdout, stderr := output.Stdout(), output.Stderr()
+	for _, chunk := range []string{"Down", "loading 10%\rDownloading 100%\n", "Installing: go\n\n", "Comp"} {
+		stdout.Write([]byte(chunk))
+	}
+	stderr.Write([]byte("warning: slow mirror\n"))
+	stdout.Write([]byte("lete!"))
+	stdout.Flush()
+	stderr.Flush()
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	want := []string{"[dnf] Downloading 10%", "[dnf] Downloading 100%", "[dnf] Installing: go", "[dnf] warning: slow mirror", "[dnf] Complete!"}
+	if !slices.Equal(messages, want) {
+		t.Fatalf("expected logged lines %q, got: %q", want, messages)
+	}
+	if tail := output.Tail(); tail != strings.Join(want[2:], "\n") {
+		t.Fatalf("expected the last 3 lines, got: %q", tail)
+	}
+	if got := output.StderrString(); got != "warning: slow mirror\n" {
+		t.Fatalf("expected the whole stderr, got: %q", got)
+	}
+}
+
+func Test_CommandOutput_ConcurrentWritersKeepWholeLines(t *testing.T) {
+	logs := observeLogs(t)
+	var wg sync.WaitGroup
+	for writer := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := newCommandOutput(fmt.Sprintf("pm%d", writer)).Stdout()
+			for i := range 50 {
+				// Each line is written in several chunks
+				line := fmt.Sprintf("line %d of writer %d\n", i, writer)
+				for chunk := range strings.SplitAfterSeq(line, " ") {
+					w.Write([]byte(chunk))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries := logs.All()
+	if len(entries) != 200 {
+		t.Fatalf("expected 200 lines, got: %d", len(entries))
+	}
+	for _, entry := range entries {
+		var writer, i, of int
+		if _, err := fmt.Sscanf(entry.Message, "[pm%d] line %d of writer %d", &writer, &i, &of); err != nil || writer != of {
+			t.Fatalf("expected a whole line, got: %q", entry.Message)
+		}
+	}
+}
+
+func Test_Install_StreamsOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+	tailLines := OUTPUT_TAIL_LINES
+	t.Cleanup(func() { OUTPUT_TAIL_LINES = tailLines })
+	OUTPUT_TAIL_LINES = 2
+	logs := observeLogs(t)
+
+	dir := t.TempDir()
+	writeExecutable(t, filepath.Join(dir, "fakepm"), `#!/bin/sh
+echo "resolving $2" >&2
+echo "fetching $2" >&2
+[ "$2" = "broken" ] && { echo "error: $2 has no installation candidate" >&2; exit 1; }
+echo "installed $2"
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	pm := &PackageManager{Name: "fakepm", InstallCmd: "install", Retry: &RetryPolicy{Attempts: 1}}
+	errs := pm.Install([]string{"tool", "broken"})
+	if len(errs) != 1 {
+		t.Fatalf("expected a single error, got: %v", errs)
+	}
+	wantErr := "last output lines:\n[fakepm] fetching broken\n[fakepm] error: broken has no installation candidate"
+	if !strings.HasSuffix(errs[0].Error(), wantErr) || strings.Contains(errs[0].Error(), "resolving broken") {
+		t.Fatalf("expected the error to end with the last output lines, got: %v", errs[0])
+	}
+	for _, line := range []string{"[fakepm] resolving tool", "[fakepm] fetching tool", "[fakepm] installed tool"} {
+		if logs.FilterMessage(line).Len() != 1 {
+			t.Fatalf("expected the output line %q to be logged", line)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package packagemanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// VersionInfo is the installed version of a package
+type VersionInfo struct {
+	Name      string `json:"name"`
+	Version   string `json:"version,omitempty"`
+	Installed bool   `json:"installed"`
+}
+
+// VersionQuerier returns the installed version of each package, keyed by the package as given
+type VersionQuerier func(pm *PackageManager, packages []string) (map[string]VersionInfo, error)
+
+// Query returns the installed version of each package, keyed by the package as given.
+// Packages that are not installed are reported with Installed set to false.
+func (pm *PackageManager) Query(packages []string) (map[string]VersionInfo, error) {
+	if pm == nil {
+		return nil, ErrUnsupportedPackageManager
+	}
+	if pm.QueryVersions == nil {
+		return nil, fmt.Errorf("querying versions is not supported by %s", pm.Name)
+	}
+	return pm.QueryVersions(pm, packages)
+}
+
+// MatchVersions builds the versions of the packages from the installed packages versions,
+// the package names being normalized with normalize before matching.
+func MatchVersions(packages []string, installed map[string]string, normalize func(string) string) map[string]VersionInfo {
+	normalizedInstalled := make(map[string]string, len(installed))
+	for name, version := range installed {
+		normalizedInstalled[normalize(name)] = version
+	}
+	versions := make(map[string]VersionInfo, len(packages))
+	for _, pkg := range packages {
+		name := normalize(pkg)
+		version, exists := normalizedInstalled[name]
+		versions[pkg] = VersionInfo{Name: name, Version: version, Installed: exists}
+	}
+	return versions
+}
+
+// identity returns the package name unchanged
+func identity(name string) string {
+	return name
+}
+
+// runQuery runs the query command and returns its standard output.
+// Query commands exit with a non-zero code when some of the packages are not installed,
+// their output is still parsed in that case.
+func runQuery(name string, args ...string) ([]byte, error) {
+	return runQueryCommand(exec.Command(name, args...))
+}
+
+// runQueryCommand runs the query command like runQuery, with the environment of the package manager
+func (pm *PackageManager) runQueryCommand(args ...string) ([]byte, error) {
+	cmd := exec.Command(pm.Name, args...)
+	cmd.Env = pm.Environ()
+	return runQueryCommand(cmd)
+}
+
+// runQueryCommand runs the query command and returns its standard output, see runQuery
+func runQueryCommand(cmd *exec.Cmd) ([]byte, error) {
+	zap.L().Debug("Running command", zap.String("command", cmd.String()))
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run %s: %w", cmd.String(), err)
+	}
+	return output, nil
+}
+
+// queryRpmVersions queries the installed versions of the packages with rpm (dnf, microdnf, yum, zypper)
+func queryRpmVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery("rpm", append([]string{"-q", "--queryformat", `%{NAME} %{VERSION}-%{RELEASE}\n`}, packages...)...)
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, ParseNameVersionLines(string(output)), identity), nil
+}
+
+// queryDpkgVersions queries the installed versions of the packages with dpkg-query (apt)
+func queryDpkgVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery("dpkg-query", append([]string{"-W", "-f", `${Package} ${Version} ${db:Status-Status}\n`}, packages...)...)
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, ParseDpkgQuery(string(output)), identity), nil
+}
+
+// queryPacmanVersions queries the installed versions of the packages with pacman -Q
+func queryPacmanVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery("pacman", append([]string{"-Q"}, packages...)...)
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, ParseNameVersionLines(string(output)), identity), nil
+}
+
+// queryApkVersions lists the installed packages with apk info
+func queryApkVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery("apk", "info", "-v")
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, ParseApkInfo(string(output)), identity), nil
+}
+
+// queryPipVersions lists the installed python packages with pip list, falling back to pip show
+// for the pip versions without JSON output
+func queryPipVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery(pm.Name, "list", "--format=json")
+	if err != nil {
+		return nil, err
+	}
+	installed, err := ParsePipList(output)
+	if err != nil {
+		zap.L().Debug("Failed to parse pip list output, falling back to pip show", zap.Error(err))
+		names := make([]string, len(packages))
+		for i, pkg := range packages {
+			names[i] = NormalizePipPackage(pkg)
+		}
+		if output, err = runQuery(pm.Name, append([]string{"show"}, names...)...); err != nil {
+			return nil, err
+		}
+		installed = ParsePipShow(string(output))
+	}
+	return MatchVersions(packages, installed, NormalizePipPackage), nil
+}
+
+// queryPipxVersions lists the python CLI tools installed with pipx list --json
+func queryPipxVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery(pm.Name, "list", "--json")
+	if err != nil {
+		return nil, err
+	}
+	installed, err := ParsePipxList(output)
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, installed, NormalizePipPackage), nil
+}
+
+// queryUvVersions lists the python CLI tools installed with uv tool list
+func queryUvVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery(pm.Name, "tool", "list")
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, ParseUvToolList(string(output)), NormalizePipPackage), nil
+}
+
+// queryCargoVersions lists the installed crates with cargo install --list
+func queryCargoVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery(pm.Name, "install", "--list")
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, ParseCargoInstallList(string(output)), NormalizeVersionedPackage("@")), nil
+}
+
+// queryNpmVersions lists the global node packages with npm ls
+func queryNpmVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := pm.runQueryCommand("ls", "-g", "--json", "--depth=0")
+	if err != nil {
+		return nil, err
+	}
+	installed, err := ParseNpmList(output)
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, installed, NormalizeVersionedPackage("@")), nil
+}
+
+// queryPnpmVersions lists the global node packages with pnpm ls
+func queryPnpmVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := pm.runQueryCommand("ls", "-g", "--json", "--depth=0")
+	if err != nil {
+		return nil, err
+	}
+	installed, err := ParsePnpmList(output)
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, installed, NormalizeVersionedPackage("@")), nil
+}
+
+// queryBunVersions lists the global node packages with bun pm ls
+func queryBunVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := pm.runQueryCommand("pm", "ls", "-g")
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, ParseBunList(string(output)), NormalizeVersionedPackage("@")), nil
+}
+
+// queryGoVersions reads the build information of the binaries installed in the go binaries directory
+func queryGoVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery(pm.Name, "env", "GOBIN", "GOPATH")
+	if err != nil {
+		return nil, err
+	}
+	// go env prints one line per variable, GOBIN being empty by default
+	goBin, goPath, _ := strings.Cut(string(output), "\n")
+	goBin = strings.TrimSpace(goBin)
+	if goBin == "" {
+		// The first GOPATH entry holds the installed binaries
+		goPaths := filepath.SplitList(strings.TrimSpace(goPath))
+		if len(goPaths) == 0 || goPaths[0] == "" {
+			return nil, fmt.Errorf("failed to locate the go binaries directory, go env printed neither GOBIN nor GOPATH")
+		}
+		goBin = filepath.Join(goPaths[0], "bin")
+	}
+
+	entries, err := os.ReadDir(goBin)
+	if os.IsNotExist(err) {
+		return MatchVersions(packages, nil, NormalizeVersionedPackage("@")), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read go binaries directory: %w", err)
+	}
+	var binaries []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			binaries = append(binaries, filepath.Join(goBin, entry.Name()))
+		}
+	}
+	if len(binaries) == 0 {
+		// go version -m without files reads the binaries of the current directory
+		return MatchVersions(packages, nil, NormalizeVersionedPackage("@")), nil
+	}
+	if output, err = runQuery(pm.Name, append([]string{"version", "-m"}, binaries...)...); err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, ParseGoVersionM(string(output)), NormalizeVersionedPackage("@")), nil
+}
+
+// queryKrewVersions lists the installed kubectl plugins with kubectl krew list
+func queryKrewVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	output, err := runQuery("kubectl", "krew", "list")
+	if err != nil {
+		return nil, err
+	}
+	return MatchVersions(packages, ParseKrewList(string(output)), identity), nil
+}
+
+// ParseNameVersionLines parses "<name> <version>" lines, as printed by rpm with a query format or pacman -Q.
+// Lines with another format, like the "package x is not installed" messages, are ignored.
+func ParseNameVersionLines(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			installed[fields[0]] = fields[1]
+		}
+	}
+	return installed
+}
+
+// ParseDpkgQuery parses the "<package> <version> <status>" lines printed by dpkg-query,
+// only the packages with the "installed" status are kept.
+func ParseDpkgQuery(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[2] == "installed" {
+			// Multi-arch packages are printed with their architecture (libc6:amd64)
+			name, _, _ := strings.Cut(fields[0], ":")
+			installed[name] = fields[1]
+		}
+	}
+	return installed
+}
+
+// ParseApkInfo parses the "<name>-<version>-r<release>" lines printed by apk info -v, the versions include the release.
+func ParseApkInfo(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		release := strings.LastIndex(line, "-")
+		if release <= 0 {
+			continue
+		}
+		version := strings.LastIndex(line[:release], "-")
+		if version <= 0 {
+			continue
+		}
+		installed[line[:version]] = line[version+1:]
+	}
+	return installed
+}
+
+// ParsePipList parses the output of pip list --format=json into the installed versions, keyed by package name.
+func ParsePipList(output []byte) (map[string]string, error) {
+	var entries []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(output, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse pip list output: %w", err)
+	}
+	installed := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		installed[entry.Name] = entry.Version
+	}
+	return installed, nil
+}
+
+// ParsePipShow parses the "Name: " and "Version: " fields of the packages printed by pip show.
+func ParsePipShow(output string) map[string]string {
+	installed := make(map[string]string)
+	var name string
+	for _, line := range strings.Split(output, "\n") {
+		if value, found := strings.CutPrefix(line, "Name: "); found {
+			name = strings.TrimSpace(value)
+		} else if value, found := strings.CutPrefix(line, "Version: "); found && name != "" {
+			installed[name] = strings.TrimSpace(value)
+		} else if strings.TrimSpace(line) == "---" {
+			name = ""
+		}
+	}
+	return installed
+}
+
+// ParseCargoInstallList parses the "<crate> v<version>:" lines printed by cargo install --list,
+// the indented lines listing the crates binaries are ignored.
+func ParseCargoInstallList(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(line), ":"))
+		if len(fields) >= 2 {
+			installed[fields[0]] = strings.TrimPrefix(fields[1], "v")
+		}
+	}
+	return installed
+}
+
+// ParsePipxList parses the output of pipx list --json into the installed versions, keyed by main package name.
+func ParsePipxList(output []byte) (map[string]string, error) {
+	var list struct {
+		Venvs map[string]struct {
+			Metadata struct {
+				MainPackage struct {
+					Package        string `json:"package"`
+					PackageVersion string `json:"package_version"`
+				} `json:"main_package"`
+			} `json:"metadata"`
+		} `json:"venvs"`
+	}
+	if err := json.Unmarshal(output, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pipx list output: %w", err)
+	}
+	installed := make(map[string]string, len(list.Venvs))
+	for name, venv := range list.Venvs {
+		if venv.Metadata.MainPackage.Package != "" {
+			name = venv.Metadata.MainPackage.Package
+		}
+		installed[name] = venv.Metadata.MainPackage.PackageVersion
+	}
+	return installed, nil
+}
+
+// ParseUvToolList parses the output of uv tool list: a "<tool> v<version>" line per tool, followed by its "- <entry point>" lines.
+func ParseUvToolList(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] == "-" || !strings.HasPrefix(fields[1], "v") {
+			continue
+		}
+		installed[fields[0]] = strings.TrimPrefix(fields[1], "v")
+	}
+	return installed
+}
+
+// ParseNpmList parses the output of npm ls -g --json into the installed versions, keyed by package name.
+func ParseNpmList(output []byte) (map[string]string, error) {
+	var tree struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(output, &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse npm ls output: %w", err)
+	}
+	installed := make(map[string]string, len(tree.Dependencies))
+	for name, dependency := range tree.Dependencies {
+		installed[name] = dependency.Version
+	}
+	return installed, nil
+}
+
+// ParsePnpmList parses the output of pnpm ls -g --json into the installed versions, keyed by package name.
+// pnpm prints a list holding the global project.
+func ParsePnpmList(output []byte) (map[string]string, error) {
+	var projects []struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(output, &projects); err != nil {
+		return nil, fmt.Errorf("failed to parse pnpm ls output: %w", err)
+	}
+	installed := make(map[string]string)
+	for _, project := range projects {
+		for name, dependency := range project.Dependencies {
+			installed[name] = dependency.Version
+		}
+	}
+	return installed, nil
+}
+
+// ParseBunList parses the output of bun pm ls -g into the installed versions, keyed by package name:
+// a "<directory> node_modules (<count>)" header followed by a "├── <name>@<version>" line per package.
+func ParseBunList(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || (fields[0] != "├──" && fields[0] != "└──") {
+			continue
+		}
+		if index := strings.LastIndex(fields[1], "@"); index > 0 {
+			installed[fields[1][:index]] = fields[1][index+1:]
+		}
+	}
+	return installed
+}
+
+// ParseGoVersionM parses the output of go version -m into the installed versions, keyed by main package path.
+// The version is the one of the module providing the main package.
+func ParseGoVersionM(output string) map[string]string {
+	installed := make(map[string]string)
+	var path string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case !strings.HasPrefix(line, "\t"):
+			// New binary: "<file>: <go version>"
+			path = ""
+		case fields[0] == "path" && len(fields) >= 2:
+			path = fields[1]
+		case fields[0] == "mod" && len(fields) >= 3 && path != "":
+			installed[path] = fields[2]
+		}
+	}
+	return installed
+}
+
+// ParseKrewList parses the output of kubectl krew list: one plugin per line,
+// optionally followed by its version with the older krew versions printing a table.
+func ParseKrewList(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "PLUGIN" {
+			continue
+		}
+		version := ""
+		if len(fields) >= 2 {
+			version = fields[1]
+		}
+		installed[fields[0]] = version
+	}
+	return installed
+}
+
+// NormalizePipPackage returns the normalized name of a python package requirement (PEP 503),
+// without its extras and version specifiers.
+func NormalizePipPackage(pkg string) string {
+	if index := strings.IndexAny(pkg, "[=<>!~; "); index >= 0 {
+		pkg = pkg[:index]
+	}
+	return strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(strings.TrimSpace(pkg)))
+}
+
+// NormalizeVersionedPackage returns a function removing the version suffix of the packages,
+// the version being separated by the given separator (e.g. "gopls@latest", "@types/node@20").
+// A separator at the start of the package is kept, it is a scope.
+func NormalizeVersionedPackage(separator string) func(string) string {
+	return func(pkg string) string {
+		if index := strings.LastIndex(pkg, separator); index > 0 {
+			return pkg[:index]
+		}
+		return pkg
+	}
+}
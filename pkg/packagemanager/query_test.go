@@ -0,0 +1,325 @@
+package packagemanager
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_ParsePipList(t *testing.T) {
+	output := `[{"name": "black", "version": "24.4.2"}, {"name": "PyYAML", "version": "6.0.1"}, {"name": "typing_extensions", "version": "4.12.2"}]`
+	installed, err := ParsePipList([]byte(output))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	versions := MatchVersions([]string{"black", "pyyaml", "typing-extensions>=4", "ruff"}, installed, NormalizePipPackage)
+	tests := []struct {
+		pkg           string
+		wantInstalled bool
+		wantVersion   string
+	}{
+		{"black", true, "24.4.2"},
+		{"pyyaml", true, "6.0.1"},
+		{"typing-extensions>=4", true, "4.12.2"},
+		{"ruff", false, ""},
+	}
+	for _, tt := range tests {
+		if got := versions[tt.pkg]; got.Installed != tt.wantInstalled || got.Version != tt.wantVersion {
+			t.Fatalf("unexpected version for %s: %+v", tt.pkg, got)
+		}
+	}
+
+	if _, err := ParsePipList([]byte("WARNING: not json")); err == nil || !strings.Contains(err.Error(), "failed to parse pip list output") {
+		t.Fatalf("expected parse error, got: %v", err)
+	}
+}
+
+func Test_Query_NotSupported(t *testing.T) {
+	pm := &PackageManager{Name: "custom"}
+	if _, err := pm.Query([]string{"pkg"}); err == nil || !strings.Contains(err.Error(), "not supported by custom") {
+		t.Fatalf("expected not supported error, got: %v", err)
+	}
+}
+
+func Test_QueryParsers(t *testing.T) {
+	tests := []struct {
+		name   string
+		parse  func(string) map[string]string
+		output string
+		want   map[string]string
+	}{
+		{
+			name:  "rpm",
+			parse: ParseNameVersionLines,
+			output: `make 4.4.1-6.fc40
+golang 1.22.5-1.fc40
+package gofmt is not installed
+`,
+			want: map[string]string{"make": "4.4.1-6.fc40", "golang": "1.22.5-1.fc40"},
+		},
+		{
+			name:  "pacman",
+			parse: ParseNameVersionLines,
+			output: `go 2:1.22.5-1
+make 4.4.1-2
+`,
+			want: map[string]string{"go": "2:1.22.5-1", "make": "4.4.1-2"},
+		},
+		{
+			name:  "dpkg-query",
+			parse: ParseDpkgQuery,
+			output: `make 4.3-4.1build1 installed
+golang-go 2:1.22~2build1 installed
+libc6:amd64 2.39-0ubuntu8.2 installed
+valgrind 1:3.22.0-0ubuntu3 not-installed
+`,
+			want: map[string]string{"make": "4.3-4.1build1", "golang-go": "2:1.22~2build1", "libc6": "2.39-0ubuntu8.2"},
+		},
+		{
+			name:  "apk info",
+			parse: ParseApkInfo,
+			output: `musl-1.2.5-r0
+go-1.22.5-r0
+py3-pip-24.0-r2
+`,
+			want: map[string]string{"musl": "1.2.5-r0", "go": "1.22.5-r0", "py3-pip": "24.0-r2"},
+		},
+		{
+			name:  "pip show",
+			parse: ParsePipShow,
+			output: `Name: black
+Version: 24.4.2
+Summary: The uncompromising code formatter.
+---
+Name: mypy
+Version: 1.10.1
+Summary: Optional static typing for Python
+`,
+			want: map[string]string{"black": "24.4.2", "mypy": "1.10.1"},
+		},
+		{
+			name:  "uv tool list",
+			parse: ParseUvToolList,
+			output: `black v24.4.2
+- black
+- blackd
+ruff v0.5.0
+- ruff
+warning: tool pylint environment is broken
+`,
+			want: map[string]string{"black": "24.4.2", "ruff": "0.5.0"},
+		},
+		{
+			name:  "bun pm ls -g",
+			parse: ParseBunList,
+			output: `/home/user/.local/share/bun/install/global node_modules (3)
+├── @types/node@20.14.10
+├── eslint@9.6.0
+└── prettier@3.3.2
+`,
+			want: map[string]string{"@types/node": "20.14.10", "eslint": "9.6.0", "prettier": "3.3.2"},
+		},
+		{
+			name:  "cargo install --list",
+			parse: ParseCargoInstallList,
+			output: `cargo-audit v0.20.0:
+    cargo-audit
+cargo-edit v0.12.3:
+    cargo-add
+    cargo-rm
+    cargo-set-version
+    cargo-upgrade
+ripgrep v14.1.0 (https://github.com/BurntSushi/ripgrep#e50df40a):
+    rg
+`,
+			want: map[string]string{"cargo-audit": "0.20.0", "cargo-edit": "0.12.3", "ripgrep": "14.1.0"},
+		},
+		{
+			name:  "go version -m",
+			parse: ParseGoVersionM,
+			output: `/home/user/go/bin/gopls: go1.22.5
+	path	golang.org/x/tools/gopls
+	mod	golang.org/x/tools/gopls	v0.16.1	h1:1hO/dCeUvjEYx3V0rVvCtOD2ARG5lkdCm/U0i8MT5R0=
+	dep	github.com/BurntSushi/toml	v1.2.1	h1:9F2/+DoOYIOksmaJFPw1tGFy1eDnIJXg+UHjuD8lTak=
+	build	-buildmode=exe
+/home/user/go/bin/dlv: go1.22.5
+	path	github.com/go-delve/delve/cmd/dlv
+	mod	github.com/go-delve/delve	v1.23.0	h1:jYgZISZ14KAO3ys8kD07kjrowrygE9F9SIwnpz9xXys=
+/home/user/go/bin/script.sh: could not read Go build info from /home/user/go/bin/script.sh: unrecognized file format
+`,
+			want: map[string]string{"golang.org/x/tools/gopls": "v0.16.1", "github.com/go-delve/delve/cmd/dlv": "v1.23.0"},
+		},
+		{
+			name:  "krew list",
+			parse: ParseKrewList,
+			output: `ctx
+krew
+ns
+`,
+			want: map[string]string{"ctx": "", "krew": "", "ns": ""},
+		},
+		{
+			name:  "krew list table",
+			parse: ParseKrewList,
+			output: `PLUGIN  VERSION
+ctx     v0.9.5
+krew    v0.4.4
+`,
+			want: map[string]string{"ctx": "v0.9.5", "krew": "v0.4.4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.parse(tt.output); !maps.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_ParseNpmList(t *testing.T) {
+	output := `{
+  "name": "lib",
+  "dependencies": {
+    "@types/node": {"version": "20.14.10", "overridden": false},
+    "eslint": {"version": "9.6.0", "overridden": false}
+  }
+}`
+	installed, err := ParseNpmList([]byte(output))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	versions := MatchVersions([]string{"@types/node@20", "eslint", "prettier"}, installed, NormalizeVersionedPackage("@"))
+	if got := versions["@types/node@20"]; !got.Installed || got.Version != "20.14.10" || got.Name != "@types/node" {
+		t.Fatalf("unexpected scoped package version: %+v", got)
+	}
+	if got := versions["eslint"]; got.Version != "9.6.0" {
+		t.Fatalf("unexpected eslint version: %+v", got)
+	}
+	if versions["prettier"].Installed {
+		t.Fatalf("expected prettier not to be installed")
+	}
+}
+
+func Test_ParsePnpmList(t *testing.T) {
+	output := `[
+  {
+    "path": "/home/user/.local/share/pnpm/global/5",
+    "dependencies": {
+      "@types/node": {"from": "@types/node", "version": "20.14.10"},
+      "eslint": {"from": "eslint", "version": "9.6.0"}
+    }
+  }
+]`
+	installed, err := ParsePnpmList([]byte(output))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := map[string]string{"@types/node": "20.14.10", "eslint": "9.6.0"}; !maps.Equal(installed, want) {
+		t.Fatalf("expected %v, got: %v", want, installed)
+	}
+	if _, err := ParsePnpmList([]byte(`{"dependencies": {}}`)); err == nil {
+		t.Fatalf("expected a parse error")
+	}
+}
+
+func Test_ParsePipxList(t *testing.T) {
+	output := `{
+  "pipx_spec_version": "0.1",
+  "venvs": {
+    "black": {"metadata": {"main_package": {"package": "black", "package_version": "24.4.2"}}},
+    "kubediagrams": {"metadata": {"main_package": {"package": "KubeDiagrams", "package_version": "0.2.0"}}}
+  }
+}`
+	installed, err := ParsePipxList([]byte(output))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	versions := MatchVersions([]string{"black==24.4.2", "kubediagrams", "ruff"}, installed, NormalizePipPackage)
+	if got := versions["black==24.4.2"]; !got.Installed || got.Version != "24.4.2" {
+		t.Fatalf("unexpected black version: %+v", got)
+	}
+	if got := versions["kubediagrams"]; !got.Installed || got.Version != "0.2.0" {
+		t.Fatalf("unexpected KubeDiagrams version: %+v", got)
+	}
+	if versions["ruff"].Installed {
+		t.Fatalf("expected ruff not to be installed")
+	}
+
+	if _, err := ParsePipxList([]byte("not json")); err == nil {
+		t.Fatalf("expected a parse error")
+	}
+}
+
+func Test_Query_PartialFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+	dir := t.TempDir()
+	// rpm exits with the number of packages not installed
+	writeExecutable(t, filepath.Join(dir, "rpm"), `#!/bin/sh
+echo "make 4.4.1-6.fc40"
+echo "package gofmt is not installed"
+exit 1
+`)
+	t.Setenv("PATH", dir)
+
+	versions, err := DNF_PACKAGE_MANAGER.Query([]string{"make", "gofmt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := versions["make"]; !got.Installed || got.Version != "4.4.1-6.fc40" {
+		t.Fatalf("unexpected make version: %+v", got)
+	}
+	if versions["gofmt"].Installed {
+		t.Fatalf("expected gofmt not to be installed")
+	}
+
+	// The query command is missing
+	if _, err := APT_PACKAGE_MANAGER.Query([]string{"make"}); err == nil {
+		t.Fatalf("expected an error when dpkg-query is missing")
+	}
+}
+
+func Test_Query_GoBinariesDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+	dir := t.TempDir()
+	goBin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(goBin, 0700); err != nil {
+		t.Fatalf("failed to create go binaries directory: %v", err)
+	}
+	tests := []struct {
+		name            string
+		env             string
+		wantErrContains string
+	}{
+		{name: "empty GOBIN and GOPATH", env: "\n\n", wantErrContains: "go env printed neither GOBIN nor GOPATH"},
+		{name: "no installed binary", env: goBin + "\n" + dir + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// go version fails so that querying the binaries of an empty directory is detected
+			writeExecutable(t, filepath.Join(dir, "go"), "#!/bin/sh\nif [ \"$1\" = env ]; then printf '"+tt.env+"'; else exit 1; fi\n")
+			t.Setenv("PATH", dir)
+
+			versions, err := GOLANG_PACKAGE_MANAGER.Query([]string{"golang.org/x/tools/gopls@latest"})
+			if tt.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if versions["golang.org/x/tools/gopls@latest"].Installed {
+				t.Fatalf("expected gopls not to be installed, got: %+v", versions)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package packagemanager
+
+import (
+	"devbox/pkg/utils"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"os/exec"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// DEFAULT_RETRY_ATTEMPTS is the maximum number of runs of a failing package manager command, 1 disables the retries
+	DEFAULT_RETRY_ATTEMPTS = utils.GetenvInt("DEVBOX_RETRY_ATTEMPTS", 3)
+
+	// DEFAULT_RETRY_DELAY is the delay before the first retry, doubled after each retry
+	DEFAULT_RETRY_DELAY = utils.GetenvDuration("DEVBOX_RETRY_DELAY", 2*time.Second)
+
+	// NETWORK_TRANSIENT_PATTERNS are the stderr patterns of the network failures shared by every package manager
+	NETWORK_TRANSIENT_PATTERNS = []string{
+		`timed? ?out`,
+		`connection (reset|refused|closed)`,
+		`temporary failure in name resolution`,
+		`could not resolve host`,
+		`network is unreachable`,
+		`tls handshake`,
+		`unexpected eof`,
+		`bad gateway|service unavailable|gateway time-?out`,
+		`too many requests`,
+	}
+
+	// DEFAULT_RETRY_POLICY retries the network failures of the package managers without a dedicated policy
+	DEFAULT_RETRY_POLICY = NewRetryPolicy(nil, nil)
+
+	// RPM_RETRY_POLICY retries the mirrors and metadata download failures of dnf, microdnf, yum and zypper
+	RPM_RETRY_POLICY = NewRetryPolicy(
+		[]string{`curl error`, `cannot download`, `failed to download`, `all mirrors were tried`, `cannot prepare internal mirrorlist`, `system management is locked`},
+		[]string{`no match for argument`, `unable to find a match`, `package '.*' not found`, `no provider of`},
+	)
+
+	// APT_RETRY_POLICY retries the repositories fetch failures and the dpkg lock contention of apt
+	APT_RETRY_POLICY = NewRetryPolicy(
+		[]string{`failed to fetch`, `temporary failure resolving`, `could not get lock`, `unable to acquire the dpkg frontend lock`},
+		[]string{`unable to locate package`, `has no installation candidate`},
+	)
+
+	// GO_RETRY_POLICY retries the module proxy and VCS failures of go install
+	GO_RETRY_POLICY = NewRetryPolicy(
+		[]string{`dial tcp`, `proxy\.golang\.org`, `sum\.golang\.org`, `verifying module: .*: reading`},
+		[]string{`unknown revision`, `cannot find module providing package`, `invalid version`, `is not a main package`, `build constraints exclude`},
+	)
+
+	// PIP_RETRY_POLICY retries the package index failures of pip, pipx and uv
+	PIP_RETRY_POLICY = NewRetryPolicy(
+		[]string{`readtimeouterror`, `max retries exceeded`, `connectionerror`, `failed to fetch`, `error sending request`},
+		[]string{`no matching distribution found`, `could not find a version that satisfies`, `not found in the package registry`, `already seems to be installed`},
+	)
+
+	// NPM_RETRY_POLICY retries the registry failures of npm, pnpm and bun
+	NPM_RETRY_POLICY = NewRetryPolicy(
+		[]string{`etimedout`, `econnreset`, `eai_again`, `socket hang up`, `err_socket_timeout`},
+		[]string{`e404`, `err_pnpm_fetch_404`, `eacces`, `no matching version`, `notarget`},
+	)
+
+	// CARGO_RETRY_POLICY retries the registry and download failures of cargo
+	CARGO_RETRY_POLICY = NewRetryPolicy(
+		[]string{`spurious network error`, `failed to download`, `failed to get .* as a dependency`, `failed to query replaced source registry`},
+		[]string{`could not find .* in registry`, `could not compile`, `no matching package named`},
+	)
+
+	// RETRY_SLEEP waits between the attempts, it is replaced in tests
+	RETRY_SLEEP = time.Sleep
+
+	// retries records the commands which needed retries, reported by Retries
+	retries struct {
+		sync.Mutex
+		list []*Retry
+	}
+)
+
+// RetryPolicy describes how the failed package manager commands are retried. A failure is permanent when its stderr
+// matches a permanent pattern, transient when its exit code or its stderr matches a transient one, and permanent otherwise.
+// Only the transient failures are retried, after an exponential backoff with jitter.
+type RetryPolicy struct {
+	// Attempts is the maximum number of runs of a command, 1 disables the retries
+	Attempts int
+	// InitialDelay is the delay before the first retry, multiplied by Multiplier after each retry up to MaxDelay
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	// Jitter is the fraction of the delay randomly added or removed (e.g. 0.2 for ±20%)
+	Jitter float64
+	// TransientExitCodes are the exit codes of the transient failures, whatever their stderr
+	TransientExitCodes []int
+	// TransientPatterns and PermanentPatterns are matched against the stderr of the failures
+	TransientPatterns []*regexp.Regexp
+	PermanentPatterns []*regexp.Regexp
+}
+
+// Retry is a package manager command which needed retries
+type Retry struct {
+	PackageManager string   `json:"package_manager"`
+	Packages       []string `json:"packages"`
+	Attempts       int      `json:"attempts"`
+	Succeeded      bool     `json:"succeeded"`
+}
+
+// NewRetryPolicy returns the default retry policy, retrying the network failures and the given transient stderr patterns.
+// The patterns are case-insensitive regular expressions.
+func NewRetryPolicy(transientPatterns []string, permanentPatterns []string) *RetryPolicy {
+	return &RetryPolicy{
+		Attempts:          DEFAULT_RETRY_ATTEMPTS,
+		InitialDelay:      DEFAULT_RETRY_DELAY,
+		MaxDelay:          30 * time.Second,
+		Multiplier:        2,
+		Jitter:            0.2,
+		TransientPatterns: compilePatterns(append(slices.Clone(NETWORK_TRANSIENT_PATTERNS), transientPatterns...)),
+		PermanentPatterns: compilePatterns(permanentPatterns),
+	}
+}
+
+// compilePatterns compiles the case-insensitive patterns
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		compiled[i] = regexp.MustCompile("(?i)" + pattern)
+	}
+	return compiled
+}
+
+// IsTransient reports whether the failure of a command is transient and can be retried.
+// The commands which could not be started or timed out are permanent failures.
+func (p *RetryPolicy) IsTransient(err error, stderr string) bool {
+	var exitErr *exec.ExitError
+	if err == nil || errors.Is(err, ErrCommandTimeout) || !errors.As(err, &exitErr) {
+		return false
+	}
+	for _, pattern := range p.PermanentPatterns {
+		if pattern.MatchString(stderr) {
+			return false
+		}
+	}
+	if slices.Contains(p.TransientExitCodes, exitErr.ExitCode()) {
+		return true
+	}
+	for _, pattern := range p.TransientPatterns {
+		if pattern.MatchString(stderr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns the delay before the given retry, starting at 1
+func (p *RetryPolicy) Delay(retry int) time.Duration {
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(retry-1))
+	if p.MaxDelay > 0 {
+		delay = math.Min(delay, float64(p.MaxDelay))
+	}
+	delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(delay)
+}
+
+// retryPolicy returns the retry policy of the package manager, the default one when it has none
+func (pm *PackageManager) retryPolicy() *RetryPolicy {
+	if pm.Retry == nil {
+		return DEFAULT_RETRY_POLICY
+	}
+	return pm.Retry
+}
+
+// recordRetry records a command which needed retries
+func recordRetry(retry *Retry) {
+	retries.Lock()
+	defer retries.Unlock()
+	retries.list = append(retries.list, retry)
+}
+
+// Retries returns the commands which needed retries since the start of devbox, in their completion order.
+func Retries() []*Retry {
+	retries.Lock()
+	defer retries.Unlock()
+	return slices.Clone(retries.list)
+}
@@ -0,0 +1,112 @@
+package packagemanager
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exitError returns the error of a command exiting with the code
+func exitError(t *testing.T, code string) error {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("skip shell tests on Windows")
+	}
+	err := exec.Command("sh", "-c", "exit "+code).Run()
+	if err == nil {
+		t.Fatalf("expected the command to fail")
+	}
+	return err
+}
+
+func Test_RetryPolicy_IsTransient(t *testing.T) {
+	policy := NewRetryPolicy([]string{`failed to fetch`}, []string{`unable to locate package`})
+	policy.TransientExitCodes = []int{75}
+	tests := []struct {
+		name   string
+		err    error
+		stderr string
+		want   bool
+	}{
+		{name: "success", err: nil, want: false},
+		{name: "network failure", err: exitError(t, "1"), stderr: "curl: (56) Connection reset by peer", want: true},
+		{name: "manager pattern", err: exitError(t, "100"), stderr: "E: Failed to fetch http://deb.debian.org/pool/main", want: true},
+		{name: "permanent pattern wins", err: exitError(t, "100"), stderr: "E: Unable to locate package foo\nE: Failed to fetch", want: false},
+		{name: "transient exit code", err: exitError(t, "75"), stderr: "", want: true},
+		{name: "unknown failure", err: exitError(t, "1"), stderr: "error: invalid option", want: false},
+		{name: "command not started", err: errors.New("exec: \"dnf\": executable file not found in $PATH"), stderr: "timed out", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.IsTransient(tt.err, tt.stderr); got != tt.want {
+				t.Fatalf("expected %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_RetryPolicy_Delay(t *testing.T) {
+	policy := &RetryPolicy{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2, Jitter: 0.2}
+	for retry, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		for range 20 {
+			if got := policy.Delay(retry); got < want*8/10 || got > want*12/10 {
+				t.Fatalf("expected the delay of retry %d to be %v ±20%%, got: %v", retry, want, got)
+			}
+		}
+	}
+}
+
+func Test_Install_RetriesTransientFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "calls.log")
+	// The first install of flaky fails with a network error, the installs of missing always fail
+	writeExecutable(t, filepath.Join(dir, "fakepm"), `#!/bin/sh
+echo "$@" >> "`+logFile+`"
+case "$2" in
+flaky) [ -f "`+dir+`/flaky" ] && exit 0; touch "`+dir+`/flaky"; echo "Connection reset by peer" >&2; exit 1 ;;
+missing) echo "error: target not found: missing" >&2; exit 1 ;;
+esac
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	var delays []time.Duration
+	sleep := RETRY_SLEEP
+	t.Cleanup(func() { RETRY_SLEEP = sleep })
+	RETRY_SLEEP = func(delay time.Duration) { delays = append(delays, delay) }
+
+	pm := &PackageManager{
+		Name:       "fakepm",
+		InstallCmd: "install",
+		Retry:      &RetryPolicy{Attempts: 3, InitialDelay: time.Second, Multiplier: 2, TransientPatterns: compilePatterns(NETWORK_TRANSIENT_PATTERNS), PermanentPatterns: compilePatterns([]string{`target not found`})},
+	}
+	before := len(Retries())
+	errs := pm.Install([]string{"flaky", "missing"})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "target not found") || strings.Contains(errs[0].Error(), "attempts") {
+		t.Fatalf("expected a single permanent failure of missing, got: %v", errs)
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("failed to read calls log: %v", err)
+	}
+	want := []string{"install flaky", "install flaky", "install missing"}
+	if calls := strings.Split(strings.TrimSpace(string(data)), "\n"); !slices.Equal(calls, want) {
+		t.Fatalf("expected calls %q, got: %q", want, calls)
+	}
+	if !slices.Equal(delays, []time.Duration{time.Second}) {
+		t.Fatalf("expected a single retry after 1s, got: %v", delays)
+	}
+	retries := Retries()[before:]
+	if len(retries) != 1 || retries[0].PackageManager != "fakepm" || !slices.Equal(retries[0].Packages, []string{"flaky"}) || retries[0].Attempts != 2 || !retries[0].Succeeded {
+		t.Fatalf("expected the retry of flaky to be recorded, got: %+v", retries)
+	}
+}
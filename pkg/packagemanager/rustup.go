@@ -0,0 +1,206 @@
+package packagemanager
+
+import (
+	"devbox/pkg/utils"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// RUSTUP_COMPONENT_PREFIX marks the rustup packages naming a component (e.g. "component:clippy")
+	RUSTUP_COMPONENT_PREFIX = "component:"
+	// RUSTUP_TARGET_PREFIX marks the rustup packages naming a compilation target (e.g. "target:wasm32-unknown-unknown")
+	RUSTUP_TARGET_PREFIX = "target:"
+	// RUSTUP_PROFILE is the rustup profile of the installed channels, the components are added on top of it
+	RUSTUP_PROFILE = "minimal"
+	// RUSTUP_ARCH_PLACEHOLDER is replaced by the Rust name of the host architecture in the targets (e.g. "{arch}-unknown-linux-musl")
+	RUSTUP_ARCH_PLACEHOLDER = "{arch}"
+)
+
+var (
+	// RUSTUP_ARCHS are the Rust names of the Go architectures, when they differ
+	RUSTUP_ARCHS = map[string]string{
+		"amd64":   "x86_64",
+		"arm64":   "aarch64",
+		"386":     "i686",
+		"ppc64le": "powerpc64le",
+		"riscv64": "riscv64gc",
+	}
+
+	// RUSTUP_PACKAGE_MANAGER installs Rust channels, components and targets with rustup.
+	// The packages are channels (e.g. "stable", "nightly" or "1.79.0"), components prefixed with RUSTUP_COMPONENT_PREFIX
+	// and targets prefixed with RUSTUP_TARGET_PREFIX. The components and targets are installed for every given channel,
+	// for the default channel when none is given, and the first channel becomes the default one.
+	RUSTUP_PACKAGE_MANAGER = &PackageManager{
+		Name:            "rustup",
+		MultiInstall:    true,
+		SudoRequired:    false,
+		InstallPackages: installRustupPackages,
+		QueryVersions:   queryRustupVersions,
+		Provides:        []string{"cargo"},
+		Environment: map[string]string{
+			"CARGO_HOME":  filepath.Join(utils.XDG_DATA_HOME, "cargo"),
+			"RUSTUP_HOME": filepath.Join(utils.XDG_DATA_HOME, "rustup"),
+		},
+	}
+)
+
+// rustupPackages are the rustup packages grouped by kind
+type rustupPackages struct {
+	channels   []string
+	components []string
+	targets    []string
+}
+
+// parseRustupPackages groups the rustup packages by kind
+func parseRustupPackages(packages []string) *rustupPackages {
+	parsed := &rustupPackages{}
+	for _, pkg := range packages {
+		pkg = expandRustupArch(pkg)
+		if component, isComponent := strings.CutPrefix(pkg, RUSTUP_COMPONENT_PREFIX); isComponent {
+			parsed.components = append(parsed.components, component)
+		} else if target, isTarget := strings.CutPrefix(pkg, RUSTUP_TARGET_PREFIX); isTarget {
+			parsed.targets = append(parsed.targets, target)
+		} else {
+			parsed.channels = append(parsed.channels, pkg)
+		}
+	}
+	return parsed
+}
+
+// expandRustupArch replaces the architecture placeholder of the package with the Rust name of the host architecture
+func expandRustupArch(pkg string) string {
+	arch, renamed := RUSTUP_ARCHS[runtime.GOARCH]
+	if !renamed {
+		arch = runtime.GOARCH
+	}
+	return strings.ReplaceAll(pkg, RUSTUP_ARCH_PLACEHOLDER, arch)
+}
+
+// RustupArgs returns the rustup command lines installing the packages, without the rustup binary
+// (e.g. "toolchain install stable --profile minimal --component clippy --target wasm32-unknown-unknown").
+func RustupArgs(packages []string) [][]string {
+	parsed := parseRustupPackages(packages)
+	var commands [][]string
+	if len(parsed.channels) == 0 {
+		if len(parsed.components) > 0 {
+			commands = append(commands, append([]string{"component", "add"}, parsed.components...))
+		}
+		if len(parsed.targets) > 0 {
+			commands = append(commands, append([]string{"target", "add"}, parsed.targets...))
+		}
+		return commands
+	}
+
+	args := append([]string{"toolchain", "install"}, parsed.channels...)
+	args = append(args, "--profile", RUSTUP_PROFILE)
+	for _, component := range parsed.components {
+		args = append(args, "--component", component)
+	}
+	for _, target := range parsed.targets {
+		args = append(args, "--target", target)
+	}
+	return append(commands, args, []string{"default", parsed.channels[0]})
+}
+
+// installRustupPackages runs the rustup commands installing the packages.
+// The rustup installer of the distributions shipping rustup-init instead of rustup is run first.
+func installRustupPackages(pm *PackageManager, packages []string) []error {
+	rustup, err := rustupBinary(pm)
+	if err != nil {
+		return []error{err}
+	}
+	zap.L().Info("Installing packages", zap.Strings("packages", packages), zap.String("package_manager", pm.Name))
+	for _, args := range RustupArgs(packages) {
+		if err := runRustup(pm, rustup, args...); err != nil {
+			zap.L().Error("Error installing packages", zap.Strings("packages", packages), zap.String("package_manager", pm.Name), zap.Error(err))
+			return []error{fmt.Errorf("failed to install packages using %s: %w", pm.Name, err)}
+		}
+	}
+	zap.L().Info("Successfully installed packages", zap.Strings("packages", packages), zap.String("package_manager", pm.Name))
+	return nil
+}
+
+// rustupBinary returns the rustup binary on PATH, or installs it in CARGO_HOME with rustup-init when only the installer is available
+func rustupBinary(pm *PackageManager) (string, error) {
+	if path, err := exec.LookPath(pm.Name); err == nil {
+		return path, nil
+	}
+	installer, err := exec.LookPath("rustup-init")
+	if err != nil {
+		return "", fmt.Errorf("neither rustup nor rustup-init is installed, select a rustup channel of the rust toolchain (e.g. rust@stable)")
+	}
+	if err := runRustup(pm, installer, "-y", "--no-modify-path", "--default-toolchain", "none"); err != nil {
+		return "", fmt.Errorf("failed to install rustup: %w", err)
+	}
+	return filepath.Join(utils.Getenv("CARGO_HOME", pm.Environment["CARGO_HOME"]), "bin", pm.Name), nil
+}
+
+// runRustup runs the rustup binary with the environment and the timeout of the package manager, streaming its output
+func runRustup(pm *PackageManager, binary string, args ...string) error {
+	cmd := exec.Command(binary, args...)
+	cmd.Env = pm.Environ()
+
+	zap.L().Debug("Running command", zap.String("command", cmd.String()))
+	if output, err := pm.runStreamed(cmd, args); err != nil {
+		return fmt.Errorf("%s: %w%s", cmd.String(), err, outputContext(output))
+	}
+	return nil
+}
+
+// queryRustupVersions lists the installed channels, and the components and targets of the default channel
+func queryRustupVersions(pm *PackageManager, packages []string) (map[string]VersionInfo, error) {
+	var installed []string
+	queries := []struct {
+		prefix string
+		args   []string
+	}{
+		{"", []string{"toolchain", "list"}},
+		{RUSTUP_COMPONENT_PREFIX, []string{"component", "list", "--installed"}},
+		{RUSTUP_TARGET_PREFIX, []string{"target", "list", "--installed"}},
+	}
+	for _, query := range queries {
+		output, err := pm.runQueryCommand(query.args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range ParseRustupList(string(output)) {
+			installed = append(installed, query.prefix+name)
+		}
+	}
+	return MatchRustupVersions(packages, installed), nil
+}
+
+// ParseRustupList parses the output of rustup toolchain, component or target list into the listed names,
+// without their "(default)" or "(installed)" annotations.
+func ParseRustupList(output string) []string {
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "no installed") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	return names
+}
+
+// MatchRustupVersions builds the versions of the rustup packages from the installed names, which can be suffixed
+// with the host triple (e.g. "stable-x86_64-unknown-linux-gnu" or "clippy-x86_64-unknown-linux-gnu"). Rustup reports no version.
+func MatchRustupVersions(packages []string, installed []string) map[string]VersionInfo {
+	versions := make(map[string]VersionInfo, len(packages))
+	for _, pkg := range packages {
+		expanded := expandRustupArch(pkg)
+		versions[pkg] = VersionInfo{Name: expanded, Installed: slices.ContainsFunc(installed, func(name string) bool {
+			return name == expanded || strings.HasPrefix(name, expanded+"-")
+		})}
+	}
+	return versions
+}
@@ -0,0 +1,99 @@
+package packagemanager
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_RustupArgs(t *testing.T) {
+	arch := runtime.GOARCH
+	if renamed, exists := RUSTUP_ARCHS[arch]; exists {
+		arch = renamed
+	}
+	tests := []struct {
+		name     string
+		packages []string
+		want     [][]string
+	}{
+		{
+			name:     "channels with components and targets",
+			packages: []string{"stable", "component:clippy", "target:{arch}-unknown-linux-musl", "nightly", "component:rust-src"},
+			want: [][]string{
+				{"toolchain", "install", "stable", "nightly", "--profile", "minimal", "--component", "clippy", "--component", "rust-src", "--target", arch + "-unknown-linux-musl"},
+				{"default", "stable"},
+			},
+		},
+		{
+			name:     "pinned channel",
+			packages: []string{"1.79.0"},
+			want:     [][]string{{"toolchain", "install", "1.79.0", "--profile", "minimal"}, {"default", "1.79.0"}},
+		},
+		{
+			name:     "default channel",
+			packages: []string{"component:rust-analyzer", "target:wasm32-unknown-unknown"},
+			want:     [][]string{{"component", "add", "rust-analyzer"}, {"target", "add", "wasm32-unknown-unknown"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RustupArgs(tt.packages)
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Fatalf("expected %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_RustupPackageManager(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "calls.log")
+	writeExecutable(t, filepath.Join(dir, "rustup"), `#!/bin/sh
+echo "$RUSTUP_HOME $@" >> "`+logFile+`"
+case "$1 $2" in
+"toolchain list") printf 'stable-x86_64-unknown-linux-gnu (default)\n1.79.0-x86_64-unknown-linux-gnu\n' ;;
+"component list") printf 'cargo-x86_64-unknown-linux-gnu\nclippy-x86_64-unknown-linux-gnu\nrust-src\n' ;;
+"target list") printf 'wasm32-unknown-unknown\n' ;;
+esac
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("RUSTUP_HOME", "/custom/rustup")
+
+	if errs := RUSTUP_PACKAGE_MANAGER.Install([]string{"stable", "component:clippy"}); errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("failed to read calls log: %v", err)
+	}
+	want := []string{"/custom/rustup toolchain install stable --profile minimal --component clippy", "/custom/rustup default stable"}
+	if calls := strings.Split(strings.TrimSpace(string(data)), "\n"); !slices.Equal(calls, want) {
+		t.Fatalf("expected calls %q, got: %q", want, calls)
+	}
+
+	versions, err := RUSTUP_PACKAGE_MANAGER.Query([]string{"stable", "nightly", "1.79.0", "component:clippy", "component:rust-src", "component:rustfmt", "target:wasm32-unknown-unknown"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for pkg, installed := range map[string]bool{"stable": true, "nightly": false, "1.79.0": true, "component:clippy": true, "component:rust-src": true, "component:rustfmt": false, "target:wasm32-unknown-unknown": true} {
+		if versions[pkg].Installed != installed {
+			t.Fatalf("expected %s installed to be %v, got: %+v", pkg, installed, versions[pkg])
+		}
+	}
+}
+
+func Test_RustupPackageManager_NotInstalled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+	t.Setenv("PATH", t.TempDir())
+	if errs := RUSTUP_PACKAGE_MANAGER.Install([]string{"stable"}); len(errs) != 1 || !strings.Contains(errs[0].Error(), "neither rustup nor rustup-init is installed") {
+		t.Fatalf("expected rustup not installed error, got: %v", errs)
+	}
+}
@@ -55,3 +55,30 @@ func TestDetectPackageManager_SuccessAndFailure(t *testing.T) {
 		}
 	})
 }
+
+func TestSelectPackageManager(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+
+	dir := t.TempDir()
+	writeExecutable(t, filepath.Join(dir, "pipx"), "#!/bin/sh\nexit 0\n")
+	withModifiedPATH(t, dir, func() {
+		if pm := SelectPackageManager(UV_PACKAGE_MANAGER, PIPX_PACKAGE_MANAGER, PYTHON_PACKAGE_MANAGER); pm != PIPX_PACKAGE_MANAGER {
+			t.Fatalf("expected pipx to be selected, got: %s", pm.Name)
+		}
+	})
+
+	writeExecutable(t, filepath.Join(dir, "uv"), "#!/bin/sh\nexit 0\n")
+	withModifiedPATH(t, dir, func() {
+		if pm := SelectPackageManager(UV_PACKAGE_MANAGER, PIPX_PACKAGE_MANAGER, PYTHON_PACKAGE_MANAGER); pm != UV_PACKAGE_MANAGER {
+			t.Fatalf("expected uv to be selected, got: %s", pm.Name)
+		}
+	})
+
+	withModifiedPATH(t, t.TempDir(), func() {
+		if pm := SelectPackageManager(UV_PACKAGE_MANAGER, PIPX_PACKAGE_MANAGER, PYTHON_PACKAGE_MANAGER); pm != PYTHON_PACKAGE_MANAGER {
+			t.Fatalf("expected the pip fallback to be selected, got: %s", pm.Name)
+		}
+	})
+}
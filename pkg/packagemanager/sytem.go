@@ -4,6 +4,7 @@ import (
 	"devbox/pkg/utils"
 	"fmt"
 	"os/exec"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -11,6 +12,9 @@ import (
 var (
 	SystemPackageManager *PackageManager = nil
 
+	// SYSTEM_TIMEOUT is the timeout of the system package managers, which install large sets of dependencies from mirrors of varying speed
+	SYSTEM_TIMEOUT = time.Hour
+
 	SYSTEM_PACKAGE_MANAGERS = []*PackageManager{
 		APT_PACKAGE_MANAGER,
 		DNF_PACKAGE_MANAGER,
@@ -28,34 +32,54 @@ var (
 
 	APT_PACKAGE_MANAGER = &PackageManager{
 		Name:             "apt",
+		Retry:            APT_RETRY_POLICY,
 		InstallCmd:       "install",
 		NoInteractiveArg: utils.StrPtr("-y"),
 		MultiInstall:     true,
 		SudoRequired:     true,
+		UpgradeCmd:       []string{"install", "--only-upgrade"},
+		QueryVersions:    queryDpkgVersions,
+		PinFormat:        "{name}={version}",
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	DNF_PACKAGE_MANAGER = &PackageManager{
 		Name:             "dnf",
+		Retry:            RPM_RETRY_POLICY,
 		InstallCmd:       "install",
 		NoInteractiveArg: utils.StrPtr("-y"),
 		MultiInstall:     true,
 		SudoRequired:     true,
+		UpgradeCmd:       []string{"upgrade"},
+		QueryVersions:    queryRpmVersions,
+		PinFormat:        "{name}-{version}",
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	MICRODNF_PACKAGE_MANAGER = &PackageManager{
 		Name:             "microdnf",
+		Retry:            RPM_RETRY_POLICY,
 		InstallCmd:       "install",
 		NoInteractiveArg: utils.StrPtr("-y"),
 		MultiInstall:     true,
 		SudoRequired:     true,
+		UpgradeCmd:       []string{"upgrade"},
+		QueryVersions:    queryRpmVersions,
+		PinFormat:        "{name}-{version}",
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	YUM_PACKAGE_MANAGER = &PackageManager{
 		Name:             "yum",
+		Retry:            RPM_RETRY_POLICY,
 		InstallCmd:       "install",
 		NoInteractiveArg: utils.StrPtr("-y"),
 		MultiInstall:     true,
 		SudoRequired:     true,
+		UpgradeCmd:       []string{"upgrade"},
+		QueryVersions:    queryRpmVersions,
+		PinFormat:        "{name}-{version}",
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	APK_PACKAGE_MANAGER = &PackageManager{
@@ -64,6 +88,10 @@ var (
 		NoInteractiveArg: nil,
 		MultiInstall:     true,
 		SudoRequired:     true,
+		UpgradeCmd:       []string{"upgrade"},
+		QueryVersions:    queryApkVersions,
+		PinFormat:        "{name}={version}",
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	BREW_PACKAGE_MANAGER = &PackageManager{
@@ -72,6 +100,8 @@ var (
 		NoInteractiveArg: nil,
 		MultiInstall:     true,
 		SudoRequired:     false,
+		UpgradeCmd:       []string{"upgrade"},
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	PACMAN_PACKAGE_MANAGER = &PackageManager{
@@ -80,14 +110,22 @@ var (
 		NoInteractiveArg: utils.StrPtr("--noconfirm"),
 		MultiInstall:     true,
 		SudoRequired:     true,
+		UpgradeCmd:       []string{"-S"},
+		QueryVersions:    queryPacmanVersions,
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	ZYPPER_PACKAGE_MANAGER = &PackageManager{
 		Name:             "zypper",
+		Retry:            RPM_RETRY_POLICY,
 		InstallCmd:       "install",
 		NoInteractiveArg: utils.StrPtr("--non-interactive"),
 		MultiInstall:     true,
 		SudoRequired:     true,
+		UpgradeCmd:       []string{"update"},
+		QueryVersions:    queryRpmVersions,
+		PinFormat:        "{name}={version}",
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	PORT_PACKAGE_MANAGER = &PackageManager{
@@ -96,6 +134,8 @@ var (
 		NoInteractiveArg: nil,
 		MultiInstall:     true,
 		SudoRequired:     true,
+		UpgradeCmd:       []string{"upgrade"},
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	NIX_ENV_PACKAGE_MANAGER = &PackageManager{
@@ -104,6 +144,8 @@ var (
 		NoInteractiveArg: nil,
 		MultiInstall:     true,
 		SudoRequired:     false,
+		UpgradeCmd:       []string{"-u"},
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	FLATPAK_PACKAGE_MANAGER = &PackageManager{
@@ -112,6 +154,8 @@ var (
 		NoInteractiveArg: utils.StrPtr("-y"),
 		MultiInstall:     false,
 		SudoRequired:     false,
+		UpgradeCmd:       []string{"update"},
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 
 	SNAP_PACKAGE_MANAGER = &PackageManager{
@@ -120,6 +164,8 @@ var (
 		NoInteractiveArg: nil,
 		MultiInstall:     false,
 		SudoRequired:     false,
+		UpgradeCmd:       []string{"refresh"},
+		Timeout:          SYSTEM_TIMEOUT,
 	}
 )
 
@@ -145,3 +191,13 @@ func DetectPackageManager() (*PackageManager, error) {
 	}
 	return nil, fmt.Errorf("no supported package manager found")
 }
+
+// GetSystemPackageManager returns the supported system package manager with the given name.
+func GetSystemPackageManager(name string) (*PackageManager, error) {
+	for _, pm := range SYSTEM_PACKAGE_MANAGERS {
+		if pm.Name == name {
+			return pm, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported system package manager: %s", name)
+}
@@ -0,0 +1,148 @@
+package packagemanager
+
+import (
+	"devbox/pkg/progress"
+	"devbox/pkg/utils"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// DEFAULT_TIMEOUT is the maximum duration of a package manager command, when the package manager has no timeout of its own
+	DEFAULT_TIMEOUT = utils.GetenvDuration("DEVBOX_TIMEOUT", 30*time.Minute)
+
+	// HEARTBEAT_INTERVAL is the interval of the progress logs of the running package manager commands
+	HEARTBEAT_INTERVAL = utils.GetenvDuration("DEVBOX_HEARTBEAT_INTERVAL", 30*time.Second)
+
+	// KILL_DELAY is the delay between the termination of a timed out command and its kill
+	KILL_DELAY = 10 * time.Second
+
+	// ErrCommandTimeout is returned when a package manager command runs longer than its timeout
+	ErrCommandTimeout = errors.New("command timed out")
+
+	// ErrSudoNotInstalled is returned when the system package managers require sudo and it is missing
+	ErrSudoNotInstalled = errors.New("sudo is not installed, it is required by the system package managers")
+
+	// ErrSudoPassword is returned when sudo requires a password and devbox cannot prompt for it
+	ErrSudoPassword = errors.New("sudo requires a password, run sudo -v before devbox or allow the package managers to run without password")
+
+	// timeoutOverrides are the timeouts set by SetTimeouts (e.g. from the project manifest), by package manager name
+	timeoutOverrides      = make(map[string]time.Duration)
+	timeoutOverridesMutex sync.RWMutex
+
+	// sudoChecked runs the sudo pre-flight check once, before the first command requiring sudo
+	sudoChecked = sync.OnceValue(checkSudo)
+)
+
+// TimeoutVariable returns the environment variable overriding the timeout of the package manager (e.g. DEVBOX_TIMEOUT_NIX_ENV)
+func (pm *PackageManager) TimeoutVariable() string {
+	return "DEVBOX_TIMEOUT_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(pm.Name))
+}
+
+// SetTimeouts overrides the timeouts of the package managers by name (e.g. {"dnf": 2h}), as declared by the project manifest.
+// The TimeoutVariable environment variables still take precedence.
+func SetTimeouts(timeouts map[string]time.Duration) {
+	timeoutOverridesMutex.Lock()
+	defer timeoutOverridesMutex.Unlock()
+	clear(timeoutOverrides)
+	for name, timeout := range timeouts {
+		if timeout > 0 {
+			timeoutOverrides[name] = timeout
+		}
+	}
+}
+
+// timeout returns the timeout of the package manager commands: the TimeoutVariable value,
+// the timeout set with SetTimeouts, the package manager timeout, or DEFAULT_TIMEOUT.
+func (pm *PackageManager) timeout() time.Duration {
+	timeoutOverridesMutex.RLock()
+	timeout, overridden := timeoutOverrides[pm.Name]
+	timeoutOverridesMutex.RUnlock()
+	if !overridden {
+		timeout = pm.Timeout
+	}
+	if timeout <= 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+	return utils.GetenvDuration(pm.TimeoutVariable(), timeout)
+}
+
+// runWatched runs the command of the packages, logging its progress every HEARTBEAT_INTERVAL.
+// The command is terminated when it exceeds the timeout of the package manager, then killed after KILL_DELAY.
+func (pm *PackageManager) runWatched(cmd *exec.Cmd, packages []string) error {
+	timeout := pm.timeout()
+	started := time.Now()
+	// The output copy stops KILL_DELAY after the exit of the command, even if a child process (e.g. a daemon) keeps its output open
+	cmd.WaitDelay = KILL_DELAY
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		timedOut.Store(true)
+		zap.L().Error("Command timed out, terminating it", zap.Strings("packages", packages), zap.String("package_manager", pm.Name),
+			zap.Duration("timeout", timeout), zap.String("timeout_variable", pm.TimeoutVariable()))
+		cmd.Process.Signal(syscall.SIGTERM)
+		time.AfterFunc(KILL_DELAY, func() { cmd.Process.Kill() })
+	})
+	defer timer.Stop()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(HEARTBEAT_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				zap.L().Info("Still running", zap.Strings("packages", packages), zap.String("package_manager", pm.Name),
+					zap.Duration("elapsed", time.Since(started).Round(time.Second)), zap.Duration("timeout", timeout))
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	if timedOut.Load() {
+		return fmt.Errorf("%w after %s, raise it with %s or timeouts.%s in the project manifest: %w", ErrCommandTimeout, timeout, pm.TimeoutVariable(), pm.Name, err)
+	}
+	return err
+}
+
+// checkSudo verifies that sudo runs the commands without a password prompt, which would hang on the uncaptured terminal.
+// When devbox runs in a terminal, the password is asked once upfront, otherwise ErrSudoPassword is returned.
+func checkSudo() error {
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return ErrSudoNotInstalled
+	}
+	if exec.Command("sudo", "-n", "true").Run() == nil {
+		return nil
+	}
+	if !utils.IsTerminal(os.Stdin) {
+		return ErrSudoPassword
+	}
+
+	zap.L().Warn("sudo requires a password to run the package managers")
+	cmd := exec.Command("sudo", "-v")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	var err error
+	progress.Suspend(func() { err = cmd.Run() })
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSudoPassword, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package packagemanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_PackageManager_Timeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		pm       *PackageManager
+		env      string
+		manifest map[string]time.Duration
+		variable string
+		want     time.Duration
+	}{
+		{name: "default", pm: &PackageManager{Name: "dnf"}, variable: "DEVBOX_TIMEOUT_DNF", want: DEFAULT_TIMEOUT},
+		{name: "package manager timeout", pm: &PackageManager{Name: "cargo", Timeout: time.Hour}, variable: "DEVBOX_TIMEOUT_CARGO", want: time.Hour},
+		{name: "overridden", pm: &PackageManager{Name: "nix-env", Timeout: time.Hour}, env: "5m", variable: "DEVBOX_TIMEOUT_NIX_ENV", want: 5 * time.Minute},
+		{name: "invalid override", pm: &PackageManager{Name: "go"}, env: "forever", variable: "DEVBOX_TIMEOUT_GO", want: DEFAULT_TIMEOUT},
+		{name: "system package manager", pm: DNF_PACKAGE_MANAGER, variable: "DEVBOX_TIMEOUT_DNF", want: SYSTEM_TIMEOUT},
+		{name: "go", pm: GOLANG_PACKAGE_MANAGER, variable: "DEVBOX_TIMEOUT_GO", want: 45 * time.Minute},
+		{name: "pip", pm: PYTHON_PACKAGE_MANAGER, variable: "DEVBOX_TIMEOUT_PIP", want: PIP_TIMEOUT},
+		{name: "manifest override", pm: DNF_PACKAGE_MANAGER, manifest: map[string]time.Duration{"dnf": 2 * time.Hour}, variable: "DEVBOX_TIMEOUT_DNF", want: 2 * time.Hour},
+		{name: "environment over manifest", pm: DNF_PACKAGE_MANAGER, manifest: map[string]time.Duration{"dnf": 2 * time.Hour}, env: "3h", variable: "DEVBOX_TIMEOUT_DNF", want: 3 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if variable := tt.pm.TimeoutVariable(); variable != tt.variable {
+				t.Fatalf("expected variable %s, got: %s", tt.variable, variable)
+			}
+			t.Setenv(tt.variable, tt.env)
+			SetTimeouts(tt.manifest)
+			t.Cleanup(func() { SetTimeouts(nil) })
+			if got := tt.pm.timeout(); got != tt.want {
+				t.Fatalf("expected %s, got: %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_Install_TimeoutAndHeartbeat(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+	dir := t.TempDir()
+	writeExecutable(t, filepath.Join(dir, "slowpm"), "#!/bin/sh\nexec sleep 5\n")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	interval, killDelay := HEARTBEAT_INTERVAL, KILL_DELAY
+	t.Cleanup(func() { HEARTBEAT_INTERVAL, KILL_DELAY = interval, killDelay })
+	HEARTBEAT_INTERVAL, KILL_DELAY = 50*time.Millisecond, time.Second
+	logs := observeLogs(t)
+
+	pm := &PackageManager{Name: "slowpm", InstallCmd: "install", Timeout: 300 * time.Millisecond}
+	started := time.Now()
+	errs := pm.Install([]string{"tool"})
+	if len(errs) != 1 || !errors.Is(errs[0], ErrCommandTimeout) || !strings.Contains(errs[0].Error(), "DEVBOX_TIMEOUT_SLOWPM") {
+		t.Fatalf("expected a timeout error, got: %v", errs)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("expected the command to be terminated after its timeout, ran for %s", elapsed)
+	}
+	if heartbeats := logs.FilterMessage("Still running").Len(); heartbeats == 0 {
+		t.Fatalf("expected heartbeat logs while the command was running")
+	}
+}
+
+func Test_CheckSudo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{name: "passwordless", script: "#!/bin/sh\nexit 0\n"},
+		{name: "password required", script: "#!/bin/sh\necho 'sudo: a password is required' >&2\nexit 1\n", wantErr: ErrSudoPassword.Error()},
+		{name: "not installed", wantErr: "sudo is not installed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.script != "" {
+				writeExecutable(t, filepath.Join(dir, "sudo"), tt.script)
+			}
+			t.Setenv("PATH", dir)
+			// The test standard input is not a terminal, the password cannot be asked
+			err := checkSudo()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,15 +1,33 @@
 package packagemanager
 
 import (
-	"bytes"
 	"devbox/pkg/utils"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
 	"os/exec"
+	"slices"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
 
-var ()
+var (
+	// ErrUnsupportedPackageManager is returned by the operations of a nil package manager, e.g. an unsupported system package manager
+	ErrUnsupportedPackageManager = errors.New("package manager is not specified or unsupported")
+)
+
+// PackageError is the failure of a package manager operation, with the packages it was run on
+type PackageError struct {
+	PackageManager string
+	Packages       []string
+	Err            error
+}
+
+func (e *PackageError) Error() string { return e.Err.Error() }
+func (e *PackageError) Unwrap() error { return e.Err }
 
 type PackageManager struct {
 	Name             string  `yaml:"name"`
@@ -17,69 +35,252 @@ type PackageManager struct {
 	NoInteractiveArg *string `yaml:"no_interactive_arg,omitempty"`
 	MultiInstall     bool    `yaml:"multi_install,omitempty"`
 	SudoRequired     bool    `yaml:"sudo_required,omitempty"`
+	// UpgradeCmd are the arguments upgrading packages to their latest version, upgrades are not supported when empty
+	UpgradeCmd []string `yaml:"upgrade_cmd,omitempty"`
+	// LatestVersionSuffix replaces the version suffix of the packages when upgrading them (e.g. "@latest" for go)
+	LatestVersionSuffix string `yaml:"latest_version_suffix,omitempty"`
+	// PinFormat is the template of a package pinned to a version, with the {name} and {version} placeholders
+	// (e.g. "{name}=={version}" for pip), pinning versions is not supported when empty
+	PinFormat string `yaml:"pin_format,omitempty"`
+	// QueryVersions returns the installed versions of the packages, querying versions is not supported when nil
+	QueryVersions VersionQuerier `yaml:"-"`
+	// InstallPackages installs the packages in place of the install command, for the package managers not backed by a command
+	InstallPackages PackagesInstaller `yaml:"-"`
+	// InstalledBinaries returns the paths of the binaries installed by the packages, to export them to the host
+	InstalledBinaries func(pm *PackageManager, packages []string) []string `yaml:"-"`
+	// Environment are the default values of the environment variables of the package manager commands, used when they are not set
+	// (e.g. the npm_config_prefix of the global node packages)
+	Environment map[string]string `yaml:"environment,omitempty"`
+	// Timeout is the maximum duration of the package manager commands, DEFAULT_TIMEOUT when zero.
+	// It is overridden by the project manifest timeouts, then by the TimeoutVariable environment variable (e.g. DEVBOX_TIMEOUT_CARGO=1h).
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Retry is the retry policy of the failed commands, DEFAULT_RETRY_POLICY when nil
+	Retry *RetryPolicy `yaml:"-"`
+	// Candidates are the package managers selected by Resolve, in preference order, when the packages are installed.
+	// A package manager with candidates runs no command itself (e.g. the python CLI tools installed with uv, pipx or pip).
+	Candidates []*PackageManager `yaml:"-"`
+	// Provides are the binaries of other package managers installed by the packages (e.g. cargo for rustup),
+	// the packages of these package managers are installed afterwards
+	Provides []string `yaml:"provides,omitempty"`
+}
+
+// PackagesInstaller installs the packages, each package can be pinned with the PinFormat of the package manager
+type PackagesInstaller func(pm *PackageManager, packages []string) []error
+
+// packagesOperation describes an operation run on packages, with the verb forms used in logs and errors
+type packagesOperation struct {
+	verb      string
+	progress  string
+	done      string
+	buildArgs func(packages []string) []string
+	// single forces one command per package, even if the package manager supports multiple packages
+	single bool
 }
 
 func (pm *PackageManager) Install(packages []string) []error {
 	if pm == nil {
-		return []error{fmt.Errorf("package manager is not specified or unsupported")}
+		return []error{ErrUnsupportedPackageManager}
 	}
+	if pm.InstallPackages != nil {
+		return pm.InstallPackages(pm, packages)
+	}
+	return pm.run(&packagesOperation{verb: "install", progress: "Installing", done: "installed", buildArgs: pm.InstallArgs}, packages)
+}
 
-	// Multi-install logic
-	if pm.MultiInstall {
-		var cmd *exec.Cmd
-		if pm.SudoRequired {
-			cmd = exec.Command("sudo", pm.Name, pm.InstallCmd)
-		} else {
-			cmd = exec.Command(pm.Name, pm.InstallCmd)
-		}
-		zap.L().Info("Installing packages", zap.Strings("packages", packages), zap.String("package_manager", pm.Name))
-		cmd.Args = append(cmd.Args, packages...)
+// Upgrade upgrades the packages to their latest version.
+func (pm *PackageManager) Upgrade(packages []string) []error {
+	if pm == nil {
+		return []error{ErrUnsupportedPackageManager}
+	}
+	if len(pm.UpgradeCmd) == 0 {
+		return []error{fmt.Errorf("upgrading packages is not supported by %s", pm.Name)}
+	}
+	return pm.run(&packagesOperation{verb: "upgrade", progress: "Upgrading", done: "upgraded", buildArgs: pm.UpgradeArgs}, packages)
+}
 
-		if pm.NoInteractiveArg != nil {
-			cmd.Args = append(cmd.Args, *pm.NoInteractiveArg)
-		}
+// InstallPinned installs the packages at the given versions, the packages without a version are installed unpinned.
+// Pinned packages expanding to several arguments (e.g. "ripgrep --version 14.1.0" for cargo) are installed one by one.
+func (pm *PackageManager) InstallPinned(packages []string, versions map[string]string) []error {
+	if pm == nil {
+		return []error{ErrUnsupportedPackageManager}
+	}
+	if pm.PinFormat == "" {
+		return []error{fmt.Errorf("pinning versions is not supported by %s", pm.Name)}
+	}
 
-		var stderr bytes.Buffer
-		cmd.Stderr = &stderr
+	op := &packagesOperation{verb: "install", progress: "Installing", done: "installed"}
+	pinned := make([]string, len(packages))
+	for i, pkg := range packages {
+		pinned[i] = pm.PinnedPackage(pkg, versions[pkg])
+		if strings.Contains(pinned[i], " ") {
+			op.single = true
+		}
+	}
+	if pm.InstallPackages != nil {
+		return pm.InstallPackages(pm, pinned)
+	}
+	op.buildArgs = func(packages []string) []string {
+		fields := make([]string, 0, len(packages))
+		for _, pkg := range packages {
+			fields = append(fields, strings.Fields(pkg)...)
+		}
+		return pm.InstallArgs(fields)
+	}
+	return pm.run(op, pinned)
+}
 
-		zap.L().Debug("Running command", zap.String("command", cmd.String()))
-		err := cmd.Run()
+// run runs the operation on the packages, in a single command if the package manager supports it
+func (pm *PackageManager) run(op *packagesOperation, packages []string) []error {
+	// Multi-install logic
+	if pm.MultiInstall && !op.single {
+		zap.L().Info(op.progress+" packages", zap.Strings("packages", packages), zap.String("package_manager", pm.Name))
+		output, attempts, err := pm.runWithRetry(op.buildArgs(packages), packages)
 		if err != nil {
-			zap.L().Error("Error installing packages", zap.Strings("packages", packages), zap.String("package_manager", pm.Name), zap.Error(err))
-			return []error{fmt.Errorf("failed to install packages using %s: %w, stderr: %s", pm.Name, err, stderr.String())}
+			zap.L().Error("Error "+strings.ToLower(op.progress)+" packages", zap.Strings("packages", packages), zap.String("package_manager", pm.Name), zap.Error(err))
+			return []error{&PackageError{PackageManager: pm.Name, Packages: packages,
+				Err: fmt.Errorf("failed to %s packages using %s%s: %w%s", op.verb, pm.Name, attemptsSuffix(attempts), err, outputContext(output))}}
 		}
-		zap.L().Info("Successfully installed packages", zap.Strings("packages", packages), zap.String("package_manager", pm.Name))
+		zap.L().Info("Successfully "+op.done+" packages", zap.Strings("packages", packages), zap.String("package_manager", pm.Name))
 		return nil
 	}
 
 	// Single-install logic
 	var errorChan = make(chan error, len(packages))
 	for _, pkg := range packages {
-		zap.L().Info("Installing package", zap.String("package", pkg), zap.String("package_manager", pm.Name))
-		var cmd *exec.Cmd
-		if pm.SudoRequired {
-			cmd = exec.Command("sudo", pm.Name, pm.InstallCmd)
+		zap.L().Info(op.progress+" package", zap.String("package", pkg), zap.String("package_manager", pm.Name))
+		if output, attempts, err := pm.runWithRetry(op.buildArgs([]string{pkg}), []string{pkg}); err != nil {
+			zap.L().Error("Error "+strings.ToLower(op.progress)+" package", zap.String("package", pkg), zap.String("package_manager", pm.Name), zap.Error(err))
+			errorChan <- &PackageError{PackageManager: pm.Name, Packages: []string{pkg},
+				Err: fmt.Errorf("failed to %s package %s using %s%s: %w%s", op.verb, pkg, pm.Name, attemptsSuffix(attempts), err, outputContext(output))}
 		} else {
-			cmd = exec.Command(pm.Name, pm.InstallCmd)
+			zap.L().Info("Successfully "+op.done+" package", zap.String("package", pkg), zap.String("package_manager", pm.Name))
 		}
-		cmd.Args = append(cmd.Args, pkg)
+	}
+
+	close(errorChan)
+	return utils.MergeErrors(errorChan)
+}
 
-		if pm.NoInteractiveArg != nil {
-			cmd.Args = append(cmd.Args, *pm.NoInteractiveArg)
+// runWithRetry runs the command of the packages until it succeeds, fails permanently or runs out of attempts,
+// following the retry policy of the package manager. It returns the output of the last attempt and the number of attempts.
+// The commands which needed retries are recorded for the final report.
+func (pm *PackageManager) runWithRetry(args []string, packages []string) (*commandOutput, int, error) {
+	if pm.SudoRequired {
+		if err := sudoChecked(); err != nil {
+			return nil, 0, err
+		}
+	}
+	policy := pm.retryPolicy()
+	for attempt := 1; ; attempt++ {
+		cmd := pm.command(args)
+		zap.L().Debug("Running command", zap.String("command", cmd.String()), zap.Int("attempt", attempt))
+		output, err := pm.runStreamed(cmd, packages)
+		if err == nil || attempt >= policy.Attempts || !policy.IsTransient(err, output.StderrString()) {
+			if attempt > 1 {
+				recordRetry(&Retry{PackageManager: pm.Name, Packages: packages, Attempts: attempt, Succeeded: err == nil})
+			}
+			return output, attempt, err
 		}
+		delay := policy.Delay(attempt)
+		zap.L().Warn("Transient failure, retrying", zap.Strings("packages", packages), zap.String("package_manager", pm.Name),
+			zap.Int("attempt", attempt), zap.Duration("delay", delay), zap.Error(err))
+		RETRY_SLEEP(delay)
+	}
+}
 
-		var stderr bytes.Buffer
-		cmd.Stderr = &stderr
+// outputContext returns the last output lines of a failed command, on their own lines after the error
+func outputContext(output *commandOutput) string {
+	if output == nil {
+		return ""
+	}
+	if tail := output.Tail(); tail != "" {
+		return ", last output lines:\n" + tail
+	}
+	return ""
+}
 
-		zap.L().Debug("Running command", zap.String("command", cmd.String()))
-		if err := cmd.Run(); err != nil {
-			zap.L().Error("Error installing package", zap.String("package", pkg), zap.String("package_manager", pm.Name), zap.Error(err))
-			errorChan <- fmt.Errorf("failed to install package %s using %s: %w, stderr: %s", pkg, pm.Name, err, stderr.String())
-		} else {
-			zap.L().Info("Successfully installed package", zap.String("package", pkg), zap.String("package_manager", pm.Name))
+// attemptsSuffix returns the attempts count of the errors of the retried commands
+func attemptsSuffix(attempts int) string {
+	if attempts <= 1 {
+		return ""
+	}
+	return fmt.Sprintf(" after %d attempts", attempts)
+}
+
+// InstallArgs returns the command line used to install the given packages in a single invocation,
+// without any privilege escalation (e.g. "dnf install go make -y"). The install command can hold several words (e.g. "tool install").
+func (pm *PackageManager) InstallArgs(packages []string) []string {
+	args := append([]string{pm.Name}, strings.Fields(pm.InstallCmd)...)
+	args = append(args, packages...)
+	if pm.NoInteractiveArg != nil {
+		args = append(args, *pm.NoInteractiveArg)
+	}
+	return args
+}
+
+// UpgradeArgs returns the command line used to upgrade the given packages in a single invocation,
+// without any privilege escalation (e.g. "cargo install --force ripgrep").
+func (pm *PackageManager) UpgradeArgs(packages []string) []string {
+	args := append([]string{pm.Name}, pm.UpgradeCmd...)
+	for _, pkg := range packages {
+		args = append(args, pm.LatestVersion(pkg))
+	}
+	if pm.NoInteractiveArg != nil {
+		args = append(args, *pm.NoInteractiveArg)
+	}
+	return args
+}
+
+// LatestVersion returns the package reference resolving to its latest version,
+// replacing its version suffix when the package manager requires one (e.g. "gopls@v0.16.0" becomes "gopls@latest").
+func (pm *PackageManager) LatestVersion(pkg string) string {
+	if pm.LatestVersionSuffix == "" {
+		return pkg
+	}
+	separator := pm.LatestVersionSuffix[:1]
+	if index := strings.LastIndex(pkg, separator); index > 0 {
+		pkg = pkg[:index]
+	}
+	return pkg + pm.LatestVersionSuffix
+}
+
+// PinnedPackage returns the package reference pinned to the version, replacing its version suffix when the
+// package manager requires one (e.g. "gopls@latest" pinned to "v0.16.0" becomes "gopls@v0.16.0").
+// The package is returned unchanged when the version is empty.
+func (pm *PackageManager) PinnedPackage(pkg string, version string) string {
+	if version == "" || pm.PinFormat == "" {
+		return pkg
+	}
+	if pm.LatestVersionSuffix != "" {
+		if index := strings.LastIndex(pkg, pm.LatestVersionSuffix[:1]); index > 0 {
+			pkg = pkg[:index]
 		}
 	}
+	return strings.NewReplacer("{name}", pkg, "{version}", version).Replace(pm.PinFormat)
+}
 
-	close(errorChan)
-	return utils.MergeErrors(errorChan)
+// command builds the command running args, prefixed with sudo if the package manager requires it.
+// sudo never prompts for a password, which would hang on the uncaptured terminal.
+func (pm *PackageManager) command(args []string) *exec.Cmd {
+	if pm.SudoRequired {
+		return exec.Command("sudo", append([]string{"-n"}, args...)...)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = pm.Environ()
+	return cmd
+}
+
+// Environ returns the environment of the package manager commands: the current environment,
+// completed with the default values of the package manager environment variables. It returns nil when there is no default value.
+func (pm *PackageManager) Environ() []string {
+	if len(pm.Environment) == 0 {
+		return nil
+	}
+	environ := os.Environ()
+	for _, key := range slices.Sorted(maps.Keys(pm.Environment)) {
+		if _, exists := os.LookupEnv(key); !exists {
+			environ = append(environ, key+"="+pm.Environment[key])
+		}
+	}
+	return environ
 }
@@ -0,0 +1,160 @@
+package packagemanager
+
+import (
+	"devbox/pkg/utils"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_UpgradeArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		pm       *PackageManager
+		packages []string
+		want     []string
+	}{
+		{"dnf", DNF_PACKAGE_MANAGER, []string{"go", "make"}, []string{"dnf", "upgrade", "go", "make", "-y"}},
+		{"apt", APT_PACKAGE_MANAGER, []string{"make"}, []string{"apt", "install", "--only-upgrade", "make", "-y"}},
+		{"pip", PYTHON_PACKAGE_MANAGER, []string{"black", "ruff"}, []string{"pip", "install", "-U", "black", "ruff"}},
+		{"pipx", PIPX_PACKAGE_MANAGER, []string{"black", "ruff"}, []string{"pipx", "upgrade", "black", "ruff"}},
+		{"uv", UV_PACKAGE_MANAGER, []string{"black"}, []string{"uv", "tool", "upgrade", "black"}},
+		{"cargo", CARGO_PACKAGE_MANAGER, []string{"ripgrep"}, []string{"cargo", "install", "--force", "ripgrep"}},
+		{"krew", KREW_PACKAGE_MANAGER, []string{"ctx"}, []string{"krew", "upgrade", "ctx"}},
+		{"npm", NODE_PACKAGE_MANAGER, []string{"eslint"}, []string{"npm", "update", "-g", "eslint"}},
+		{"pnpm", PNPM_PACKAGE_MANAGER, []string{"eslint", "prettier"}, []string{"pnpm", "update", "-g", "eslint", "prettier"}},
+		{"bun", BUN_PACKAGE_MANAGER, []string{"eslint@9.6.0", "@types/node"}, []string{"bun", "add", "-g", "eslint@latest", "@types/node@latest"}},
+		{"go latest", GOLANG_PACKAGE_MANAGER, []string{"golang.org/x/tools/gopls@latest"}, []string{"go", "install", "golang.org/x/tools/gopls@latest"}},
+		{"go pinned", GOLANG_PACKAGE_MANAGER, []string{"github.com/go-delve/delve/cmd/dlv@v1.22.0"}, []string{"go", "install", "github.com/go-delve/delve/cmd/dlv@latest"}},
+		{"go without version", GOLANG_PACKAGE_MANAGER, []string{"github.com/josharian/impl"}, []string{"go", "install", "github.com/josharian/impl@latest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pm.UpgradeArgs(tt.packages); !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_InstallArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		pm       *PackageManager
+		packages []string
+		want     []string
+	}{
+		{"dnf", DNF_PACKAGE_MANAGER, []string{"go", "make"}, []string{"dnf", "install", "go", "make", "-y"}},
+		{"pipx", PIPX_PACKAGE_MANAGER, []string{"black", "ruff"}, []string{"pipx", "install", "black", "ruff"}},
+		{"uv", UV_PACKAGE_MANAGER, []string{"black==24.4.2"}, []string{"uv", "tool", "install", "black==24.4.2"}},
+		{"npm", NODE_PACKAGE_MANAGER, []string{"eslint", "@types/node@20"}, []string{"npm", "install", "-g", "eslint", "@types/node@20"}},
+		{"pnpm", PNPM_PACKAGE_MANAGER, []string{"eslint"}, []string{"pnpm", "add", "-g", "eslint"}},
+		{"bun", BUN_PACKAGE_MANAGER, []string{"eslint", "prettier"}, []string{"bun", "add", "-g", "eslint", "prettier"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pm.InstallArgs(tt.packages); !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_Environ(t *testing.T) {
+	t.Setenv("npm_config_prefix", "/custom/npm")
+	os.Unsetenv("PNPM_HOME")
+	pm := &PackageManager{Name: "fakepm", Environment: map[string]string{"npm_config_prefix": "/default/npm", "PNPM_HOME": "/default/pnpm"}}
+	environ := pm.Environ()
+	if !slices.Contains(environ, "npm_config_prefix=/custom/npm") || slices.Contains(environ, "npm_config_prefix=/default/npm") {
+		t.Fatalf("expected the environment value to be kept, got: %q", environ)
+	}
+	if !slices.Contains(environ, "PNPM_HOME=/default/pnpm") {
+		t.Fatalf("expected the default value of the unset variable, got: %q", environ)
+	}
+	if environ := (&PackageManager{Name: "pip"}).Environ(); environ != nil {
+		t.Fatalf("expected the inherited environment without default values, got: %q", environ)
+	}
+}
+
+func Test_Upgrade_NotSupported(t *testing.T) {
+	pm := &PackageManager{Name: "custom", InstallCmd: "install", NoInteractiveArg: utils.StrPtr("-y")}
+	errs := pm.Upgrade([]string{"pkg"})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "not supported by custom") {
+		t.Fatalf("expected not supported error, got: %v", errs)
+	}
+
+	var nilPM *PackageManager
+	if errs := nilPM.Upgrade([]string{"pkg"}); len(errs) != 1 {
+		t.Fatalf("expected an error for a nil package manager, got: %v", errs)
+	}
+}
+
+func Test_PinnedPackage(t *testing.T) {
+	tests := []struct {
+		name    string
+		pm      *PackageManager
+		pkg     string
+		version string
+		want    string
+	}{
+		{"pip", PYTHON_PACKAGE_MANAGER, "black", "24.4.2", "black==24.4.2"},
+		{"dnf", DNF_PACKAGE_MANAGER, "python3-devel", "3.12.3-2.fc40", "python3-devel-3.12.3-2.fc40"},
+		{"apt", APT_PACKAGE_MANAGER, "make", "4.3-4.1build1", "make=4.3-4.1build1"},
+		{"cargo", CARGO_PACKAGE_MANAGER, "ripgrep", "14.1.0", "ripgrep --version 14.1.0"},
+		{"npm scoped", NODE_PACKAGE_MANAGER, "@angular/cli", "17.3.0", "@angular/cli@17.3.0"},
+		{"bun scoped", BUN_PACKAGE_MANAGER, "@angular/cli", "17.3.0", "@angular/cli@17.3.0"},
+		{"go latest", GOLANG_PACKAGE_MANAGER, "golang.org/x/tools/gopls@latest", "v0.16.0", "golang.org/x/tools/gopls@v0.16.0"},
+		{"go without version", GOLANG_PACKAGE_MANAGER, "github.com/josharian/impl", "v1.4.0", "github.com/josharian/impl@v1.4.0"},
+		{"empty version", PYTHON_PACKAGE_MANAGER, "black", "", "black"},
+		{"not supported", BREW_PACKAGE_MANAGER, "make", "4.4.1", "make"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pm.PinnedPackage(tt.pkg, tt.version); got != tt.want {
+				t.Fatalf("expected %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_InstallPinned(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip PATH/executable tests on Windows")
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "calls.log")
+	writeExecutable(t, filepath.Join(dir, "fakepm"), "#!/bin/sh\necho \"$@\" >> \""+logFile+"\"\n")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		name      string
+		pinFormat string
+		want      []string
+	}{
+		{"single command", "{name}=={version}", []string{"install black==24.4.2 ruff"}},
+		{"one command per expanded package", "{name} --version {version}", []string{"install black --version 24.4.2", "install ruff"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { os.Remove(logFile) })
+			pm := &PackageManager{Name: "fakepm", InstallCmd: "install", MultiInstall: true, PinFormat: tt.pinFormat}
+			if errs := pm.InstallPinned([]string{"black", "ruff"}, map[string]string{"black": "24.4.2"}); errs != nil {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			data, err := os.ReadFile(logFile)
+			if err != nil {
+				t.Fatalf("failed to read calls log: %v", err)
+			}
+			if calls := strings.Split(strings.TrimSpace(string(data)), "\n"); !slices.Equal(calls, tt.want) {
+				t.Fatalf("expected calls %q, got: %q", tt.want, calls)
+			}
+		})
+	}
+
+	if errs := BREW_PACKAGE_MANAGER.InstallPinned([]string{"make"}, nil); len(errs) != 1 || !strings.Contains(errs[0].Error(), "not supported by brew") {
+		t.Fatalf("expected not supported error, got: %v", errs)
+	}
+}
@@ -0,0 +1,326 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// SPINNER_FRAMES are the frames of the spinner of the running tasks
+	SPINNER_FRAMES = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+	// REFRESH_INTERVAL is the interval between two renderings of the tasks rows
+	REFRESH_INTERVAL = 100 * time.Millisecond
+
+	// taskMutex guards the status of the tasks started without display
+	taskMutex sync.Mutex
+
+	// current is the enabled display, the tasks are not displayed when it is nil
+	current struct {
+		sync.Mutex
+		display *Display
+		// tasks are the tasks started since the start of devbox, displayed or not, reported by Tasks
+		tasks []*Task
+	}
+)
+
+// Status is the status of a task
+type Status int
+
+const (
+	Running Status = iota
+	Succeeded
+	Failed
+)
+
+// String returns the status as displayed in the task row
+func (s Status) String() string {
+	switch s {
+	case Succeeded:
+		return "done"
+	case Failed:
+		return "failed"
+	default:
+		return "running"
+	}
+}
+
+// MarshalText returns the status as reported in the JSON output
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Task is a unit of work displayed as a row, e.g. the installation of the packages of a package manager
+type Task struct {
+	Name string
+	// PackageManager, Packages and Toolchains are what the task works on, reported with its result
+	PackageManager string
+	Packages       []string
+	Toolchains     []string
+	started        time.Time
+	ended          time.Time
+	status         Status
+	errors         []string
+	display        *Display
+}
+
+// TaskReport is the result of a task
+type TaskReport struct {
+	Name           string   `json:"name"`
+	PackageManager string   `json:"package_manager,omitempty"`
+	Packages       []string `json:"packages,omitempty"`
+	Toolchains     []string `json:"toolchains,omitempty"`
+	Status         Status   `json:"status"`
+	// Duration is the duration of the task in seconds
+	Duration float64  `json:"duration"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Display renders a live row per task on a terminal: a spinner, the task name, its elapsed time and its status.
+// It is also the zap WriteSyncer of the console logs, which are written above the rows.
+type Display struct {
+	mutex sync.Mutex
+	out   io.Writer
+	tasks []*Task
+	// lines is the number of rows currently drawn, erased before the next rendering
+	lines   int
+	frame   int
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// New returns a display rendering the tasks on the terminal out
+func New(out io.Writer) *Display {
+	return &Display{out: out}
+}
+
+// Enable makes the display render the tasks started from now on, until Stop is called
+func Enable(display *Display) {
+	current.Lock()
+	defer current.Unlock()
+	current.display = display
+}
+
+// Start starts the task, displayed when a display is enabled, and returns it
+func Start(task *Task) *Task {
+	current.Lock()
+	defer current.Unlock()
+	task.started, task.display = time.Now(), current.display
+	current.tasks = append(current.tasks, task)
+	if task.display != nil {
+		task.display.add(task)
+	}
+	return task
+}
+
+// Tasks returns the reports of the tasks started since the start of devbox, in their start order
+func Tasks() []*TaskReport {
+	current.Lock()
+	tasks := slices.Clone(current.tasks)
+	current.Unlock()
+	reports := make([]*TaskReport, len(tasks))
+	for i, task := range tasks {
+		reports[i] = task.report()
+	}
+	return reports
+}
+
+// Stop renders the final status of the tasks and disables the display, the logs are then written as is
+func Stop() {
+	current.Lock()
+	display := current.display
+	current.display = nil
+	current.Unlock()
+	if display != nil {
+		display.close()
+	}
+}
+
+// Suspend erases the rows and stops their rendering while fn runs, e.g. while a password is asked on the terminal.
+// The logs written meanwhile wait for the end of fn.
+func Suspend(fn func()) {
+	current.Lock()
+	display := current.display
+	current.Unlock()
+	if display == nil {
+		fn()
+		return
+	}
+	display.mutex.Lock()
+	defer display.mutex.Unlock()
+	display.erase()
+	fn()
+	display.draw()
+}
+
+// Done ends the task, failed when there are errors. It does nothing on a nil task.
+func (t *Task) Done(errs []error) {
+	if t == nil {
+		return
+	}
+	status := Succeeded
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		status = Failed
+	}
+	t.lock()
+	defer t.unlock()
+	t.status, t.ended, t.errors = status, time.Now(), messages
+	if t.display != nil {
+		t.display.render()
+	}
+}
+
+// Status returns the status of the task
+func (t *Task) Status() Status {
+	t.lock()
+	defer t.unlock()
+	return t.status
+}
+
+// report returns the report of the task, the duration of a running task is its elapsed time
+func (t *Task) report() *TaskReport {
+	t.lock()
+	defer t.unlock()
+	ended := t.ended
+	if t.status == Running {
+		ended = time.Now()
+	}
+	return &TaskReport{
+		Name:           t.Name,
+		PackageManager: t.PackageManager,
+		Packages:       t.Packages,
+		Toolchains:     t.Toolchains,
+		Status:         t.status,
+		Duration:       ended.Sub(t.started).Seconds(),
+		Errors:         t.errors,
+	}
+}
+
+// lock and unlock guard the status of the task, with the mutex of its display when it is displayed
+func (t *Task) lock() {
+	if t.display != nil {
+		t.display.mutex.Lock()
+	} else {
+		taskMutex.Lock()
+	}
+}
+
+func (t *Task) unlock() {
+	if t.display != nil {
+		t.display.mutex.Unlock()
+	} else {
+		taskMutex.Unlock()
+	}
+}
+
+// add adds the task row, and starts the rendering loop with the first task
+func (d *Display) add(task *Task) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.tasks = append(d.tasks, task)
+	if d.stop == nil {
+		d.stop = make(chan struct{})
+		d.stopped.Add(1)
+		go d.loop()
+	}
+	d.render()
+}
+
+// loop renders the rows every REFRESH_INTERVAL, to animate the spinners and the elapsed times
+func (d *Display) loop() {
+	defer d.stopped.Done()
+	ticker := time.NewTicker(REFRESH_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.mutex.Lock()
+			d.frame++
+			d.render()
+			d.mutex.Unlock()
+		}
+	}
+}
+
+// close stops the rendering loop and renders the final rows, which are then kept above the next outputs
+func (d *Display) close() {
+	d.mutex.Lock()
+	stop := d.stop
+	d.mutex.Unlock()
+	if stop != nil {
+		close(stop)
+		d.stopped.Wait()
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.render()
+	d.lines = 0
+	d.tasks = nil
+}
+
+// Write writes the log entry above the rows, it is safe to call concurrently
+func (d *Display) Write(p []byte) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.erase()
+	n, err := d.out.Write(p)
+	d.draw()
+	return n, err
+}
+
+// Sync implements zapcore.WriteSyncer, the rows and the logs are written unbuffered
+func (d *Display) Sync() error {
+	return nil
+}
+
+// render draws the rows again, the mutex must be held
+func (d *Display) render() {
+	d.erase()
+	d.draw()
+}
+
+// erase moves the cursor back to the first row and clears the rows
+func (d *Display) erase() {
+	if d.lines > 0 {
+		fmt.Fprintf(d.out, "\033[%dA\033[J", d.lines)
+		d.lines = 0
+	}
+}
+
+// draw writes a row per task below the cursor
+func (d *Display) draw() {
+	width := 0
+	for _, task := range d.tasks {
+		width = max(width, len(task.Name))
+	}
+	var rows strings.Builder
+	for _, task := range d.tasks {
+		rows.WriteString(task.row(width, d.frame) + "\n")
+	}
+	fmt.Fprint(d.out, rows.String())
+	d.lines = len(d.tasks)
+}
+
+// row returns the row of the task, its name padded to width
+func (t *Task) row(width int, frame int) string {
+	symbol, elapsed := SPINNER_FRAMES[frame%len(SPINNER_FRAMES)], time.Since(t.started)
+	switch t.status {
+	case Succeeded:
+		symbol, elapsed = "✓", t.ended.Sub(t.started)
+	case Failed:
+		symbol, elapsed = "✗", t.ended.Sub(t.started)
+	}
+	return fmt.Sprintf("%s %-*s  %6s  %s", symbol, width, t.Name, elapsed.Round(100*time.Millisecond), t.status)
+}
@@ -0,0 +1,101 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a buffer written by the rendering loop and read by the test
+type lockedBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func Test_Display(t *testing.T) {
+	var out lockedBuffer
+	display := New(&out)
+	Enable(display)
+	t.Cleanup(Stop)
+
+	dnf := Start(&Task{Name: "dnf: 3 packages"})
+	export := Start(&Task{Name: "export: 1 binary"})
+	display.Write([]byte("WARN slow mirror\n"))
+	dnf.Done(nil)
+	export.Done([]error{errors.New("export failed")})
+	Stop()
+
+	output := out.String()
+	logIndex := strings.Index(output, "WARN slow mirror\n")
+	if logIndex < 0 || !strings.Contains(output[:logIndex], "\033[2A\033[J") {
+		t.Fatalf("expected the rows to be erased before the log, got: %q", output)
+	}
+	final := output[strings.LastIndex(output, "\033[J")+len("\033[J"):]
+	rows := strings.Split(strings.TrimSuffix(final, "\n"), "\n")
+	if len(rows) != 2 || !strings.HasPrefix(rows[0], "✓ dnf: 3 packages    ") || !strings.HasSuffix(rows[0], "done") ||
+		!strings.HasPrefix(rows[1], "✗ export: 1 binary   ") || !strings.HasSuffix(rows[1], "failed") {
+		t.Fatalf("expected the final rows of the tasks, got: %q", rows)
+	}
+
+	// The stopped display writes the logs as is
+	display.Write([]byte("INFO done\n"))
+	if !strings.HasSuffix(out.String(), final+"INFO done\n") {
+		t.Fatalf("expected the log to be written below the final rows, got: %q", out.String())
+	}
+	if task := Start(&Task{Name: "not displayed"}); task.display != nil {
+		t.Fatalf("expected the tasks not to be displayed once stopped")
+	}
+}
+
+func Test_Display_Spinner(t *testing.T) {
+	interval := REFRESH_INTERVAL
+	t.Cleanup(func() { REFRESH_INTERVAL = interval })
+	REFRESH_INTERVAL = 10 * time.Millisecond
+
+	var out lockedBuffer
+	Enable(New(&out))
+	t.Cleanup(Stop)
+	task := Start(&Task{Name: "cargo: 1 package"})
+	time.Sleep(100 * time.Millisecond)
+	if task.Status() != Running || !strings.Contains(out.String(), SPINNER_FRAMES[1]+" cargo: 1 package") {
+		t.Fatalf("expected the spinner to turn while the task is running, got: %q", out.String())
+	}
+	task.Done(nil)
+}
+
+func Test_Task_WithoutDisplay(t *testing.T) {
+	task := Start(&Task{Name: "npm: 2 packages", PackageManager: "npm", Packages: []string{"eslint", "prettier"}, Toolchains: []string{"node"}})
+	task.Done([]error{nil, errors.New("failed")})
+	if task.Status() != Failed {
+		t.Fatalf("expected the task to be failed, got: %s", task.Status())
+	}
+	reports := Tasks()
+	report := reports[len(reports)-1]
+	if report.Name != "npm: 2 packages" || report.PackageManager != "npm" || !slices.Equal(report.Toolchains, []string{"node"}) ||
+		report.Status != Failed || !slices.Equal(report.Errors, []string{"failed"}) || report.Duration < 0 {
+		t.Fatalf("unexpected task report: %+v", report)
+	}
+	if data, err := json.Marshal(report.Status); err != nil || string(data) != `"failed"` {
+		t.Fatalf("expected the status to be marshalled as its name, got: %s, %v", data, err)
+	}
+	var nilTask *Task
+	nilTask.Done(nil)
+	Suspend(func() {})
+}
@@ -1,6 +1,9 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
 // MergeErrors collects any number of errors from various inputs:
 //   - chan error         (must be closed by sender)
@@ -70,3 +73,27 @@ func MergeErrors(input any) []error {
 	}
 	return merged
 }
+
+// RunWorkers calls fn on every item, running at most workers calls at the same time.
+// It returns once every call returned.
+func RunWorkers[T any](items []T, workers int, fn func(T)) {
+	if workers < 1 {
+		workers = 1
+	}
+	itemsChan := make(chan T)
+	var wg sync.WaitGroup
+	for range min(workers, len(items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range itemsChan {
+				fn(item)
+			}
+		}()
+	}
+	for _, item := range items {
+		itemsChan <- item
+	}
+	close(itemsChan)
+	wg.Wait()
+}
@@ -4,7 +4,9 @@ package utils
 import (
 	"errors"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_MergeErrors_various(t *testing.T) {
@@ -129,4 +131,35 @@ func Test_MergeErrors_various(t *testing.T) {
 			t.Fatalf("unexpected error message: %v", got[0].Error())
 		}
 	})
-}
\ No newline at end of file
+}
+
+func Test_RunWorkers(t *testing.T) {
+	t.Parallel()
+
+	items := make([]int, 50)
+	for i := range items {
+		items[i] = i
+	}
+	var running, maxRunning, sum atomic.Int64
+	RunWorkers(items, 4, func(item int) {
+		current := running.Add(1)
+		for {
+			previous := maxRunning.Load()
+			if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		sum.Add(int64(item))
+		running.Add(-1)
+	})
+	if got := sum.Load(); got != 1225 {
+		t.Fatalf("expected every item to be processed, got sum %d", got)
+	}
+	if got := maxRunning.Load(); got > 4 {
+		t.Fatalf("expected at most 4 concurrent calls, got %d", got)
+	}
+
+	// no items and invalid workers count
+	RunWorkers(nil, 0, func(int) { t.Fatalf("unexpected call") })
+}
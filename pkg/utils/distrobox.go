@@ -3,8 +3,12 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"go.uber.org/zap"
 )
@@ -16,10 +20,147 @@ const (
 	DISTROBOX_NOT_AVAILABLE_ERROR = "distrobox-export command is not available, please install it or ensure you are inside the distrobox"
 )
 
+// ErrDistroboxNotAvailable is returned when the distrobox-export command is missing, e.g. outside of a distrobox
+var ErrDistroboxNotAvailable = errors.New(DISTROBOX_NOT_AVAILABLE_ERROR)
+
+const (
+	// DISTROBOX_LIST_BINARIES_FLAG lists the binaries exported from the distrobox
+	DISTROBOX_LIST_BINARIES_FLAG = "--list-binaries"
+
+	// DISTROBOX_LIST_APPS_FLAG lists the applications exported from the distrobox
+	DISTROBOX_LIST_APPS_FLAG = "--list-apps"
+)
+
 var (
+	// DISTROBOX_EXPORT_WORKERS is the maximum number of distrobox-export commands running at the same time
+	DISTROBOX_EXPORT_WORKERS = 4
+
+	// DistroboxExportPath is the host directory receiving the exported binaries wrappers, distrobox defaults to ~/.local/bin when empty
+	DistroboxExportPath = Getenv("DEVBOX_EXPORT_PATH", "")
+
 	distroboxExportAvailable = IsDistroboxExportAvailable()
+
+	// distroboxExportsCache holds the parsed output of the distrobox-export list commands, keyed by list flag
+	distroboxExportsCache      = make(map[string]*DistroboxExports)
+	distroboxExportsCacheMutex sync.Mutex
 )
 
+// DistroboxExportOptions are the options of a binary or application export
+type DistroboxExportOptions struct {
+	// Sudo runs the exported binary or application as root on the host
+	Sudo bool
+	// ExtraFlags are appended to every invocation of the exported binary or application
+	ExtraFlags string
+	// ExportPath is the host directory receiving the binary wrapper, DistroboxExportPath is used when empty
+	ExportPath string
+	// ExportLabel replaces the " (on <container>)" suffix of the exported application name, "none" removes it
+	ExportLabel string
+}
+
+// DistroboxExportResult is the result of the export of a binary or an application
+type DistroboxExportResult struct {
+	Name string
+	// Skipped is true when the binary or application was already exported
+	Skipped bool
+	Err     error
+}
+
+// DistroboxExport is a binary or an application exported from the distrobox to the host system
+type DistroboxExport struct {
+	// Source is the path of the binary, or the name of the application, inside the distrobox
+	Source string
+	// Target is the path of the host-side wrapper script or desktop file, empty if unknown
+	Target string
+}
+
+// Name returns the name of the exported binary or application.
+func (e *DistroboxExport) Name() string {
+	return filepath.Base(e.Source)
+}
+
+// DistroboxExports is the parsed output of a distrobox-export list command
+type DistroboxExports struct {
+	Entries []*DistroboxExport
+	lookup  map[string]*DistroboxExport
+}
+
+// ParseDistroboxExports parses the output of distrobox-export --list-binaries or --list-apps.
+// Each line has the format "<source> | <target>", the target part being optional.
+// The container name is used to match the applications by their exported desktop file name.
+func ParseDistroboxExports(output string, containerName string) *DistroboxExports {
+	exports := &DistroboxExports{lookup: make(map[string]*DistroboxExport)}
+	for _, line := range strings.Split(output, "\n") {
+		source, target, _ := strings.Cut(line, "|")
+		export := &DistroboxExport{Source: strings.TrimSpace(source), Target: strings.TrimSpace(target)}
+		if export.Source == "" {
+			continue
+		}
+		exports.Entries = append(exports.Entries, export)
+		exports.lookup[export.Source] = export
+		exports.lookup[export.Name()] = export
+		if export.Target != "" {
+			// Exported desktop files are named <container>-<application>.desktop
+			desktopFile := strings.TrimSuffix(filepath.Base(export.Target), ".desktop")
+			exports.lookup[desktopFile] = export
+			if containerName != "" {
+				exports.lookup[strings.TrimPrefix(desktopFile, containerName+"-")] = export
+			}
+		}
+	}
+	return exports
+}
+
+// Contains reports whether the binary or application is exported, matching its exact name or path.
+func (e *DistroboxExports) Contains(nameOrPath string) bool {
+	return e.Find(nameOrPath) != nil
+}
+
+// Find returns the exported binary or application matching the exact name or path, or nil if it is not exported.
+func (e *DistroboxExports) Find(nameOrPath string) *DistroboxExport {
+	return e.lookup[nameOrPath]
+}
+
+// ListDistroboxBinaries returns the binaries exported from the distrobox.
+// The list is cached until the next export.
+func ListDistroboxBinaries() (*DistroboxExports, error) {
+	return listDistroboxExports(DISTROBOX_LIST_BINARIES_FLAG)
+}
+
+// ListDistroboxApplications returns the applications exported from the distrobox.
+// The list is cached until the next export.
+func ListDistroboxApplications() (*DistroboxExports, error) {
+	return listDistroboxExports(DISTROBOX_LIST_APPS_FLAG)
+}
+
+// InvalidateDistroboxExportsCache clears the cached lists of exported binaries and applications.
+func InvalidateDistroboxExportsCache() {
+	distroboxExportsCacheMutex.Lock()
+	defer distroboxExportsCacheMutex.Unlock()
+	clear(distroboxExportsCache)
+}
+
+// listDistroboxExports runs the distrobox-export list command once and caches its parsed output
+func listDistroboxExports(listFlag string) (*DistroboxExports, error) {
+	if !distroboxExportAvailable {
+		return nil, ErrDistroboxNotAvailable
+	}
+	distroboxExportsCacheMutex.Lock()
+	defer distroboxExportsCacheMutex.Unlock()
+	if exports, cached := distroboxExportsCache[listFlag]; cached {
+		return exports, nil
+	}
+
+	cmd := exec.Command(DISTROBOX_EXPORT_COMMAND, listFlag)
+	zap.L().Debug("Running command", zap.String("command", cmd.String()))
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	exports := ParseDistroboxExports(string(output), CurrentEnvironment().Name)
+	distroboxExportsCache[listFlag] = exports
+	return exports, nil
+}
+
 func IsDistroboxExportAvailable() bool {
 	// Check if the distrobox-export command is available
 	_, err := exec.LookPath(DISTROBOX_EXPORT_COMMAND)
@@ -29,56 +170,239 @@ func IsDistroboxExportAvailable() bool {
 // IsDistroboxBinaryExported checks if a package is exported from the distrobox.
 func IsDistroboxBinaryExported(packageName string) (bool, error) {
 	if !distroboxExportAvailable {
-		return false, errors.New(DISTROBOX_NOT_AVAILABLE_ERROR)
+		return false, ErrDistroboxNotAvailable
 	}
 	zap.L().Debug("Checking if package is exported from distrobox", zap.String("binary", packageName))
-	cmd := exec.Command(DISTROBOX_EXPORT_COMMAND, "--list-binaries")
-	output, err := cmd.Output()
+	exports, err := ListDistroboxBinaries()
 	if err != nil {
 		return false, fmt.Errorf("failed to list exported binaries: %w", err)
 	}
 
-	return strings.Contains(string(output), packageName), nil
+	return exports.Contains(packageName), nil
 }
 
 // ExportDistroboxBinaries exports a list of binaries from the distrobox to the host system.
 // It returns an error if the export fails.
 func ExportDistroboxBinaries(binaries []string) []error {
+	return ExportDistroboxBinariesWithOptions(binaries, nil)
+}
+
+// ExportDistroboxBinariesWithOptions exports a list of binaries from the distrobox to the host system,
+// applying the export options registered for each binary name.
+func ExportDistroboxBinariesWithOptions(binaries []string, options map[string]*DistroboxExportOptions) []error {
 	if !distroboxExportAvailable {
-		return []error{errors.New(DISTROBOX_NOT_AVAILABLE_ERROR)}
+		return []error{ErrDistroboxNotAvailable}
+	}
+	return DistroboxExportErrors(ExportDistroboxBinariesResults(binaries, options))
+}
+
+// ExportDistroboxBinariesResults exports the binaries missing from a single snapshot of the exported binaries,
+// running the exports through a bounded worker pool. It returns the result of each binary, in the given order.
+// The binaries exported with other options than the requested ones are exported again, the wrapper of the previous
+// export being deleted once the new one is written to another directory.
+func ExportDistroboxBinariesResults(binaries []string, options map[string]*DistroboxExportOptions) []*DistroboxExportResult {
+	upToDate := func(export *DistroboxExport) bool {
+		return binaryExportMatches(export, options[export.Name()])
+	}
+	return exportDistroboxBatch(binaries, ListDistroboxBinaries, upToDate, func(result *DistroboxExportResult, previous *DistroboxExport) error {
+		opts := options[result.Name]
+		if previous != nil {
+			zap.L().Info("Re-exporting binary from distrobox with its requested options", zap.String("binary", result.Name), zap.String("previous_target", previous.Target))
+		} else {
+			zap.L().Info("Exporting binary from distrobox", zap.String("binary", result.Name))
+		}
+		binaryPath, err := exec.LookPath(result.Name)
+		if err != nil {
+			return err
+		}
+		if err := exportDistroboxBinaryPath(binaryPath, opts); err != nil {
+			return err
+		}
+		if exportPath := requestedExportPath(opts); previous != nil && previous.Target != "" && exportPath != "" && filepath.Dir(previous.Target) != exportPath {
+			return UnexportDistroboxBinary(previous)
+		}
+		return nil
+	})
+}
+
+// requestedExportPath returns the directory receiving the binary wrapper with the given options,
+// the ~/.local/bin default of distrobox being empty when the home directory is unknown
+func requestedExportPath(opts *DistroboxExportOptions) string {
+	if opts != nil && opts.ExportPath != "" {
+		return filepath.Clean(opts.ExportPath)
+	}
+	if DistroboxExportPath != "" {
+		return filepath.Clean(DistroboxExportPath)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "bin")
+	}
+	return ""
+}
+
+// binaryExportMatches reports whether the tracked binary export was made with the requested options.
+// The exports are kept as is when no option is requested, or when their wrapper does not tell the options it was made with.
+func binaryExportMatches(export *DistroboxExport, opts *DistroboxExportOptions) bool {
+	if opts == nil || export.Target == "" {
+		return true
+	}
+	if exportPath := requestedExportPath(opts); exportPath != "" && exportPath != filepath.Dir(export.Target) {
+		return false
+	}
+	exported, known := exportedBinaryOptions(export)
+	if !known {
+		return true
+	}
+	return exported.Sudo == opts.Sudo && exported.ExtraFlags == strings.TrimSpace(opts.ExtraFlags)
+}
+
+// exportedBinaryOptions reads the options of the binary export from its host wrapper script, whose command entering
+// the distrobox runs "[sudo -S] '<binary>' [extra flags] "$@"". It reports false when the wrapper can not be read or parsed.
+func exportedBinaryOptions(export *DistroboxExport) (*DistroboxExportOptions, bool) {
+	opts := &DistroboxExportOptions{}
+	if export.Target == "" {
+		return opts, false
+	}
+	opts.ExportPath = filepath.Dir(export.Target)
+	wrapper, err := os.ReadFile(export.Target)
+	if err != nil {
+		return opts, false
+	}
+	quotedSource := "'" + export.Source + "'"
+	for _, line := range strings.Split(string(wrapper), "\n") {
+		if !strings.Contains(line, "distrobox-enter") {
+			continue
+		}
+		command, arguments, found := strings.Cut(line, quotedSource)
+		if !found {
+			continue
+		}
+		_, prefix, _ := strings.Cut(command, " -- ")
+		opts.Sudo = slices.ContainsFunc(strings.Fields(prefix), func(field string) bool {
+			return filepath.Base(field) == "sudo" || filepath.Base(field) == "sudo-rs"
+		})
+		opts.ExtraFlags = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(arguments), `"$@"`))
+		return opts, true
 	}
-	errorChan := make(chan error, len(binaries))
-	for _, binary := range binaries {
-		errorChan <- ExportDistroboxBinary(binary)
+	return opts, false
+}
+
+// DistroboxExportErrors returns the errors of the failed exports
+func DistroboxExportErrors(results []*DistroboxExportResult) []error {
+	errorChan := make(chan error, len(results))
+	for _, result := range results {
+		errorChan <- result.Err
 	}
 	close(errorChan)
 	return MergeErrors(errorChan)
 }
 
+// exportDistroboxBatch runs the export of the items missing from the list snapshot, or whose tracked export is not up to date,
+// through a bounded worker pool. The export receives the tracked export to replace, nil when the item was not exported.
+// The cached exports lists are invalidated once, after every export returned.
+func exportDistroboxBatch(names []string, list func() (*DistroboxExports, error), upToDate func(*DistroboxExport) bool,
+	export func(*DistroboxExportResult, *DistroboxExport) error) []*DistroboxExportResult {
+	results := make([]*DistroboxExportResult, len(names))
+	for i, name := range names {
+		results[i] = &DistroboxExportResult{Name: name}
+	}
+	if !distroboxExportAvailable {
+		for _, result := range results {
+			result.Err = ErrDistroboxNotAvailable
+		}
+		return results
+	}
+
+	exports, err := list()
+	if err != nil {
+		for _, result := range results {
+			result.Err = fmt.Errorf("failed to list exports: %w", err)
+		}
+		return results
+	}
+
+	pending := make([]*DistroboxExportResult, 0, len(results))
+	seen := make(map[string]struct{}, len(results))
+	for _, result := range results {
+		_, duplicate := seen[result.Name]
+		if tracked := exports.Find(result.Name); duplicate || (tracked != nil && (upToDate == nil || upToDate(tracked))) {
+			result.Skipped = true
+			continue
+		}
+		seen[result.Name] = struct{}{}
+		pending = append(pending, result)
+	}
+	if len(pending) == 0 {
+		return results
+	}
+
+	defer InvalidateDistroboxExportsCache()
+	RunWorkers(pending, DISTROBOX_EXPORT_WORKERS, func(result *DistroboxExportResult) {
+		result.Err = export(result, exports.Find(result.Name))
+	})
+	return results
+}
+
 // ExportDistroboxBinary exports a binary from the distrobox to the host system.
 // It returns an error if the export fails.
 func ExportDistroboxBinary(binaryName string) error {
+	return ExportDistroboxBinaryWithOptions(binaryName, nil)
+}
+
+// ExportDistroboxBinaryWithOptions exports a binary from the distrobox to the host system with the given export options.
+// A binary already exported with other options is exported again, as ExportDistroboxBinaries does.
+// It returns an error if the export fails.
+func ExportDistroboxBinaryWithOptions(binaryName string, opts *DistroboxExportOptions) error {
 	if !distroboxExportAvailable {
 		zap.L().Error(DISTROBOX_NOT_AVAILABLE_ERROR)
-		return errors.New(DISTROBOX_NOT_AVAILABLE_ERROR)
+		return ErrDistroboxNotAvailable
 	}
-	zap.L().Info("Exporting binary from distrobox", zap.String("binary", binaryName))
-	// Check if the binary is already exported
-	exported, err := IsDistroboxBinaryExported(binaryName)
-	if err != nil {
-		return fmt.Errorf("failed to check if binary is exported: %w", err)
-	} else if exported {
-		return nil // Binary is already exported, no need to export again
+	results := ExportDistroboxBinariesResults([]string{binaryName}, map[string]*DistroboxExportOptions{binaryName: opts})
+	return results[0].Err
+}
+
+// DistroboxBinaryExportArgs returns the distrobox-export arguments exporting the binary at the given path.
+// The export path defaults to DistroboxExportPath when the options do not set one.
+func DistroboxBinaryExportArgs(binaryPath string, opts *DistroboxExportOptions) []string {
+	args := []string{"--bin", binaryPath}
+	exportPath := DistroboxExportPath
+	if opts != nil {
+		if opts.Sudo {
+			args = append(args, "--sudo")
+		}
+		if opts.ExtraFlags != "" {
+			args = append(args, "--extra-flags", opts.ExtraFlags)
+		}
+		if opts.ExportPath != "" {
+			exportPath = opts.ExportPath
+		}
+	}
+	if exportPath != "" {
+		args = append(args, "--export-path", exportPath)
 	}
+	return args
+}
 
-	// Determine the path of the binary in the distrobox
-	binaryPath, err := exec.LookPath(binaryName)
-	if err != nil {
-		return err
+// DistroboxApplicationExportArgs returns the distrobox-export arguments exporting the application.
+func DistroboxApplicationExportArgs(appName string, opts *DistroboxExportOptions) []string {
+	args := []string{"--app", appName}
+	if opts != nil {
+		if opts.Sudo {
+			args = append(args, "--sudo")
+		}
+		if opts.ExtraFlags != "" {
+			args = append(args, "--extra-flags", opts.ExtraFlags)
+		}
+		if opts.ExportLabel != "" {
+			args = append(args, "--export-label", opts.ExportLabel)
+		}
 	}
+	return args
+}
 
-	// Construct the export command
-	cmd := exec.Command(DISTROBOX_EXPORT_COMMAND, "--bin", binaryPath)
+// exportDistroboxBinaryPath exports the binary at the given path to the host, without invalidating the cached exports lists
+func exportDistroboxBinaryPath(binaryPath string, opts *DistroboxExportOptions) error {
+	cmd := exec.Command(DISTROBOX_EXPORT_COMMAND, DistroboxBinaryExportArgs(binaryPath, opts)...)
 	cmd.Stdout = nil // Redirect output to nil
 	cmd.Stderr = nil // Redirect error to nil
 	zap.L().Debug("Running command", zap.String("command", cmd.String()))
@@ -91,38 +415,53 @@ func ExportDistroboxBinary(binaryName string) error {
 // IsDistroboxApplicationExported checks if an application is exported from the distrobox.
 func IsDistroboxApplicationExported(appName string) (bool, error) {
 	if !distroboxExportAvailable {
-		return false, errors.New(DISTROBOX_NOT_AVAILABLE_ERROR)
+		return false, ErrDistroboxNotAvailable
 	}
 	zap.L().Debug("Checking if application is exported from distrobox", zap.String("application", appName))
-	cmd := exec.Command(DISTROBOX_EXPORT_COMMAND, "--list-apps")
-	output, err := cmd.Output()
+	exports, err := ListDistroboxApplications()
 	if err != nil {
 		zap.L().Error("Error listing exported applications", zap.String("application", appName), zap.Error(err))
 		return false, fmt.Errorf("failed to list exported applications: %w", err)
 	}
 
-	return strings.Contains(string(output), appName), nil
+	return exports.Contains(appName), nil
 }
 
 // ExportDistroboxApplications exports a list of applications from the distrobox to the host system.
 // It returns an error if the export fails.
 func ExportDistroboxApplications(apps []string) []error {
+	return ExportDistroboxApplicationsWithOptions(apps, nil)
+}
+
+// ExportDistroboxApplicationsWithOptions exports a list of applications from the distrobox to the host system,
+// applying the export options registered for each application name.
+func ExportDistroboxApplicationsWithOptions(apps []string, options map[string]*DistroboxExportOptions) []error {
 	if !distroboxExportAvailable {
-		return []error{errors.New(DISTROBOX_NOT_AVAILABLE_ERROR)}
-	}
-	errorChan := make(chan error, len(apps))
-	for _, app := range apps {
-		errorChan <- ExportDistroboxApplication(app)
+		return []error{ErrDistroboxNotAvailable}
 	}
-	close(errorChan)
-	return MergeErrors(errorChan)
+	return DistroboxExportErrors(ExportDistroboxApplicationsResults(apps, options))
+}
+
+// ExportDistroboxApplicationsResults exports the applications missing from a single snapshot of the exported applications,
+// running the exports through a bounded worker pool. It returns the result of each application, in the given order.
+func ExportDistroboxApplicationsResults(apps []string, options map[string]*DistroboxExportOptions) []*DistroboxExportResult {
+	return exportDistroboxBatch(apps, ListDistroboxApplications, nil, func(result *DistroboxExportResult, _ *DistroboxExport) error {
+		zap.L().Info("Exporting application from distrobox", zap.String("application", result.Name))
+		return exportDistroboxApplication(result.Name, options[result.Name])
+	})
 }
 
 // ExportDistroboxApplication exports an application from the distrobox to the host system.
 // It returns an error if the export fails.
 func ExportDistroboxApplication(appName string) error {
+	return ExportDistroboxApplicationWithOptions(appName, nil)
+}
+
+// ExportDistroboxApplicationWithOptions exports an application from the distrobox to the host system with the given export options.
+// It returns an error if the export fails.
+func ExportDistroboxApplicationWithOptions(appName string, opts *DistroboxExportOptions) error {
 	if !distroboxExportAvailable {
-		return errors.New(DISTROBOX_NOT_AVAILABLE_ERROR)
+		return ErrDistroboxNotAvailable
 	}
 	zap.L().Info("Exporting application from distrobox", zap.String("application", appName))
 	// Check if the application is already exported
@@ -134,8 +473,13 @@ func ExportDistroboxApplication(appName string) error {
 		return nil // Application is already exported, no need to export again
 	}
 
-	// Construct the export command
-	cmd := exec.Command(DISTROBOX_EXPORT_COMMAND, "--app", appName)
+	defer InvalidateDistroboxExportsCache()
+	return exportDistroboxApplication(appName, opts)
+}
+
+// exportDistroboxApplication exports the application to the host, without invalidating the cached exports lists
+func exportDistroboxApplication(appName string, opts *DistroboxExportOptions) error {
+	cmd := exec.Command(DISTROBOX_EXPORT_COMMAND, DistroboxApplicationExportArgs(appName, opts)...)
 	cmd.Stdout = nil // Redirect output to nil
 	cmd.Stderr = nil // Redirect error to nil
 	zap.L().Debug("Running command", zap.String("command", cmd.String()))
@@ -145,3 +489,63 @@ func ExportDistroboxApplication(appName string) error {
 	}
 	return nil
 }
+
+// BrokenDistroboxExport is an exported binary or application whose host-side wrapper is stale or broken
+type BrokenDistroboxExport struct {
+	*DistroboxExport
+	Reason string
+}
+
+// BrokenDistroboxBinaries returns the exported binaries whose host-side wrapper scripts are stale or broken:
+// the wrapper is missing, the wrapper does not run the exported binary anymore or the binary no longer exists in the distrobox.
+func BrokenDistroboxBinaries() ([]*BrokenDistroboxExport, error) {
+	exports, err := ListDistroboxBinaries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exported binaries: %w", err)
+	}
+
+	var broken []*BrokenDistroboxExport
+	for _, export := range exports.Entries {
+		if reason := brokenBinaryReason(export); reason != "" {
+			broken = append(broken, &BrokenDistroboxExport{DistroboxExport: export, Reason: reason})
+		}
+	}
+	return broken, nil
+}
+
+// BrokenDistroboxApplications returns the exported applications whose desktop file is missing on the host.
+func BrokenDistroboxApplications() ([]*BrokenDistroboxExport, error) {
+	exports, err := ListDistroboxApplications()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exported applications: %w", err)
+	}
+
+	var broken []*BrokenDistroboxExport
+	for _, export := range exports.Entries {
+		if export.Target == "" {
+			continue
+		}
+		if _, err := os.Stat(export.Target); err != nil {
+			broken = append(broken, &BrokenDistroboxExport{DistroboxExport: export, Reason: "desktop file is missing"})
+		}
+	}
+	return broken, nil
+}
+
+// brokenBinaryReason returns why the exported binary is broken, or an empty string if it is healthy
+func brokenBinaryReason(export *DistroboxExport) string {
+	if _, err := os.Stat(export.Source); err != nil {
+		return "binary no longer exists in the distrobox"
+	}
+	if export.Target == "" {
+		return ""
+	}
+	wrapper, err := os.ReadFile(export.Target)
+	if err != nil {
+		return "host wrapper script is missing or unreadable"
+	}
+	if !strings.Contains(string(wrapper), export.Source) {
+		return "host wrapper script does not run the exported binary"
+	}
+	return ""
+}
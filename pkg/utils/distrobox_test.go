@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 
@@ -337,6 +339,7 @@ exit 0
 				// restore PATH and reset distroboxExportAvailable to its initial detection
 				_ = os.Setenv("PATH", origPath)
 				distroboxExportAvailable = IsDistroboxExportAvailable()
+				InvalidateDistroboxExportsCache()
 				if setup.cleanup != nil {
 					setup.cleanup()
 				}
@@ -355,3 +358,367 @@ exit 0
 		})
 	}
 }
+
+func Test_ParseDistroboxExports(t *testing.T) {
+	t.Parallel()
+	binaries := ParseDistroboxExports(`/usr/bin/gofmt       | /home/user/.local/bin/gofmt
+/usr/bin/cargo        | /home/user/.local/bin/cargo
+/usr/bin/javac        | /home/user/.local/bin/javac
+
+`, "go-dev")
+	if len(binaries.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got: %d", len(binaries.Entries))
+	}
+	if binaries.Entries[0].Source != "/usr/bin/gofmt" || binaries.Entries[0].Target != "/home/user/.local/bin/gofmt" || binaries.Entries[0].Name() != "gofmt" {
+		t.Fatalf("unexpected first entry: %+v", binaries.Entries[0])
+	}
+	for _, exported := range []string{"gofmt", "cargo", "javac", "/usr/bin/javac"} {
+		if !binaries.Contains(exported) {
+			t.Errorf("expected %q to be exported", exported)
+		}
+	}
+	// Substrings of exported binaries must not be reported as exported
+	for _, notExported := range []string{"go", "java", "fmt", "/usr/bin/go", "bin"} {
+		if binaries.Contains(notExported) {
+			t.Errorf("expected %q NOT to be exported", notExported)
+		}
+	}
+
+	apps := ParseDistroboxExports("Visual Studio Code   | /home/user/.local/share/applications/go-dev-code.desktop\n", "go-dev")
+	for _, exported := range []string{"Visual Studio Code", "code", "go-dev-code"} {
+		if !apps.Contains(exported) {
+			t.Errorf("expected application %q to be exported", exported)
+		}
+	}
+	if apps.Contains("dev-code") || apps.Contains("cod") {
+		t.Errorf("expected partial application names NOT to be exported")
+	}
+}
+
+func Test_ListDistroboxBinaries_Cache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping distrobox script tests on Windows")
+	}
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "list.count")
+	makeDistroboxScript(t, dir, `#!/bin/sh
+if [ "$1" = "--list-binaries" ]; then
+	echo x >> "`+counter+`"
+	echo "/usr/bin/gofmt | /home/user/.local/bin/gofmt"
+	exit 0
+fi
+exit 0
+`)
+	makeFakeBinary(t, dir, "go", "#!/bin/sh\nexit 0\n")
+	makeFakeBinary(t, dir, "cargo", "#!/bin/sh\nexit 0\n")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	distroboxExportAvailable = IsDistroboxExportAvailable()
+	InvalidateDistroboxExportsCache()
+	defer func() {
+		InvalidateDistroboxExportsCache()
+		distroboxExportAvailable = IsDistroboxExportAvailable()
+	}()
+
+	listCalls := func() int {
+		data, _ := os.ReadFile(counter)
+		return strings.Count(string(data), "x")
+	}
+
+	for _, binary := range []string{"go", "gofmt", "cargo"} {
+		if _, err := IsDistroboxBinaryExported(binary); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := listCalls(); got != 1 {
+		t.Fatalf("expected the binaries to be listed once, got %d calls", got)
+	}
+
+	// Exporting a binary uses the cached list, then invalidates it
+	if err := ExportDistroboxBinary("go"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := listCalls(); got != 1 {
+		t.Fatalf("expected the export to use the cached list, got %d calls", got)
+	}
+	if _, err := IsDistroboxBinaryExported("cargo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := listCalls(); got != 2 {
+		t.Fatalf("expected the cache to be invalidated after the export, got %d calls", got)
+	}
+}
+
+func Test_BrokenDistroboxBinaries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping distrobox script tests on Windows")
+	}
+	dir := t.TempDir()
+	healthy := makeFakeBinary(t, dir, "healthy", "#!/bin/sh\nexit 0\n")
+	moved := makeFakeBinary(t, dir, "moved", "#!/bin/sh\nexit 0\n")
+	wrappers := filepath.Join(dir, "wrappers")
+	if err := os.MkdirAll(wrappers, 0o700); err != nil {
+		t.Fatalf("failed to create wrappers dir: %v", err)
+	}
+	writeExecutable(t, filepath.Join(wrappers, "healthy"), "#!/bin/sh\n# distrobox_binary\n"+healthy+" \"$@\"\n")
+	writeExecutable(t, filepath.Join(wrappers, "moved"), "#!/bin/sh\n# distrobox_binary\n/usr/bin/moved \"$@\"\n")
+	writeExecutable(t, filepath.Join(wrappers, "removed"), "#!/bin/sh\n# distrobox_binary\n"+filepath.Join(dir, "removed")+" \"$@\"\n")
+
+	makeDistroboxScript(t, dir, `#!/bin/sh
+if [ "$1" = "--list-binaries" ]; then
+	echo "`+healthy+` | `+filepath.Join(wrappers, "healthy")+`"
+	echo "`+moved+` | `+filepath.Join(wrappers, "moved")+`"
+	echo "`+filepath.Join(dir, "removed")+` | `+filepath.Join(wrappers, "removed")+`"
+	echo "`+healthy+` | `+filepath.Join(wrappers, "missing")+`"
+	exit 0
+fi
+exit 0
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	distroboxExportAvailable = IsDistroboxExportAvailable()
+	InvalidateDistroboxExportsCache()
+	defer func() {
+		InvalidateDistroboxExportsCache()
+		distroboxExportAvailable = IsDistroboxExportAvailable()
+	}()
+
+	broken, err := BrokenDistroboxBinaries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := make(map[string]string)
+	for _, b := range broken {
+		got[b.Target] = b.Reason
+	}
+	want := map[string]string{
+		filepath.Join(wrappers, "moved"):   "host wrapper script does not run the exported binary",
+		filepath.Join(wrappers, "removed"): "binary no longer exists in the distrobox",
+		filepath.Join(wrappers, "missing"): "host wrapper script is missing or unreadable",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d broken exports, got: %v", len(want), got)
+	}
+	for target, reason := range want {
+		if got[target] != reason {
+			t.Errorf("expected %s to be broken with reason %q, got %q", target, reason, got[target])
+		}
+	}
+}
+
+func Test_DistroboxExportArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		exportPath string
+		app        bool
+		opts       *DistroboxExportOptions
+		want       []string
+	}{
+		{
+			name: "binary without options",
+			want: []string{"--bin", "/usr/bin/valgrind"},
+		},
+		{
+			name:       "binary with default export path",
+			exportPath: "/home/user/bin",
+			want:       []string{"--bin", "/usr/bin/valgrind", "--export-path", "/home/user/bin"},
+		},
+		{
+			name:       "binary with all options",
+			exportPath: "/home/user/bin",
+			opts:       &DistroboxExportOptions{Sudo: true, ExtraFlags: "--tool=memcheck -q", ExportPath: "/opt/bin", ExportLabel: "ignored"},
+			want:       []string{"--bin", "/usr/bin/valgrind", "--sudo", "--extra-flags", "--tool=memcheck -q", "--export-path", "/opt/bin"},
+		},
+		{
+			name: "application without options",
+			app:  true,
+			want: []string{"--app", "code"},
+		},
+		{
+			name:       "application with all options",
+			exportPath: "/home/user/bin",
+			app:        true,
+			opts:       &DistroboxExportOptions{Sudo: true, ExtraFlags: "--disable-gpu", ExportPath: "/opt/bin", ExportLabel: "none"},
+			want:       []string{"--app", "code", "--sudo", "--extra-flags", "--disable-gpu", "--export-label", "none"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := DistroboxExportPath
+			DistroboxExportPath = tt.exportPath
+			defer func() { DistroboxExportPath = previous }()
+
+			var got []string
+			if tt.app {
+				got = DistroboxApplicationExportArgs("code", tt.opts)
+			} else {
+				got = DistroboxBinaryExportArgs("/usr/bin/valgrind", tt.opts)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected args %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_ExportDistroboxBinariesWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	logFile := stubDistroboxExport(t, dir, "", "")
+	valgrind := makeFakeBinary(t, dir, "valgrind", "#!/bin/sh\nexit 0\n")
+	gdb := makeFakeBinary(t, dir, "gdb", "#!/bin/sh\nexit 0\n")
+
+	errs := ExportDistroboxBinariesWithOptions([]string{"valgrind", "gdb"}, map[string]*DistroboxExportOptions{
+		"valgrind": {Sudo: true, ExtraFlags: "-q"},
+	})
+	if errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := []string{"--bin " + gdb, "--bin " + valgrind + " --sudo --extra-flags -q"}
+	calls := readExportCalls(t, logFile)
+	// the exports run concurrently
+	slices.Sort(calls)
+	if !slices.Equal(calls, want) {
+		t.Fatalf("expected calls %v, got: %v", want, calls)
+	}
+}
+
+func Test_ExportDistroboxBinariesResults_ChangedOptions(t *testing.T) {
+	wrapper := func(enter string) string {
+		return "#!/bin/sh\n# distrobox_binary\n# name: devbox\nif [ -z \"${CONTAINER_ID}\" ]; then\n\texec \"distrobox-enter\"  -n devbox -- " + enter + " \"$@\"\nfi\n"
+	}
+	tests := []struct {
+		name      string
+		enter     string
+		opts      *DistroboxExportOptions
+		wantCalls func(valgrind, wrappers, other string) []string
+	}{
+		{
+			name:      "same options",
+			enter:     "sudo -S '{valgrind}' --tool=memcheck",
+			opts:      &DistroboxExportOptions{Sudo: true, ExtraFlags: "--tool=memcheck"},
+			wantCalls: func(string, string, string) []string { return nil },
+		},
+		{
+			name:      "no requested options",
+			enter:     "sudo -S '{valgrind}'",
+			wantCalls: func(string, string, string) []string { return nil },
+		},
+		{
+			name:  "sudo requested",
+			enter: "'{valgrind}'",
+			opts:  &DistroboxExportOptions{Sudo: true},
+			wantCalls: func(valgrind, wrappers, _ string) []string {
+				return []string{"--bin " + valgrind + " --sudo --export-path " + wrappers}
+			},
+		},
+		{
+			name:  "extra flags changed",
+			enter: "sudo -S '{valgrind}' -q",
+			opts:  &DistroboxExportOptions{Sudo: true},
+			wantCalls: func(valgrind, wrappers, _ string) []string {
+				return []string{"--bin " + valgrind + " --sudo --export-path " + wrappers}
+			},
+		},
+		{
+			name:  "export path changed",
+			enter: "sudo -S '{valgrind}'",
+			opts:  &DistroboxExportOptions{Sudo: true, ExportPath: "{other}"},
+			wantCalls: func(valgrind, wrappers, other string) []string {
+				return []string{"--bin " + valgrind + " --sudo --export-path " + other, "--bin " + valgrind + " --delete --export-path " + wrappers}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			wrappers := filepath.Join(dir, "wrappers")
+			if err := os.MkdirAll(wrappers, 0o700); err != nil {
+				t.Fatalf("failed to create wrappers dir: %v", err)
+			}
+			other := filepath.Join(dir, "other")
+			valgrind := makeFakeBinary(t, dir, "valgrind", "#!/bin/sh\nexit 0\n")
+			writeExecutable(t, filepath.Join(wrappers, "valgrind"), wrapper(strings.ReplaceAll(tt.enter, "{valgrind}", valgrind)))
+			logFile := stubDistroboxExport(t, dir, valgrind+" | "+filepath.Join(wrappers, "valgrind"), "")
+			previous := DistroboxExportPath
+			DistroboxExportPath = wrappers
+			t.Cleanup(func() { DistroboxExportPath = previous })
+
+			var options map[string]*DistroboxExportOptions
+			if tt.opts != nil {
+				opts := *tt.opts
+				opts.ExportPath = strings.ReplaceAll(opts.ExportPath, "{other}", other)
+				options = map[string]*DistroboxExportOptions{"valgrind": &opts}
+			}
+			results := ExportDistroboxBinariesResults([]string{"valgrind"}, options)
+			if results[0].Err != nil {
+				t.Fatalf("unexpected error: %v", results[0].Err)
+			}
+			want := tt.wantCalls(valgrind, wrappers, other)
+			if got := readExportCalls(t, logFile); !slices.Equal(got, want) {
+				t.Fatalf("expected calls %q, got: %q", want, got)
+			}
+			if results[0].Skipped != (want == nil) {
+				t.Fatalf("expected skipped to be %t, got: %+v", want == nil, results[0])
+			}
+
+			// the single binary export re-exports it the same way
+			os.Remove(logFile)
+			if err := ExportDistroboxBinaryWithOptions("valgrind", options["valgrind"]); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := readExportCalls(t, logFile); !slices.Equal(got, want) {
+				t.Fatalf("expected single export calls %q, got: %q", want, got)
+			}
+		})
+	}
+}
+
+func Test_ExportDistroboxBinariesResults_CommandCount(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping distrobox script tests on Windows")
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "calls.log")
+	makeDistroboxScript(t, dir, `#!/bin/sh
+echo "$1" >> "`+logFile+`"
+if [ "$1" = "--list-binaries" ]; then
+	echo "/usr/bin/exported | /home/user/.local/bin/exported"
+fi
+exit 0
+`)
+	binaries := []string{"exported"}
+	for i := range 25 {
+		name := fmt.Sprintf("bin%d", i)
+		makeFakeBinary(t, dir, name, "#!/bin/sh\nexit 0\n")
+		binaries = append(binaries, name)
+	}
+	// duplicates are exported once
+	binaries = append(binaries, "bin0")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	distroboxExportAvailable = IsDistroboxExportAvailable()
+	InvalidateDistroboxExportsCache()
+	defer func() {
+		InvalidateDistroboxExportsCache()
+		distroboxExportAvailable = IsDistroboxExportAvailable()
+	}()
+
+	results := ExportDistroboxBinariesResults(binaries, nil)
+	if len(results) != len(binaries) {
+		t.Fatalf("expected %d results, got: %d", len(binaries), len(results))
+	}
+	for i, result := range results {
+		if result.Name != binaries[i] || result.Err != nil {
+			t.Fatalf("unexpected result %d: %+v", i, result)
+		}
+		if wantSkipped := i == 0 || i == len(binaries)-1; result.Skipped != wantSkipped {
+			t.Fatalf("expected %s skipped to be %t", result.Name, wantSkipped)
+		}
+	}
+
+	// 1 list snapshot + 25 exports, instead of a list, a lookup and an export per binary
+	calls := readExportCalls(t, logFile)
+	if len(calls) != 26 || slices.Index(calls, "--list-binaries") != 0 || strings.Count(strings.Join(calls, "\n"), "--bin") != 25 {
+		t.Fatalf("expected 1 list and 25 exports, got %d calls: %v", len(calls), calls)
+	}
+	if _, cached := distroboxExportsCache[DISTROBOX_LIST_BINARIES_FLAG]; cached {
+		t.Fatalf("expected the cache to be invalidated after the exports")
+	}
+}
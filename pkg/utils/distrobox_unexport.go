@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// UnexportDistroboxExports removes the host-side wrappers of the given exported binaries or applications.
+// Each name is looked up in the exported binaries first, then in the exported applications.
+func UnexportDistroboxExports(names []string) []error {
+	if !distroboxExportAvailable {
+		return []error{ErrDistroboxNotAvailable}
+	}
+	errorChan := make(chan error, len(names))
+	for _, name := range names {
+		binaries, err := ListDistroboxBinaries()
+		if err != nil {
+			errorChan <- fmt.Errorf("failed to list exported binaries: %w", err)
+			break
+		}
+		if export := binaries.Find(name); export != nil {
+			errorChan <- UnexportDistroboxBinary(export)
+			continue
+		}
+
+		applications, err := ListDistroboxApplications()
+		if err != nil {
+			errorChan <- fmt.Errorf("failed to list exported applications: %w", err)
+			break
+		}
+		if export := applications.Find(name); export != nil {
+			errorChan <- UnexportDistroboxApplication(export)
+			continue
+		}
+		errorChan <- fmt.Errorf("%s is neither an exported binary nor an exported application", name)
+	}
+	close(errorChan)
+	return MergeErrors(errorChan)
+}
+
+// UnexportDistroboxBinary deletes the host-side wrapper script of the exported binary.
+func UnexportDistroboxBinary(export *DistroboxExport) error {
+	zap.L().Info("Unexporting binary from distrobox", zap.String("binary", export.Source))
+	args := []string{"--bin", export.Source, "--delete"}
+	// The wrapper must be deleted from the directory it was exported to
+	if export.Target != "" {
+		args = append(args, "--export-path", filepath.Dir(export.Target))
+	} else if DistroboxExportPath != "" {
+		args = append(args, "--export-path", DistroboxExportPath)
+	}
+	if err := runDistroboxExport(args...); err != nil {
+		return fmt.Errorf("failed to unexport binary %s: %w", export.Source, err)
+	}
+	return nil
+}
+
+// UnexportDistroboxApplication deletes the host-side desktop file of the exported application.
+func UnexportDistroboxApplication(export *DistroboxExport) error {
+	appName := applicationName(export)
+	zap.L().Info("Unexporting application from distrobox", zap.String("application", appName))
+	if err := runDistroboxExport("--app", appName, "--delete"); err != nil {
+		return fmt.Errorf("failed to unexport application %s: %w", appName, err)
+	}
+	return nil
+}
+
+// RepairDistroboxBinaries re-exports every tracked binary whose path in the distrobox changed since it was exported.
+func RepairDistroboxBinaries() []error {
+	return reexportDistroboxBinaries(false)
+}
+
+// ReexportDistroboxExports deletes and exports again every tracked binary and application,
+// the binaries being exported from their current path in the distrobox.
+func ReexportDistroboxExports() []error {
+	errs := reexportDistroboxBinaries(true)
+	return append(errs, reexportDistroboxApplications()...)
+}
+
+// reexportDistroboxBinaries re-exports the tracked binaries whose path changed, or all of them if all is true.
+// Each binary is exported again to the directory holding its current wrapper.
+func reexportDistroboxBinaries(all bool) []error {
+	if !distroboxExportAvailable {
+		return []error{ErrDistroboxNotAvailable}
+	}
+	binaries, err := ListDistroboxBinaries()
+	if err != nil {
+		return []error{fmt.Errorf("failed to list exported binaries: %w", err)}
+	}
+	// Copy the entries, the cached list is invalidated by the exports
+	entries := slices.Clone(binaries.Entries)
+	defer InvalidateDistroboxExportsCache()
+
+	errorChan := make(chan error, len(entries))
+	for _, export := range entries {
+		currentPath, err := currentBinaryPath(export)
+		if err != nil {
+			errorChan <- fmt.Errorf("exported binary %s is no longer available in the distrobox, use devbox unexport to remove it: %w", export.Name(), err)
+			continue
+		}
+		if !all && currentPath == export.Source {
+			continue
+		}
+
+		// The binary keeps the directory, sudo and extra flags its wrapper was exported with
+		opts, _ := exportedBinaryOptions(export)
+		zap.L().Info("Re-exporting binary from distrobox", zap.String("binary", export.Name()), zap.String("previous_path", export.Source), zap.String("path", currentPath))
+		// The wrapper is only deleted once the current path is known to exist, so that the export is never lost
+		if err := UnexportDistroboxBinary(export); err != nil {
+			errorChan <- err
+			continue
+		}
+		errorChan <- exportDistroboxBinaryPath(currentPath, opts)
+	}
+	close(errorChan)
+	return MergeErrors(errorChan)
+}
+
+// currentBinaryPath returns the path of the exported binary in the distrobox: the first executable with its name on PATH,
+// otherwise its exported path while it exists (e.g. the downloads of DEVBOX_BIN_DIR, which are not on PATH).
+// The directories receiving the host wrappers are skipped, they are on PATH too and hold the wrapper itself.
+// The exported path is kept when it is the same file as the one found on PATH (e.g. through the /bin symbolic link).
+func currentBinaryPath(export *DistroboxExport) (string, error) {
+	sourceInfo, sourceErr := os.Stat(export.Source)
+	if sourceErr == nil && sourceInfo.IsDir() {
+		sourceErr = fmt.Errorf("%s is a directory", export.Source)
+	}
+	wrappersDirectories := exportDirectories(export)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" || slices.Contains(wrappersDirectories, filepath.Clean(dir)) {
+			continue
+		}
+		path, err := exec.LookPath(filepath.Join(dir, export.Name()))
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && sourceErr == nil && os.SameFile(info, sourceInfo) {
+			return export.Source, nil
+		}
+		return path, nil
+	}
+	if sourceErr == nil {
+		return export.Source, nil
+	}
+	return "", fmt.Errorf("%s not found on PATH nor in %s", export.Name(), export.Source)
+}
+
+// exportDirectories returns the directories which may hold the host wrapper of the exported binary:
+// the directory of its tracked wrapper, the configured export path and the distrobox default ~/.local/bin
+func exportDirectories(export *DistroboxExport) []string {
+	var dirs []string
+	if export.Target != "" {
+		dirs = append(dirs, filepath.Dir(export.Target))
+	}
+	if DistroboxExportPath != "" {
+		dirs = append(dirs, filepath.Clean(DistroboxExportPath))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local", "bin"))
+	}
+	return dirs
+}
+
+// reexportDistroboxApplications deletes and exports again every tracked application
+func reexportDistroboxApplications() []error {
+	if !distroboxExportAvailable {
+		return []error{ErrDistroboxNotAvailable}
+	}
+	applications, err := ListDistroboxApplications()
+	if err != nil {
+		return []error{fmt.Errorf("failed to list exported applications: %w", err)}
+	}
+	entries := slices.Clone(applications.Entries)
+
+	errorChan := make(chan error, len(entries))
+	for _, export := range entries {
+		// The options are read from the desktop file before the unexport deletes it
+		opts := exportedApplicationOptions(export)
+		if err := UnexportDistroboxApplication(export); err != nil {
+			errorChan <- err
+			continue
+		}
+		appName := applicationName(export)
+		if err := runDistroboxExport(DistroboxApplicationExportArgs(appName, opts)...); err != nil {
+			errorChan <- fmt.Errorf("failed to export application %s: %w", appName, err)
+		}
+	}
+	close(errorChan)
+	return MergeErrors(errorChan)
+}
+
+// applicationName returns the name used to export the application, taken from its desktop file name
+// (<container>-<application>.desktop) when the container name is known
+func applicationName(export *DistroboxExport) string {
+	containerName := CurrentEnvironment().Name
+	if export.Target == "" || containerName == "" {
+		return export.Source
+	}
+	desktopFile := strings.TrimSuffix(filepath.Base(export.Target), ".desktop")
+	return strings.TrimPrefix(desktopFile, containerName+"-")
+}
+
+// exportedApplicationOptions reads the options of the application export from its host desktop file, compared with
+// the desktop file of the application in the distrobox: the label suffixing its name, and the sudo prefix and extra flags
+// of the command entering the distrobox. The options that can not be told apart are left to their defaults.
+func exportedApplicationOptions(export *DistroboxExport) *DistroboxExportOptions {
+	opts := &DistroboxExportOptions{}
+	exported := readDesktopEntry(export.Target)
+	if exported == nil {
+		return opts
+	}
+	original := readDesktopEntry(applicationDesktopFile(applicationName(export)))
+
+	_, command, _ := strings.Cut(exported["Exec"], " -- ")
+	fields := strings.Fields(command)
+	if len(fields) > 0 && (filepath.Base(fields[0]) == "sudo" || filepath.Base(fields[0]) == "sudo-rs") {
+		opts.Sudo = true
+		fields = fields[1:]
+		if len(fields) > 0 && fields[0] == "-S" {
+			fields = fields[1:]
+		}
+	}
+	if original == nil {
+		return opts
+	}
+	originalFields := strings.Fields(original["Exec"])
+	var extraFlags []string
+	for _, field := range fields {
+		if !slices.Contains(originalFields, field) {
+			extraFlags = append(extraFlags, field)
+		}
+	}
+	opts.ExtraFlags = strings.Join(extraFlags, " ")
+
+	suffix, found := strings.CutPrefix(exported["Name"], original["Name"])
+	switch {
+	case !found || suffix == " (on "+CurrentEnvironment().Name+")":
+	case suffix == "":
+		opts.ExportLabel = "none"
+	default:
+		opts.ExportLabel = strings.TrimSuffix(strings.TrimPrefix(suffix, " ("), ")")
+	}
+	return opts
+}
+
+// applicationDesktopFile returns the path of the desktop file of the application in the distrobox,
+// searched in the applications directories of XDG_DATA_DIRS. It returns an empty path when none is found.
+func applicationDesktopFile(appName string) string {
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range filepath.SplitList(dataDirs) {
+		path := filepath.Join(dir, "applications", appName+".desktop")
+		if fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// readDesktopEntry returns the first Name and Exec keys of the desktop file, nil when it can not be read
+func readDesktopEntry(path string) map[string]string {
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	entry := make(map[string]string, 2)
+	for _, line := range strings.Split(string(content), "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if _, seen := entry[key]; found && !seen && (key == "Name" || key == "Exec") {
+			entry[key] = value
+		}
+	}
+	return entry
+}
+
+// runDistroboxExport runs distrobox-export with the given arguments and invalidates the cached exports lists
+func runDistroboxExport(args ...string) error {
+	cmd := exec.Command(DISTROBOX_EXPORT_COMMAND, args...)
+	zap.L().Debug("Running command", zap.String("command", cmd.String()))
+	defer InvalidateDistroboxExportsCache()
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
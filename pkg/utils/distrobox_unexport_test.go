@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// stubDistroboxExport writes a distrobox-export script listing the given binaries and applications,
+// and recording the arguments of the other calls in the returned log file.
+func stubDistroboxExport(t *testing.T, dir, binaries, applications string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping distrobox script tests on Windows")
+	}
+	logFile := filepath.Join(dir, "calls.log")
+	makeDistroboxScript(t, dir, `#!/bin/sh
+if [ "$1" = "--list-binaries" ]; then
+	cat <<'LIST'
+`+binaries+`
+LIST
+	exit 0
+fi
+if [ "$1" = "--list-apps" ]; then
+	cat <<'LIST'
+`+applications+`
+LIST
+	exit 0
+fi
+echo "$@" >> "`+logFile+`"
+exit 0
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	distroboxExportAvailable = IsDistroboxExportAvailable()
+	InvalidateDistroboxExportsCache()
+	t.Cleanup(func() {
+		InvalidateDistroboxExportsCache()
+		distroboxExportAvailable = IsDistroboxExportAvailable()
+	})
+	return logFile
+}
+
+func readExportCalls(t *testing.T, logFile string) []string {
+	t.Helper()
+	data, err := os.ReadFile(logFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatalf("failed to read calls log: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func Test_UnexportDistroboxExports(t *testing.T) {
+	tests := []struct {
+		name            string
+		names           []string
+		wantCalls       []string
+		wantErrContains string
+	}{
+		{
+			name:      "binary by name",
+			names:     []string{"gofmt"},
+			wantCalls: []string{"--bin /usr/bin/gofmt --delete --export-path /home/user/bin"},
+		},
+		{
+			name:      "binary by path",
+			names:     []string{"/usr/bin/gofmt"},
+			wantCalls: []string{"--bin /usr/bin/gofmt --delete --export-path /home/user/bin"},
+		},
+		{
+			name:      "application",
+			names:     []string{"code"},
+			wantCalls: []string{"--app code --delete"},
+		},
+		{
+			name:            "not exported",
+			names:           []string{"cargo", "gofmt"},
+			wantCalls:       []string{"--bin /usr/bin/gofmt --delete --export-path /home/user/bin"},
+			wantErrContains: "cargo is neither an exported binary nor an exported application",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logFile := stubDistroboxExport(t, t.TempDir(), "/usr/bin/gofmt | /home/user/bin/gofmt", "code | /home/user/.local/share/applications/box-code.desktop")
+
+			errs := UnexportDistroboxExports(tt.names)
+			if tt.wantErrContains == "" && errs != nil {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			if tt.wantErrContains != "" && (len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErrContains)) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErrContains, errs)
+			}
+			if calls := readExportCalls(t, logFile); !slices.Equal(calls, tt.wantCalls) {
+				t.Fatalf("expected calls %v, got: %v", tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func Test_ReexportDistroboxBinaries(t *testing.T) {
+	dir := t.TempDir()
+	current := makeFakeBinary(t, dir, "current", "#!/bin/sh\nexit 0\n")
+	moved := makeFakeBinary(t, dir, "moved", "#!/bin/sh\nexit 0\n")
+	binaries := current + " | /home/user/bin/current\n/opt/old/moved | /home/user/bin/moved"
+
+	t.Run("repair only moved binaries", func(t *testing.T) {
+		logFile := stubDistroboxExport(t, dir, binaries, "")
+		t.Cleanup(func() { os.Remove(logFile) })
+
+		if errs := RepairDistroboxBinaries(); errs != nil {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		want := []string{
+			"--bin /opt/old/moved --delete --export-path /home/user/bin",
+			"--bin " + moved + " --export-path /home/user/bin",
+		}
+		if calls := readExportCalls(t, logFile); !slices.Equal(calls, want) {
+			t.Fatalf("expected calls %v, got: %v", want, calls)
+		}
+	})
+
+	t.Run("reexport all", func(t *testing.T) {
+		logFile := stubDistroboxExport(t, dir, binaries, "")
+		t.Cleanup(func() { os.Remove(logFile) })
+
+		if errs := ReexportDistroboxExports(); errs != nil {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		want := []string{
+			"--bin " + current + " --delete --export-path /home/user/bin",
+			"--bin " + current + " --export-path /home/user/bin",
+			"--bin /opt/old/moved --delete --export-path /home/user/bin",
+			"--bin " + moved + " --export-path /home/user/bin",
+		}
+		if calls := readExportCalls(t, logFile); !slices.Equal(calls, want) {
+			t.Fatalf("expected calls %v, got: %v", want, calls)
+		}
+	})
+
+	t.Run("binary outside of PATH", func(t *testing.T) {
+		download := makeFakeBinary(t, t.TempDir(), "checkstyle", "#!/bin/sh\nexit 0\n")
+		logFile := stubDistroboxExport(t, dir, download+" | /home/user/bin/checkstyle", "")
+		t.Cleanup(func() { os.Remove(logFile) })
+
+		if errs := RepairDistroboxBinaries(); errs != nil {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		if calls := readExportCalls(t, logFile); calls != nil {
+			t.Fatalf("expected the existing binary to be kept, got calls: %v", calls)
+		}
+	})
+
+	t.Run("binary shadowed on PATH", func(t *testing.T) {
+		// The previous binary still exists, but another one now comes first on PATH (e.g. after a toolchain version switch)
+		previous := makeFakeBinary(t, t.TempDir(), "current", "#!/bin/sh\nexit 0\n")
+		logFile := stubDistroboxExport(t, dir, previous+" | /home/user/bin/current", "")
+		t.Cleanup(func() { os.Remove(logFile) })
+
+		if errs := RepairDistroboxBinaries(); errs != nil {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		want := []string{
+			"--bin " + previous + " --delete --export-path /home/user/bin",
+			"--bin " + current + " --export-path /home/user/bin",
+		}
+		if calls := readExportCalls(t, logFile); !slices.Equal(calls, want) {
+			t.Fatalf("expected the binary to be exported from its path on PATH, got calls: %v", calls)
+		}
+	})
+
+	t.Run("host wrapper on PATH", func(t *testing.T) {
+		wrappers := t.TempDir()
+		makeFakeBinary(t, wrappers, "moved", "#!/bin/sh\nexec distrobox-enter -- /opt/old/moved\n")
+		logFile := stubDistroboxExport(t, dir, "/opt/old/moved | "+filepath.Join(wrappers, "moved"), "")
+		t.Cleanup(func() { os.Remove(logFile) })
+		t.Setenv("PATH", wrappers+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		if errs := RepairDistroboxBinaries(); errs != nil {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		want := []string{
+			"--bin /opt/old/moved --delete --export-path " + wrappers,
+			"--bin " + moved + " --export-path " + wrappers,
+		}
+		if calls := readExportCalls(t, logFile); !slices.Equal(calls, want) {
+			t.Fatalf("expected the binary to be exported from its path instead of the wrapper, got calls: %v", calls)
+		}
+	})
+
+	t.Run("removed binary", func(t *testing.T) {
+		stubDistroboxExport(t, dir, "/usr/bin/gone | /home/user/bin/gone", "")
+		logFile := filepath.Join(dir, "calls.log")
+		errs := RepairDistroboxBinaries()
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), "use devbox unexport to remove it") {
+			t.Fatalf("expected removed binary error, got: %v", errs)
+		}
+		if calls := readExportCalls(t, logFile); calls != nil {
+			t.Fatalf("expected the wrapper to be kept, got calls: %v", calls)
+		}
+	})
+}
+
+func Test_ReexportDistroboxApplications(t *testing.T) {
+	CurrentEnvironment()
+	previousEnvironment := currentEnvironment
+	currentEnvironment = &Environment{Name: "devbox", Container: true}
+	t.Cleanup(func() { currentEnvironment = previousEnvironment })
+
+	tests := []struct {
+		name     string
+		exported string
+		wantCall string
+	}{
+		{
+			name:     "default options",
+			exported: "Name=Code (on devbox)\nExec=/usr/bin/distrobox-enter  -n devbox  --   /usr/bin/code %F\n",
+			wantCall: "--app code",
+		},
+		{
+			name:     "sudo and extra flags",
+			exported: "Name=Code (on devbox)\nExec=/usr/bin/distrobox-enter  -n devbox  --  sudo -S /usr/bin/code --disable-gpu %F\n",
+			wantCall: "--app code --sudo --extra-flags --disable-gpu",
+		},
+		{
+			name:     "custom label",
+			exported: "Name=Code (work)\nExec=/usr/bin/distrobox-enter  -n devbox  --   /usr/bin/code %F\n",
+			wantCall: "--app code --export-label work",
+		},
+		{
+			name:     "no label",
+			exported: "Name=Code\nExec=/usr/bin/distrobox-enter  -n devbox  --   /usr/bin/code %F\n",
+			wantCall: "--app code --export-label none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			applications := filepath.Join(dir, "share", "applications")
+			if err := os.MkdirAll(applications, 0o700); err != nil {
+				t.Fatalf("failed to create applications dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(applications, "code.desktop"), []byte("[Desktop Entry]\nName=Code\nExec=/usr/bin/code %F\n"), 0o600); err != nil {
+				t.Fatalf("failed to write desktop file: %v", err)
+			}
+			t.Setenv("XDG_DATA_DIRS", filepath.Join(dir, "share"))
+			target := filepath.Join(dir, "devbox-code.desktop")
+			if err := os.WriteFile(target, []byte("[Desktop Entry]\n"+tt.exported), 0o600); err != nil {
+				t.Fatalf("failed to write exported desktop file: %v", err)
+			}
+			logFile := stubDistroboxExport(t, dir, "", "code | "+target)
+
+			if errs := ReexportDistroboxExports(); errs != nil {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			want := []string{"--app code --delete", tt.wantCall}
+			if calls := readExportCalls(t, logFile); !slices.Equal(calls, want) {
+				t.Fatalf("expected calls %v, got: %v", want, calls)
+			}
+		})
+	}
+}
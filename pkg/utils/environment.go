@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// CONTAINERENV_FILE is created by podman in every container it runs
+	CONTAINERENV_FILE = "/run/.containerenv"
+
+	// DOCKERENV_FILE is created by docker in every container it runs
+	DOCKERENV_FILE = "/.dockerenv"
+
+	// CONTAINER_ID_VARIABLE is set by distrobox and toolbox to the name of the container
+	CONTAINER_ID_VARIABLE = "CONTAINER_ID"
+)
+
+var (
+	// ENVIRONMENT_ROOT is the root directory used to look for the container markers
+	ENVIRONMENT_ROOT = "/"
+
+	// DISTROBOX_MARKERS are the files created by distrobox-init inside every distrobox
+	DISTROBOX_MARKERS = []string{
+		"/usr/bin/distrobox-host-exec",
+		"/usr/bin/host-spawn",
+		"/usr/bin/distrobox-export",
+	}
+
+	currentEnvironment     *Environment
+	currentEnvironmentOnce sync.Once
+)
+
+// Environment describes where devbox is running
+type Environment struct {
+	// Container is true when devbox runs inside a container
+	Container bool
+	// Distrobox is true when devbox runs inside a distrobox, exports to the host are only possible in this case
+	Distrobox bool
+	// Engine is the container engine running the container (podman, docker), if known
+	Engine string
+	// Name is the name of the container, if known
+	Name string
+}
+
+// CurrentEnvironment returns the environment devbox is running in, it is only detected once.
+func CurrentEnvironment() *Environment {
+	currentEnvironmentOnce.Do(func() {
+		currentEnvironment = DetectEnvironment()
+	})
+	return currentEnvironment
+}
+
+// DetectEnvironment detects whether devbox is running on the host, in a container or in a distrobox.
+// It checks the container engines marker files, the CONTAINER_ID variable and the distrobox host-spawn markers.
+func DetectEnvironment() *Environment {
+	env := &Environment{Name: os.Getenv(CONTAINER_ID_VARIABLE)}
+
+	if values, err := readContainerenv(filepath.Join(ENVIRONMENT_ROOT, CONTAINERENV_FILE)); err == nil {
+		env.Container = true
+		env.Engine = "podman"
+		if name := values["name"]; name != "" && env.Name == "" {
+			env.Name = name
+		}
+	} else if fileExists(filepath.Join(ENVIRONMENT_ROOT, DOCKERENV_FILE)) {
+		env.Container = true
+		env.Engine = "docker"
+	}
+	if env.Name != "" {
+		env.Container = true
+	}
+
+	for _, marker := range DISTROBOX_MARKERS {
+		if fileExists(filepath.Join(ENVIRONMENT_ROOT, marker)) {
+			env.Distrobox = env.Container
+			break
+		}
+	}
+	return env
+}
+
+// IsHost reports whether devbox is running directly on the host system.
+func (e *Environment) IsHost() bool {
+	return !e.Container
+}
+
+// String returns a human readable description of the environment.
+func (e *Environment) String() string {
+	if e.IsHost() {
+		return "host"
+	}
+	kind := "container"
+	if e.Distrobox {
+		kind = "distrobox"
+	}
+	var details []string
+	if e.Engine != "" {
+		details = append(details, e.Engine)
+	}
+	if e.Name != "" {
+		details = append(details, e.Name)
+	}
+	if len(details) == 0 {
+		return kind
+	}
+	return fmt.Sprintf("%s (%s)", kind, strings.Join(details, ", "))
+}
+
+// readContainerenv parses the key="value" lines of the podman .containerenv file
+func readContainerenv(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key, value, found := strings.Cut(scanner.Text(), "="); found {
+			values[strings.TrimSpace(key)] = TrimSpacesAndQuotes(value)
+		}
+	}
+	return values, scanner.Err()
+}
+
+// fileExists reports whether the file exists, whatever its type
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
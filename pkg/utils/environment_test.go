@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_DetectEnvironment(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		containerID string
+		want        Environment
+		wantString  string
+	}{
+		{
+			name:       "host",
+			want:       Environment{},
+			wantString: "host",
+		},
+		{
+			name:       "host with distrobox installed",
+			files:      map[string]string{"/usr/bin/distrobox-export": "", "/usr/bin/distrobox-host-exec": ""},
+			want:       Environment{},
+			wantString: "host",
+		},
+		{
+			name:       "podman container",
+			files:      map[string]string{CONTAINERENV_FILE: "engine=\"podman-5.2.0\"\nname=\"fedora\"\n"},
+			want:       Environment{Container: true, Engine: "podman", Name: "fedora"},
+			wantString: "container (podman, fedora)",
+		},
+		{
+			name:       "docker container",
+			files:      map[string]string{DOCKERENV_FILE: ""},
+			want:       Environment{Container: true, Engine: "docker"},
+			wantString: "container (docker)",
+		},
+		{
+			name:        "podman distrobox",
+			files:       map[string]string{CONTAINERENV_FILE: "name=\"fedora\"\n", "/usr/bin/host-spawn": ""},
+			containerID: "go-dev",
+			want:        Environment{Container: true, Distrobox: true, Engine: "podman", Name: "go-dev"},
+			wantString:  "distrobox (podman, go-dev)",
+		},
+		{
+			name:        "distrobox detected from CONTAINER_ID only",
+			files:       map[string]string{"/usr/bin/distrobox-host-exec": ""},
+			containerID: "go-dev",
+			want:        Environment{Container: true, Distrobox: true, Name: "go-dev"},
+			wantString:  "distrobox (go-dev)",
+		},
+	}
+
+	origRoot := ENVIRONMENT_ROOT
+	defer func() { ENVIRONMENT_ROOT = origRoot }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// avoid parallelism because tests modify package variables and environment
+			ENVIRONMENT_ROOT = t.TempDir()
+			for file, content := range tt.files {
+				path := filepath.Join(ENVIRONMENT_ROOT, file)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatalf("failed to create dir: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+					t.Fatalf("failed to write marker %s: %v", file, err)
+				}
+			}
+			t.Setenv(CONTAINER_ID_VARIABLE, tt.containerID)
+
+			got := DetectEnvironment()
+			if *got != tt.want {
+				t.Fatalf("DetectEnvironment() = %+v, want %+v", *got, tt.want)
+			}
+			if got.String() != tt.wantString {
+				t.Fatalf("String() = %q, want %q", got.String(), tt.wantString)
+			}
+			if got.IsHost() != (tt.wantString == "host") {
+				t.Fatalf("IsHost() = %v for %q", got.IsHost(), tt.wantString)
+			}
+		})
+	}
+}
@@ -2,6 +2,20 @@ package utils
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var (
+	// XDG_DATA_HOME is the base directory of the user data files
+	XDG_DATA_HOME = Getenv("XDG_DATA_HOME", filepath.Join(os.Getenv("HOME"), ".local", "share"))
+
+	// DEVBOX_DATA_DIR holds the files installed by devbox itself, outside of the package managers
+	DEVBOX_DATA_DIR = Getenv("DEVBOX_DATA_DIR", filepath.Join(XDG_DATA_HOME, "devbox"))
+
+	// DEVBOX_BIN_DIR holds the binaries installed or linked by devbox, exported to the host
+	DEVBOX_BIN_DIR = Getenv("DEVBOX_BIN_DIR", filepath.Join(DEVBOX_DATA_DIR, "bin"))
 )
 
 // Getenv retrieves the value of the environment variable named by key.
@@ -13,3 +27,29 @@ func Getenv(key string, defaultValue string) string {
 	}
 	return value
 }
+
+// GetenvInt retrieves the integer value of the environment variable named by key.
+// If the variable is not set or is not a valid integer, it returns the provided default value.
+func GetenvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// GetenvDuration retrieves the duration value of the environment variable named by key (e.g. "30s" or "5m").
+// If the variable is not set or is not a valid duration, it returns the provided default value.
+func GetenvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// IsTerminal reports whether the file is a terminal, e.g. os.Stdin when devbox is run interactively.
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestGetenv_DefaultBehavior(t *testing.T) {
@@ -25,3 +26,25 @@ func TestGetenv_DefaultBehavior(t *testing.T) {
 		t.Fatalf("expected set value, got %q", val)
 	}
 }
+
+func TestGetenvInt_and_Duration(t *testing.T) {
+	tests := []struct {
+		value        string
+		wantInt      int
+		wantDuration time.Duration
+	}{
+		{"", 3, 2 * time.Second},
+		{"5", 5, 2 * time.Second},
+		{"90s", 3, 90 * time.Second},
+		{"invalid", 3, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Setenv("DETECT_ENV_TEST", tt.value)
+		if got := GetenvInt("DETECT_ENV_TEST", 3); got != tt.wantInt {
+			t.Fatalf("expected %d for %q, got %d", tt.wantInt, tt.value, got)
+		}
+		if got := GetenvDuration("DETECT_ENV_TEST", 2*time.Second); got != tt.wantDuration {
+			t.Fatalf("expected %s for %q, got %s", tt.wantDuration, tt.value, got)
+		}
+	}
+}
@@ -18,10 +18,13 @@ var (
 	SystemVSCode *VSCode = &VSCode{SettingsFile: nil}
 
 	VSCODE_PACKAGE_MANAGER = &packagemanager.PackageManager{
-		Name:         "code",
-		InstallCmd:   "--install-extension",
-		MultiInstall: false,
-		SudoRequired: false,
+		Name:          "code",
+		InstallCmd:    "--install-extension",
+		MultiInstall:  false,
+		SudoRequired:  false,
+		UpgradeCmd:    []string{"--force", "--install-extension"},
+		QueryVersions: queryExtensionsVersions,
+		PinFormat:     "{name}@{version}",
 	}
 )
 
@@ -39,11 +42,30 @@ func (code *VSCode) FindVSCodeSettings() error {
 		return fmt.Errorf("provided settings.json file does not exist: %s", *code.SettingsFile)
 	}
 
-	var paths []string
+	paths, defaultPath := SettingsFileCandidates()
 
+	// Search through all potential paths
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			code.SettingsFile = &path
+			return nil
+		}
+	}
+	// If no valid path is found, issue a warning and set the default path
+	zap.L().Warn("VSCode settings.json file not found in standard locations, using default path for OS", zap.String("default_path", defaultPath), zap.String("OS", runtime.GOOS))
+
+	zap.L().Warn("Creating directories if they do not exist", zap.String("path", defaultPath))
+
+	// Ensure the file exists
+	code.SettingsFile = &defaultPath
+	return utils.CreateFileIfNotExists(defaultPath, []byte("{}"))
+}
+
+// SettingsFileCandidates returns the standard locations of the VS Code settings.json file for the current OS,
+// and the default location used when none of them exists.
+func SettingsFileCandidates() (paths []string, defaultPath string) {
 	// Determine the OS and add potential paths
 	zap.L().Debug("Detecting VSCode settings.json path for OS", zap.String("os", runtime.GOOS))
-	var defaultPath string
 	switch runtime.GOOS {
 	case "windows":
 		// Default Windows path
@@ -66,22 +88,7 @@ func (code *VSCode) FindVSCodeSettings() error {
 		// Flatpak-specific path
 		paths = append(paths, filepath.Join(os.Getenv("HOME"), ".var", "app", "com.visualstudio.code", "config", "Code", "User", "settings.json"))
 	}
-
-	// Search through all potential paths
-	for _, path := range paths {
-		if _, err := os.Stat(path); err == nil {
-			code.SettingsFile = &path
-			return nil
-		}
-	}
-	// If no valid path is found, issue a warning and set the default path
-	zap.L().Warn("VSCode settings.json file not found in standard locations, using default path for OS", zap.String("default_path", defaultPath), zap.String("OS", runtime.GOOS))
-
-	zap.L().Warn("Creating directories if they do not exist", zap.String("path", defaultPath))
-
-	// Ensure the file exists
-	code.SettingsFile = &defaultPath
-	return utils.CreateFileIfNotExists(defaultPath, []byte("{}"))
+	return paths, defaultPath
 }
 
 // UpdateSettings updates the VS Code settings.json file with the provided settings.
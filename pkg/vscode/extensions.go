@@ -0,0 +1,34 @@
+package vscode
+
+import (
+	"devbox/pkg/packagemanager"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// queryExtensionsVersions lists the installed VS Code extensions with their versions
+func queryExtensionsVersions(pm *packagemanager.PackageManager, extensions []string) (map[string]packagemanager.VersionInfo, error) {
+	cmd := exec.Command(pm.Name, "--list-extensions", "--show-versions")
+	zap.L().Debug("Running command", zap.String("command", cmd.String()))
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", cmd.String(), err)
+	}
+	return packagemanager.MatchVersions(extensions, ParseExtensionsList(string(output)), strings.ToLower), nil
+}
+
+// ParseExtensionsList parses the output of code --list-extensions --show-versions ("<publisher>.<name>@<version>" lines)
+// into the installed versions, keyed by extension identifier.
+func ParseExtensionsList(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		extension, version, _ := strings.Cut(strings.TrimSpace(line), "@")
+		if extension != "" {
+			installed[extension] = version
+		}
+	}
+	return installed
+}
@@ -0,0 +1,23 @@
+package vscode
+
+import (
+	"devbox/pkg/packagemanager"
+	"strings"
+	"testing"
+)
+
+func Test_ParseExtensionsList(t *testing.T) {
+	output := "golang.go@0.41.4\nms-python.python@2024.8.1\n\nredhat.vscode-yaml@1.15.0\n"
+	installed := ParseExtensionsList(output)
+	if len(installed) != 3 || installed["golang.go"] != "0.41.4" || installed["redhat.vscode-yaml"] != "1.15.0" {
+		t.Fatalf("unexpected extensions: %v", installed)
+	}
+
+	versions := packagemanager.MatchVersions([]string{"MS-Python.python", "rust-lang.rust-analyzer"}, installed, strings.ToLower)
+	if got := versions["MS-Python.python"]; !got.Installed || got.Version != "2024.8.1" {
+		t.Fatalf("expected the extension to be matched case insensitively, got: %+v", got)
+	}
+	if got := versions["rust-lang.rust-analyzer"]; got.Installed {
+		t.Fatalf("expected the extension not to be installed, got: %+v", got)
+	}
+}